@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/apply"
+)
+
+func main() {
+	apply.Main(os.Args[1:])
+}
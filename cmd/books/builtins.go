@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/audit"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/targets"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// connect loads .env or the named profile and returns a client for
+// command, along with the active profile if there is one.
+func connect(command, profileName string) (*elasticsearch7.Client, *profile.Profile, error) {
+	err := godotenv.Load()
+	if err != nil && profileName == "" && os.Getenv("ES_URL") == "" {
+		return nil, nil, fmt.Errorf("error loading .env file")
+	}
+
+	var activeProfile *profile.Profile
+	if profileName != "" {
+		activeProfile, err = profile.Activate(profileName)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, activeProfile, nil
+}
+
+// count prints the number of documents in an index.
+func count(profileName string, args []string) error {
+	flags := flag.NewFlagSet("count", flag.ExitOnError)
+	indexPtr := flags.String("index", "books", "Index to count documents in")
+	flags.Parse(args)
+
+	client, _, err := connect("books/count", profileName)
+	if err != nil {
+		return err
+	}
+
+	docs, err := countDocs(client, *indexPtr)
+	if err != nil {
+		return err
+	}
+	fmt.Println(docs)
+	return nil
+}
+
+func countDocs(client *elasticsearch7.Client, index string) (int64, error) {
+	resp, err := client.Count(client.Count.WithIndex(index))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return 0, fmt.Errorf("error counting %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+
+	var countResponse struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&countResponse); err != nil {
+		return 0, err
+	}
+	return countResponse.Count, nil
+}
+
+// deleteIndex deletes an index, or the indices an alias or pattern
+// resolves to, after listing them. Aliases and patterns have to be
+// confirmed, and production asks first. Every deletion is recorded in the
+// audit log.
+func deleteIndex(profileName string, args []string) error {
+	flags := flag.NewFlagSet("delete-index", flag.ExitOnError)
+	indexPtr := flags.String("index", "", "Index, alias or pattern of indices to delete")
+	yesPtr := flags.Bool("yes", false, "Skip confirming the indices an alias or pattern resolves to")
+	yesProdPtr := flags.Bool("yes-i-mean-prod", false, "Skip the confirmation prompt when -profile is tagged production")
+	flags.Parse(args)
+
+	if *indexPtr == "" {
+		return fmt.Errorf("no index provided for -index parameter")
+	}
+	if targets.MatchesEverything(*indexPtr) {
+		return fmt.Errorf("delete-index won't delete every index, %q matches them all", *indexPtr)
+	}
+
+	client, activeProfile, err := connect("books/delete-index", profileName)
+	if err != nil {
+		return err
+	}
+
+	indices, err := targets.Resolve(client, *indexPtr)
+	if err != nil {
+		return err
+	}
+	if err := targets.Confirm("delete", *indexPtr, indices, *yesPtr); err != nil {
+		return err
+	}
+	var docs int64
+	for _, index := range indices {
+		docs += index.Docs
+	}
+	if err := profile.ConfirmDestructive(activeProfile, fmt.Sprintf("delete %d indices with %d docs", len(indices), docs), *yesProdPtr); err != nil {
+		return err
+	}
+
+	auditLog, err := audit.New(client, "books delete-index", profileName)
+	if err != nil {
+		return err
+	}
+
+	// Indices are deleted by name, so an index created to match the
+	// pattern after it was confirmed is left alone
+	for _, index := range indices {
+		deleteErr := deleteOne(client, index.Name)
+		if err := auditLog.Record("delete", index.Name, index.Docs, deleteErr); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing audit log: %v\n", err)
+		}
+		if deleteErr != nil {
+			return deleteErr
+		}
+		fmt.Printf("deleted %s\n", index.Name)
+	}
+	return nil
+}
+
+func deleteOne(client *elasticsearch7.Client, index string) error {
+	resp, err := client.Indices.Delete([]string{index})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("error deleting %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// CatIndex is a row of _cat/indices.
+type CatIndex struct {
+	Health     string `json:"health"`
+	Status     string `json:"status"`
+	Index      string `json:"index"`
+	Primaries  int    `json:"primaries"`
+	Replicas   int    `json:"replicas"`
+	Docs       int64  `json:"docs"`
+	StoreBytes int64  `json:"store_bytes"`
+}
+
+// CatShard is a row of _cat/shards.
+type CatShard struct {
+	Index      string `json:"index"`
+	Shard      int    `json:"shard"`
+	Primary    bool   `json:"primary"`
+	State      string `json:"state"`
+	Docs       int64  `json:"docs"`
+	StoreBytes int64  `json:"store_bytes"`
+	Node       string `json:"node,omitempty"`
+}
+
+// CatNode is a row of _cat/nodes.
+type CatNode struct {
+	Name            string  `json:"name"`
+	IP              string  `json:"ip"`
+	Roles           string  `json:"roles"`
+	Master          bool    `json:"master"`
+	HeapPercent     int     `json:"heap_percent"`
+	RAMPercent      int     `json:"ram_percent"`
+	CPUPercent      int     `json:"cpu_percent"`
+	Load1m          float64 `json:"load_1m"`
+	DiskUsedPercent float64 `json:"disk_used_percent"`
+}
+
+// CatAllocation is a row of _cat/allocation.
+type CatAllocation struct {
+	Node             string `json:"node"`
+	Shards           int    `json:"shards"`
+	DiskIndicesBytes int64  `json:"disk_indices_bytes"`
+	DiskUsedBytes    int64  `json:"disk_used_bytes"`
+	DiskAvailBytes   int64  `json:"disk_avail_bytes"`
+	DiskTotalBytes   int64  `json:"disk_total_bytes"`
+	DiskPercent      int    `json:"disk_percent"`
+}
+
+var catSummaries = map[string]string{
+	"indices":    "indices with their health, doc count and size",
+	"shards":     "shards with their state, size and node",
+	"nodes":      "nodes with their roles and resource use",
+	"allocation": "shards and disk use per node",
+}
+
+// cat wraps the _cat APIs, printing typed JSON or an aligned table.
+func cat(profileName string, args []string) error {
+	flags := flag.NewFlagSet("cat", flag.ExitOnError)
+	outputPtr := flags.String("output", "table", "How to print the rows: table or json")
+	indexPtr := flags.String("index", "", "Only show these indices, a name, alias or pattern (indices and shards)")
+	healthPtr := flags.String("health", "", "Only show indices with this health: green, yellow or red (indices)")
+	statePtr := flags.String("state", "", "Only show shards in this state, e.g. UNASSIGNED (shards)")
+	nodePtr := flags.String("node", "", "Only show nodes whose name contains this (shards, nodes and allocation)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: books cat [flags] <endpoint>\n\nEndpoints:\n")
+		for _, name := range []string{"indices", "shards", "nodes", "allocation"} {
+			fmt.Fprintf(flags.Output(), "  %-11s %s\n", name, catSummaries[name])
+		}
+		fmt.Fprintf(flags.Output(), "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || catSummaries[flags.Arg(0)] == "" {
+		flags.Usage()
+		return fmt.Errorf("cat needs one of indices, shards, nodes or allocation")
+	}
+	endpoint := flags.Arg(0)
+
+	if *outputPtr != "table" && *outputPtr != "json" {
+		return fmt.Errorf("unknown -output %q, expected table or json", *outputPtr)
+	}
+
+	client, _, err := connect("books/cat", profileName)
+	if err != nil {
+		return err
+	}
+
+	var rows interface{}
+	var table [][]string
+	switch endpoint {
+	case "indices":
+		indices, err := catIndices(client, *indexPtr, *healthPtr)
+		if err != nil {
+			return err
+		}
+		rows = indices
+		table = append(table, []string{"HEALTH", "STATUS", "INDEX", "PRI", "REP", "DOCS", "SIZE"})
+		for _, index := range indices {
+			table = append(table, []string{index.Health, index.Status, index.Index, strconv.Itoa(index.Primaries), strconv.Itoa(index.Replicas), strconv.FormatInt(index.Docs, 10), formatBytes(index.StoreBytes)})
+		}
+	case "shards":
+		shards, err := catShards(client, *indexPtr, *statePtr, *nodePtr)
+		if err != nil {
+			return err
+		}
+		rows = shards
+		table = append(table, []string{"INDEX", "SHARD", "PRIREP", "STATE", "DOCS", "SIZE", "NODE"})
+		for _, shard := range shards {
+			prirep := "r"
+			if shard.Primary {
+				prirep = "p"
+			}
+			table = append(table, []string{shard.Index, strconv.Itoa(shard.Shard), prirep, shard.State, strconv.FormatInt(shard.Docs, 10), formatBytes(shard.StoreBytes), shard.Node})
+		}
+	case "nodes":
+		nodes, err := catNodes(client, *nodePtr)
+		if err != nil {
+			return err
+		}
+		rows = nodes
+		table = append(table, []string{"NAME", "IP", "ROLES", "MASTER", "HEAP%", "RAM%", "CPU%", "LOAD_1M", "DISK%"})
+		for _, node := range nodes {
+			master := "-"
+			if node.Master {
+				master = "*"
+			}
+			table = append(table, []string{node.Name, node.IP, node.Roles, master, strconv.Itoa(node.HeapPercent), strconv.Itoa(node.RAMPercent), strconv.Itoa(node.CPUPercent), strconv.FormatFloat(node.Load1m, 'f', 2, 64), strconv.FormatFloat(node.DiskUsedPercent, 'f', 1, 64)})
+		}
+	case "allocation":
+		allocations, err := catAllocation(client, *nodePtr)
+		if err != nil {
+			return err
+		}
+		rows = allocations
+		table = append(table, []string{"NODE", "SHARDS", "DISK.INDICES", "DISK.USED", "DISK.AVAIL", "DISK.TOTAL", "DISK%"})
+		for _, allocation := range allocations {
+			table = append(table, []string{allocation.Node, strconv.Itoa(allocation.Shards), formatBytes(allocation.DiskIndicesBytes), formatBytes(allocation.DiskUsedBytes), formatBytes(allocation.DiskAvailBytes), formatBytes(allocation.DiskTotalBytes), strconv.Itoa(allocation.DiskPercent)})
+		}
+	}
+
+	if *outputPtr == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range table {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// catRows decodes the JSON rows of a _cat response. Values are strings,
+// or null where Elasticsearch has none, like the node of an unassigned
+// shard.
+func catRows(resp *esapi.Response, err error) ([]map[string]*string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("error calling _cat, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+	var rows []map[string]*string
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func catString(row map[string]*string, column string) string {
+	if value := row[column]; value != nil {
+		return *value
+	}
+	return ""
+}
+
+func catInt(row map[string]*string, column string) int64 {
+	value, _ := strconv.ParseInt(catString(row, column), 10, 64)
+	return value
+}
+
+func catFloat(row map[string]*string, column string) float64 {
+	value, _ := strconv.ParseFloat(catString(row, column), 64)
+	return value
+}
+
+func catIndices(client *elasticsearch7.Client, index, health string) ([]CatIndex, error) {
+	options := []func(*esapi.CatIndicesRequest){
+		client.Cat.Indices.WithFormat("json"),
+		client.Cat.Indices.WithBytes("b"),
+		client.Cat.Indices.WithExpandWildcards("open,closed"),
+		client.Cat.Indices.WithH("health", "status", "index", "pri", "rep", "docs.count", "store.size"),
+	}
+	if index != "" {
+		options = append(options, client.Cat.Indices.WithIndex(index))
+	}
+	if health != "" {
+		options = append(options, client.Cat.Indices.WithHealth(health))
+	}
+	rows, err := catRows(client.Cat.Indices(options...))
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]CatIndex, len(rows))
+	for i, row := range rows {
+		indices[i] = CatIndex{
+			Health:     catString(row, "health"),
+			Status:     catString(row, "status"),
+			Index:      catString(row, "index"),
+			Primaries:  int(catInt(row, "pri")),
+			Replicas:   int(catInt(row, "rep")),
+			Docs:       catInt(row, "docs.count"),
+			StoreBytes: catInt(row, "store.size"),
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Index < indices[j].Index })
+	return indices, nil
+}
+
+func catShards(client *elasticsearch7.Client, index, state, node string) ([]CatShard, error) {
+	options := []func(*esapi.CatShardsRequest){
+		client.Cat.Shards.WithFormat("json"),
+		client.Cat.Shards.WithBytes("b"),
+		client.Cat.Shards.WithH("index", "shard", "prirep", "state", "docs", "store", "node"),
+	}
+	if index != "" {
+		options = append(options, client.Cat.Shards.WithIndex(index))
+	}
+	rows, err := catRows(client.Cat.Shards(options...))
+	if err != nil {
+		return nil, err
+	}
+
+	var shards []CatShard
+	for _, row := range rows {
+		shard := CatShard{
+			Index:      catString(row, "index"),
+			Shard:      int(catInt(row, "shard")),
+			Primary:    catString(row, "prirep") == "p",
+			State:      catString(row, "state"),
+			Docs:       catInt(row, "docs"),
+			StoreBytes: catInt(row, "store"),
+			Node:       catString(row, "node"),
+		}
+		if state != "" && !strings.EqualFold(shard.State, state) {
+			continue
+		}
+		if node != "" && !strings.Contains(shard.Node, node) {
+			continue
+		}
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		if shards[i].Index != shards[j].Index {
+			return shards[i].Index < shards[j].Index
+		}
+		if shards[i].Shard != shards[j].Shard {
+			return shards[i].Shard < shards[j].Shard
+		}
+		return shards[i].Primary
+	})
+	return shards, nil
+}
+
+func catNodes(client *elasticsearch7.Client, node string) ([]CatNode, error) {
+	rows, err := catRows(client.Cat.Nodes(
+		client.Cat.Nodes.WithFormat("json"),
+		client.Cat.Nodes.WithH("name", "ip", "node.role", "master", "heap.percent", "ram.percent", "cpu", "load_1m", "disk.used_percent")))
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []CatNode
+	for _, row := range rows {
+		catNode := CatNode{
+			Name:            catString(row, "name"),
+			IP:              catString(row, "ip"),
+			Roles:           catString(row, "node.role"),
+			Master:          catString(row, "master") == "*",
+			HeapPercent:     int(catInt(row, "heap.percent")),
+			RAMPercent:      int(catInt(row, "ram.percent")),
+			CPUPercent:      int(catInt(row, "cpu")),
+			Load1m:          catFloat(row, "load_1m"),
+			DiskUsedPercent: catFloat(row, "disk.used_percent"),
+		}
+		if node != "" && !strings.Contains(catNode.Name, node) {
+			continue
+		}
+		nodes = append(nodes, catNode)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+func catAllocation(client *elasticsearch7.Client, node string) ([]CatAllocation, error) {
+	rows, err := catRows(client.Cat.Allocation(
+		client.Cat.Allocation.WithFormat("json"),
+		client.Cat.Allocation.WithBytes("b"),
+		client.Cat.Allocation.WithH("node", "shards", "disk.indices", "disk.used", "disk.avail", "disk.total", "disk.percent")))
+	if err != nil {
+		return nil, err
+	}
+
+	var allocations []CatAllocation
+	for _, row := range rows {
+		allocation := CatAllocation{
+			Node:             catString(row, "node"),
+			Shards:           int(catInt(row, "shards")),
+			DiskIndicesBytes: catInt(row, "disk.indices"),
+			DiskUsedBytes:    catInt(row, "disk.used"),
+			DiskAvailBytes:   catInt(row, "disk.avail"),
+			DiskTotalBytes:   catInt(row, "disk.total"),
+			DiskPercent:      int(catInt(row, "disk.percent")),
+		}
+		if node != "" && !strings.Contains(allocation.Node, node) {
+			continue
+		}
+		allocations = append(allocations, allocation)
+	}
+	sort.Slice(allocations, func(i, j int) bool { return allocations[i].Node < allocations[j].Node })
+	return allocations, nil
+}
+
+// formatBytes prints a size in the largest binary unit it fills, e.g.
+// 1.5gb, like the _cat APIs do.
+func formatBytes(bytes int64) string {
+	units := []string{"b", "kb", "mb", "gb", "tb", "pb"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", size, units[unit])
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bashCompletion completes subcommand names, and the flags of a
+// subcommand by asking it for its usage. The shared flags before the
+// subcommand each take a value.
+const bashCompletion = `_books() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	local i=1
+	while [ "$i" -lt "$COMP_CWORD" ] && [[ "${COMP_WORDS[$i]}" == -* ]]; do
+		i=$((i + 2))
+	done
+	if [ "$COMP_CWORD" -eq "$i" ]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	elif [ "$COMP_CWORD" -gt "$i" ] && [[ "$cur" == -* ]]; then
+		local flags=$("${COMP_WORDS[0]}" "${COMP_WORDS[$i]}" -h 2>&1 | awk '$1 ~ /^-/ {print $1}')
+		COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+	fi
+}
+complete -o default -F _books books
+`
+
+// completion prints a shell completion script.
+func completion(profileName string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: books completion bash|zsh")
+	}
+
+	script := fmt.Sprintf(bashCompletion, strings.Join(commandNames(), " "))
+	switch args[0] {
+	case "bash":
+		fmt.Print(script)
+	case "zsh":
+		fmt.Print("autoload -U +X bashcompinit && bashcompinit\n" + script)
+	default:
+		return fmt.Errorf("unknown shell %q, expected bash or zsh", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/changelog"
+)
+
+// history prints the write history of a book from the changelog index.
+func history(profileName string, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	changelogIndexPtr := flags.String("changelog-index", os.Getenv(changelog.EnvIndex), "Changelog index to read, defaults to $SEARCH_GO_CHANGELOG_INDEX")
+	indexPtr := flags.String("index", "", "Only show writes to this index")
+	sizePtr := flags.Int("size", 100, "Maximum number of writes to show")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: books history [flags] <id>\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("history needs the ID of one book")
+	}
+	if *changelogIndexPtr == "" {
+		return fmt.Errorf("no changelog index, set -changelog-index or %s", changelog.EnvIndex)
+	}
+	id := flags.Arg(0)
+
+	client, _, err := connect("books/history", profileName)
+	if err != nil {
+		return err
+	}
+
+	entries, err := changelog.Entries(client, *changelogIndexPtr, *indexPtr, id, *sizePtr)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("no writes to %s recorded in %s\n", id, *changelogIndexPtr)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOP\tINDEX\tRUN\tCOMMAND")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.RFC3339), entry.Op, entry.Index, entry.RunID, entry.Command)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,170 @@
+// books runs every search-go tool as a subcommand of one binary, so
+// scripts only need one name on their PATH and share connection flags.
+// The tools are compiled in from internal/commands, the same packages the
+// standalone binaries in cmd/ run, e.g. "books search" runs search-books.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/nickcanz/search-go/internal/commands/apply"
+	"github.com/nickcanz/search-go/internal/commands/checksumbooks"
+	"github.com/nickcanz/search-go/internal/commands/feed"
+	"github.com/nickcanz/search-go/internal/commands/lintquery"
+	"github.com/nickcanz/search-go/internal/commands/loadbooks"
+	"github.com/nickcanz/search-go/internal/commands/login"
+	"github.com/nickcanz/search-go/internal/commands/migrate"
+	"github.com/nickcanz/search-go/internal/commands/pruneindices"
+	"github.com/nickcanz/search-go/internal/commands/replay"
+	"github.com/nickcanz/search-go/internal/commands/retryfailed"
+	"github.com/nickcanz/search-go/internal/commands/scripttest"
+	"github.com/nickcanz/search-go/internal/commands/searchbooks"
+	"github.com/nickcanz/search-go/internal/commands/sitemap"
+	"github.com/nickcanz/search-go/internal/commands/suggestbooks"
+	"github.com/nickcanz/search-go/internal/commands/tasks"
+)
+
+// tool is a subcommand that runs one of the tools.
+type tool struct {
+	main    func(args []string)
+	summary string
+	// verbs is the number of arguments, like migrate's up or status, that
+	// come before the tool's flags
+	verbs int
+}
+
+// tools maps subcommands to the tools that implement them.
+var tools = map[string]tool{
+	"apply":       {main: apply.Main, summary: "applies an index spec"},
+	"checksum":    {main: checksumbooks.Main, summary: "compares an index with its source file"},
+	"feed":        {main: feed.Main, summary: "writes an Atom feed of new books"},
+	"lint":        {main: lintquery.Main, summary: "checks a query for mistakes"},
+	"load":        {main: loadbooks.Main, summary: "loads books into an index"},
+	"login":       {main: login.Main, summary: "saves encrypted credentials for a profile"},
+	"migrate":     {main: migrate.Main, summary: "applies mapping migrations", verbs: 1},
+	"prune":       {main: pruneindices.Main, summary: "deletes dated indices past their retention"},
+	"replay":      {main: replay.Main, summary: "replays logged searches"},
+	"retry":       {main: retryfailed.Main, summary: "resubmits documents that failed to index"},
+	"script-test": {main: scripttest.Main, summary: "tries out a ranking script"},
+	"search":      {main: searchbooks.Main, summary: "searches books"},
+	"sitemap":     {main: sitemap.Main, summary: "writes sitemap.xml shards"},
+	"suggest":     {main: suggestbooks.Main, summary: "completes titles from a prefix"},
+	"tasks":       {main: tasks.Main, summary: "lists and cancels running requests"},
+}
+
+// builtins are subcommands implemented in this binary. They are set in
+// init because completion lists them.
+var builtins map[string]func(profileName string, args []string) error
+
+var builtinSummaries = map[string]string{
+	"count":        "prints the number of documents in an index",
+	"delete":       "soft deletes books, or removes them with -hard",
+	"purge":        "removes books soft deleted more than -older-than days ago",
+	"history":      "prints the writes recorded for a book in the changelog index",
+	"cat":          "prints cluster indices, shards, nodes or disk allocation",
+	"delete-index": "deletes an index",
+	"completion":   "prints a bash or zsh completion script",
+	"self-update":  "updates books to the newest release",
+	"version":      "prints the version of books",
+}
+
+func init() {
+	builtins = map[string]func(profileName string, args []string) error{
+		"count":        count,
+		"delete":       deleteBooks,
+		"purge":        purge,
+		"history":      history,
+		"cat":          cat,
+		"delete-index": deleteIndex,
+		"completion":   completion,
+		"self-update":  selfUpdate,
+		"version":      printVersion,
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+
+	flag.Usage = usage
+	profilePtr := flag.String("profile", "", "Connect using this named profile instead of .env, for every subcommand")
+	urlPtr := flag.String("url", "", "Connect to this Elasticsearch URL instead of ES_URL, for every subcommand")
+	userPtr := flag.String("user", "", "User to connect to -url as, with the password in ES_PASSWORD. Without it -url is connected to without credentials")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name, args := flag.Arg(0), flag.Args()[1:]
+
+	if *urlPtr != "" {
+		if *profilePtr != "" {
+			log.Fatal("-url and -profile can't be used together, the profile sets the URL")
+		}
+		useURL(*urlPtr, *userPtr)
+	} else if *userPtr != "" {
+		log.Fatal("-user needs -url")
+	}
+
+	if builtin, ok := builtins[name]; ok {
+		if err := builtin(*profilePtr, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	t, ok := tools[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if *profilePtr != "" && len(args) >= t.verbs {
+		shared := []string{"-profile", *profilePtr}
+		args = append(append(append([]string{}, args[:t.verbs]...), shared...), args[t.verbs:]...)
+	}
+	t.main(args)
+}
+
+// useURL exports url as ES_URL, which the .env file won't override. With
+// user, the password is ES_PASSWORD from the environment or .env. Without
+// it, url is connected to without credentials, like a profile that only
+// sets a URL, so the ones in .env are never sent to another cluster.
+func useURL(url, user string) {
+	os.Setenv("ES_URL", url)
+	if user != "" {
+		os.Setenv("ES_USER", user)
+		return
+	}
+	os.Setenv("ES_USER", "")
+	os.Setenv("ES_PASSWORD", "")
+}
+
+// commandNames returns every subcommand, in name order.
+func commandNames() []string {
+	var names []string
+	for name := range tools {
+		names = append(names, name)
+	}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: books [-profile name | -url url [-user user]] <command> [flags]\n\nCommands:\n")
+	for _, name := range commandNames() {
+		if t, ok := tools[name]; ok {
+			fmt.Fprintf(os.Stderr, "  %-13s %s\n", name, t.summary)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %-13s %s\n", name, builtinSummaries[name])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\nRun books <command> -h for the flags of a command.\n")
+}
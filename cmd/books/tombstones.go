@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/internal/audit"
+	"github.com/nickcanz/search-go/internal/changelog"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/tombstone"
+)
+
+// deleteBooks soft deletes books by marking them deleted, so searches
+// leave them out until purge removes them. -hard removes them straight
+// away and -restore clears the mark.
+func deleteBooks(profileName string, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ExitOnError)
+	indexPtr := flags.String("index", "books", "Index to delete books from")
+	idsPtr := flags.String("id", "", "Comma separated IDs of the books to delete")
+	hardPtr := flags.Bool("hard", false, "Remove the books now instead of marking them deleted")
+	restorePtr := flags.Bool("restore", false, "Clear the deleted mark of books that were soft deleted")
+	yesPtr := flags.Bool("yes-i-mean-prod", false, "Skip the confirmation prompt for -hard when -profile is tagged production")
+	flags.Parse(args)
+
+	if *idsPtr == "" {
+		return fmt.Errorf("no book IDs provided for -id parameter")
+	}
+	if *hardPtr && *restorePtr {
+		return fmt.Errorf("-hard deleted books can't be restored")
+	}
+	ids := strings.Split(*idsPtr, ",")
+
+	client, activeProfile, err := connect("books/delete", profileName)
+	if err != nil {
+		return err
+	}
+	changes, err := changelog.FromEnv(client, "books delete")
+	if err != nil {
+		return err
+	}
+	defer changes.Close()
+
+	if !*hardPtr {
+		now := time.Now()
+		doc := tombstone.Mark(now)
+		action := "deleted"
+		if *restorePtr {
+			doc = map[string]interface{}{tombstone.Field: false, tombstone.AtField: nil}
+			action = "restored"
+		}
+		doc["updated_at"] = now.UTC().Format(time.RFC3339)
+		for _, id := range ids {
+			if err := updateBook(client, *indexPtr, id, doc); err != nil {
+				return err
+			}
+			changes.Record(*indexPtr, id, changelog.OpUpdate)
+			fmt.Printf("%s %s\n", action, id)
+		}
+		return nil
+	}
+
+	if err := profile.ConfirmDestructive(activeProfile, fmt.Sprintf("permanently delete %d books from %s", len(ids), *indexPtr), *yesPtr); err != nil {
+		return err
+	}
+	auditLog, err := audit.New(client, "books delete", profileName)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		deleteErr := deleteBook(client, *indexPtr, id)
+		if err := auditLog.Record("delete", *indexPtr+"/"+id, 1, deleteErr); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing audit log: %v\n", err)
+		}
+		if deleteErr != nil {
+			return deleteErr
+		}
+		changes.Record(*indexPtr, id, changelog.OpDelete)
+		fmt.Printf("permanently deleted %s\n", id)
+	}
+	return nil
+}
+
+func updateBook(client *elasticsearch7.Client, index, id string, doc map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"doc": doc})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Update(index, id, bytes.NewReader(body), client.Update.WithRefresh("true"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("no book %s in %s", id, index)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("error updating %s, status: %s, response body: %s", id, resp.Status(), resp.String())
+	}
+	return nil
+}
+
+func deleteBook(client *elasticsearch7.Client, index, id string) error {
+	resp, err := client.Delete(index, id, client.Delete.WithRefresh("true"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("no book %s in %s", id, index)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("error deleting %s, status: %s, response body: %s", id, resp.Status(), resp.String())
+	}
+	return nil
+}
+
+// purge permanently removes books that were soft deleted more than a
+// number of days ago.
+func purge(profileName string, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	indexPtr := flags.String("index", "books", "Index to purge deleted books from")
+	olderThanPtr := flags.Int("older-than", 30, "Purge books deleted more than this many days ago")
+	dryRunPtr := flags.Bool("dry-run", false, "Only print how many books would be purged")
+	yesPtr := flags.Bool("yes-i-mean-prod", false, "Skip the confirmation prompt when -profile is tagged production")
+	flags.Parse(args)
+
+	if *olderThanPtr < 0 {
+		return fmt.Errorf("-older-than must be 0 or more days, got %d", *olderThanPtr)
+	}
+
+	client, activeProfile, err := connect("books/purge", profileName)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -*olderThanPtr)
+	body, err := json.Marshal(map[string]interface{}{"query": tombstone.Expired(cutoff)})
+	if err != nil {
+		return err
+	}
+
+	countResp, err := client.Count(client.Count.WithIndex(*indexPtr), client.Count.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		return err
+	}
+	defer countResp.Body.Close()
+	if countResp.IsError() {
+		return fmt.Errorf("error counting deleted books in %s, status: %s, response body: %s", *indexPtr, countResp.Status(), countResp.String())
+	}
+	var countResponse struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(countResp.Body).Decode(&countResponse); err != nil {
+		return err
+	}
+
+	if countResponse.Count == 0 || *dryRunPtr {
+		fmt.Printf("%d books in %s were deleted more than %d days ago\n", countResponse.Count, *indexPtr, *olderThanPtr)
+		return nil
+	}
+	if err := profile.ConfirmDestructive(activeProfile, fmt.Sprintf("purge %d deleted books from %s", countResponse.Count, *indexPtr), *yesPtr); err != nil {
+		return err
+	}
+	auditLog, err := audit.New(client, "books purge", profileName)
+	if err != nil {
+		return err
+	}
+
+	purged, purgeErr := deleteByQuery(client, *indexPtr, body)
+	if err := auditLog.Record("purge", *indexPtr, purged, purgeErr); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing audit log: %v\n", err)
+	}
+	if purgeErr != nil {
+		return purgeErr
+	}
+	fmt.Printf("purged %d deleted books from %s\n", purged, *indexPtr)
+	return nil
+}
+
+func deleteByQuery(client *elasticsearch7.Client, index string, body []byte) (int64, error) {
+	resp, err := client.DeleteByQuery(
+		[]string{index},
+		bytes.NewReader(body),
+		client.DeleteByQuery.WithRefresh(true))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return 0, fmt.Errorf("error purging %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+	var deleteResponse struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&deleteResponse); err != nil {
+		return 0, err
+	}
+	return deleteResponse.Deleted, nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/version"
+)
+
+// releasesURL is the GitHub API endpoint for the newest release.
+const releasesURL = "https://api.github.com/repos/nickcanz/search-go/releases/latest"
+
+// printVersion prints which build of books is running.
+func printVersion(profileName string, args []string) error {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	flags.Parse(args)
+
+	fmt.Printf("books %s\n", version.String())
+	return nil
+}
+
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// selfUpdate replaces books with the newest release for this platform.
+func selfUpdate(profileName string, args []string) error {
+	flags := flag.NewFlagSet("self-update", flag.ExitOnError)
+	checkPtr := flags.Bool("check", false, "Only print whether a newer release is available")
+	forcePtr := flags.Bool("force", false, "Install the newest release even if it isn't newer than this build")
+	flags.Parse(args)
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		return err
+	}
+	if manager := packageManager(self); manager != "" {
+		return fmt.Errorf("books was installed with %s, update it with %s instead", strings.Fields(manager)[0], manager)
+	}
+
+	httpClient := &http.Client{Timeout: time.Minute}
+	latest, err := latestRelease(httpClient)
+	if err != nil {
+		return err
+	}
+	if !version.Newer(latest.TagName, version.Version) && !*forcePtr {
+		fmt.Printf("books %s is up to date, the newest release is %s\n", version.Version, latest.TagName)
+		return nil
+	}
+	if *checkPtr {
+		fmt.Printf("books %s can be updated to %s\n", version.Version, latest.TagName)
+		return nil
+	}
+
+	archiveName := fmt.Sprintf("search-go_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		archiveName = strings.TrimSuffix(archiveName, ".tar.gz") + ".zip"
+	}
+	var archiveURL, checksumsURL string
+	for _, asset := range latest.Assets {
+		switch asset.Name {
+		case archiveName:
+			archiveURL = asset.URL
+		case "checksums.txt":
+			checksumsURL = asset.URL
+		}
+	}
+	if archiveURL == "" {
+		return fmt.Errorf("release %s has no %s for this platform", latest.TagName, archiveName)
+	}
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no checksums.txt to verify %s with", latest.TagName, archiveName)
+	}
+
+	checksums, err := download(httpClient, checksumsURL)
+	if err != nil {
+		return err
+	}
+	archive, err := download(httpClient, archiveURL)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(archiveName, archive, checksums); err != nil {
+		return err
+	}
+
+	contents, err := extractBooks(archiveName, archive)
+	if err != nil {
+		return err
+	}
+	if err := replaceFile(self, contents); err != nil {
+		return err
+	}
+	fmt.Printf("updated %s from %s to %s\n", self, version.Version, latest.TagName)
+	return nil
+}
+
+// packageManager returns the command that updates books if it was
+// installed by Homebrew or Scoop, which would lose track of a binary
+// replaced behind their backs.
+func packageManager(self string) string {
+	slashed := filepath.ToSlash(self)
+	switch {
+	case strings.Contains(slashed, "/Cellar/"):
+		return "brew upgrade search-go"
+	case strings.Contains(strings.ToLower(slashed), "/scoop/apps/"):
+		return "scoop update search-go"
+	}
+	return ""
+}
+
+func latestRelease(httpClient *http.Client) (*release, error) {
+	req, err := http.NewRequest(http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error checking for releases, status: %s", resp.Status)
+	}
+
+	var latest release
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil {
+		return nil, err
+	}
+	return &latest, nil
+}
+
+func download(httpClient *http.Client, url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s, status: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyChecksum checks contents against the line for name in a
+// checksums.txt of "<sha256>  <name>" lines.
+func verifyChecksum(name string, contents, checksums []byte) error {
+	sum := sha256.Sum256(contents)
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[1] != name {
+			continue
+		}
+		if fields[0] != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("%s does not match its checksum, not updating", name)
+		}
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("checksums.txt has no checksum for %s", name)
+}
+
+// extractBooks returns the books binary from a release archive. The
+// tools are compiled into it, so the standalone binaries beside it in the
+// archive aren't needed.
+func extractBooks(archiveName string, archive []byte) ([]byte, error) {
+	isBooks := func(name string) bool {
+		return name == "books" || name == "books.exe"
+	}
+
+	if strings.HasSuffix(archiveName, ".zip") {
+		zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zipReader.File {
+			name := path.Base(file.Name)
+			if file.FileInfo().IsDir() || !isBooks(name) {
+				continue
+			}
+			reader, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			contents, err := io.ReadAll(reader)
+			reader.Close()
+			return contents, err
+		}
+	} else {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+		if err != nil {
+			return nil, err
+		}
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			name := path.Base(header.Name)
+			if header.Typeflag != tar.TypeReg || !isBooks(name) {
+				continue
+			}
+			return io.ReadAll(tarReader)
+		}
+	}
+
+	return nil, fmt.Errorf("%s has no books binary", archiveName)
+}
+
+// replaceFile atomically replaces the executable at target with contents.
+// Windows won't replace a running executable, but will rename it, so the
+// old one is moved aside to target.old first.
+func replaceFile(target string, contents []byte) error {
+	temp := target + ".new"
+	if err := os.WriteFile(temp, contents, 0o755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := target + ".old"
+		os.Remove(old)
+		if err := os.Rename(target, old); err != nil && !os.IsNotExist(err) {
+			os.Remove(temp)
+			return err
+		}
+	}
+	if err := os.Rename(temp, target); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/checksumbooks"
+)
+
+func main() {
+	checksumbooks.Main(os.Args[1:])
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/feed"
+)
+
+func main() {
+	feed.Main(os.Args[1:])
+}
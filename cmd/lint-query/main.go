@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/lintquery"
+)
+
+func main() {
+	lintquery.Main(os.Args[1:])
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/login"
+)
+
+func main() {
+	login.Main(os.Args[1:])
+}
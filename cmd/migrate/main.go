@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/migrate"
+)
+
+func main() {
+	migrate.Main(os.Args[1:])
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/pruneindices"
+)
+
+func main() {
+	pruneindices.Main(os.Args[1:])
+}
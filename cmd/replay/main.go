@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/replay"
+)
+
+func main() {
+	replay.Main(os.Args[1:])
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/retryfailed"
+)
+
+func main() {
+	retryfailed.Main(os.Args[1:])
+}
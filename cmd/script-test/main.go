@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/scripttest"
+)
+
+func main() {
+	scripttest.Main(os.Args[1:])
+}
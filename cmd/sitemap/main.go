@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/sitemap"
+)
+
+func main() {
+	sitemap.Main(os.Args[1:])
+}
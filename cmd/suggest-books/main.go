@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/suggestbooks"
+)
+
+func main() {
+	suggestbooks.Main(os.Args[1:])
+}
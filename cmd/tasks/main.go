@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/nickcanz/search-go/internal/commands/tasks"
+)
+
+func main() {
+	tasks.Main(os.Args[1:])
+}
@@ -3,6 +3,14 @@ module github.com/nickcanz/search-go
 go 1.20
 
 require (
+	filippo.io/age v1.1.1
 	github.com/elastic/go-elasticsearch/v7 v7.10.0
 	github.com/joho/godotenv v1.5.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/tetratelabs/wazero v1.5.0
+	golang.org/x/sys v0.13.0
+	golang.org/x/term v0.13.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/crypto v0.4.0 // indirect
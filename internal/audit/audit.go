@@ -0,0 +1,122 @@
+// Package audit records destructive operations, such as deleting an index,
+// to a local log file and optionally to an audit index in the cluster, so
+// changes to shared environments can be traced back to who ran them.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+// Entry describes one destructive operation.
+type Entry struct {
+	Time    time.Time `json:"@timestamp"`
+	User    string    `json:"user"`
+	Host    string    `json:"host"`
+	ESUser  string    `json:"es_user,omitempty"`
+	Profile string    `json:"profile,omitempty"`
+	Command string    `json:"command"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target"`
+	Docs    int64     `json:"docs"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Logger writes entries to the file in SEARCH_GO_AUDIT_LOG, by default
+// audit.log in the search-go config directory, and to the index in
+// SEARCH_GO_AUDIT_INDEX when it is set.
+type Logger struct {
+	client  *elasticsearch7.Client
+	command string
+	profile string
+	path    string
+	index   string
+}
+
+// New returns a logger for entries made by command using client.
+func New(client *elasticsearch7.Client, command, profile string) (*Logger, error) {
+	path := os.Getenv("SEARCH_GO_AUDIT_LOG")
+	if path == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(configDir, "search-go", "audit.log")
+	}
+
+	return &Logger{
+		client:  client,
+		command: command,
+		profile: profile,
+		path:    path,
+		index:   os.Getenv("SEARCH_GO_AUDIT_INDEX"),
+	}, nil
+}
+
+// Record fills in who and when, then writes the entry. opErr is the result
+// of the operation being recorded, if it failed.
+func (l *Logger) Record(action, target string, docs int64, opErr error) error {
+	entry := Entry{
+		Time:    time.Now().UTC(),
+		ESUser:  os.Getenv("ES_USER"),
+		Profile: l.profile,
+		Command: l.command,
+		Action:  action,
+		Target:  target,
+		Docs:    docs,
+	}
+	if current, err := user.Current(); err == nil {
+		entry.User = current.Username
+	}
+	entry.Host, _ = os.Hostname()
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := l.appendToFile(line); err != nil {
+		return err
+	}
+	if l.index != "" {
+		return l.indexEntry(line)
+	}
+	return nil
+}
+
+func (l *Logger) appendToFile(line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+func (l *Logger) indexEntry(line []byte) error {
+	resp, err := l.client.Index(l.index, bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("error writing audit entry to %s, status: %s, response body: %s", l.index, resp.Status(), resp.String())
+	}
+	return nil
+}
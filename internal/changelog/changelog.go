@@ -0,0 +1,234 @@
+// Package changelog records every document written by these tools to a
+// changelog index, one compact entry per index, update or delete, so the
+// write history of a single book can be looked up later.
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+)
+
+// EnvIndex names the environment variable holding the changelog index.
+// Nothing is recorded when it is empty.
+const EnvIndex = "SEARCH_GO_CHANGELOG_INDEX"
+
+// The operations an entry records.
+const (
+	OpIndex  = "index"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Entry is one write to one document.
+type Entry struct {
+	Time    time.Time `json:"@timestamp"`
+	Index   string    `json:"index"`
+	DocID   string    `json:"doc_id"`
+	Op      string    `json:"op"`
+	RunID   string    `json:"run_id"`
+	Command string    `json:"command"`
+}
+
+// mapping keeps every field but the time a keyword, so history lookups are
+// exact.
+const mapping = `{
+  "mappings": {
+    "properties": {
+      "@timestamp": {"type": "date"},
+      "index": {"type": "keyword"},
+      "doc_id": {"type": "keyword"},
+      "op": {"type": "keyword"},
+      "run_id": {"type": "keyword"},
+      "command": {"type": "keyword"}
+    }
+  }
+}`
+
+// Writer batches entries into the changelog index. A nil Writer records
+// nothing, so callers don't have to check whether the changelog is on.
+type Writer struct {
+	index   string
+	command string
+	runID   string
+	bulk    esutil.BulkIndexer
+}
+
+// FromEnv returns a writer for the index in SEARCH_GO_CHANGELOG_INDEX, or
+// nil when it isn't set.
+func FromEnv(client *elasticsearch7.Client, command string) (*Writer, error) {
+	return New(client, os.Getenv(EnvIndex), command)
+}
+
+// New returns a writer recording the writes made by one run of command to
+// index, creating the index if it doesn't exist. It returns nil when index
+// is empty.
+func New(client *elasticsearch7.Client, index, command string) (*Writer, error) {
+	if index == "" {
+		return nil, nil
+	}
+	if err := ensureIndex(client, index); err != nil {
+		return nil, err
+	}
+
+	bulk, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:  index,
+		Client: client,
+		OnError: func(ctx context.Context, err error) {
+			log.Printf("error writing to changelog %s: %v", index, err)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{index: index, command: command, runID: newRunID(), bulk: bulk}, nil
+}
+
+// RunID identifies the run every entry of this writer belongs to.
+func (w *Writer) RunID() string {
+	if w == nil {
+		return ""
+	}
+	return w.runID
+}
+
+// Record queues an entry for a write of op to the document id in index. It
+// is safe to call from bulk indexer callbacks.
+func (w *Writer) Record(index, id, op string) {
+	if w == nil {
+		return
+	}
+	line, err := json.Marshal(Entry{
+		Time:    time.Now().UTC(),
+		Index:   index,
+		DocID:   id,
+		Op:      op,
+		RunID:   w.runID,
+		Command: w.command,
+	})
+	if err != nil {
+		log.Printf("error writing to changelog %s: %v", w.index, err)
+		return
+	}
+	err = w.bulk.Add(context.Background(), esutil.BulkIndexerItem{
+		Action: "index",
+		Body:   bytes.NewReader(line),
+		OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+			if err == nil {
+				err = fmt.Errorf("%s: %s", res.Error.Type, res.Error.Reason)
+			}
+			log.Printf("error writing to changelog %s: %v", w.index, err)
+		},
+	})
+	if err != nil {
+		log.Printf("error writing to changelog %s: %v", w.index, err)
+	}
+}
+
+// Close flushes the entries still queued. Entries that couldn't be written
+// are logged rather than failing the run, since the writes they describe
+// have already happened.
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.bulk.Close(context.Background())
+}
+
+// Op returns the operation a bulk item performed: action, except that an
+// upsert that created its document counts as an index.
+func Op(action, result string) string {
+	if action == "create" || action == "update" && result == "created" {
+		return OpIndex
+	}
+	return action
+}
+
+func ensureIndex(client *elasticsearch7.Client, index string) error {
+	resp, err := client.Indices.Exists([]string{index})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = client.Indices.Create(index, client.Indices.Create.WithBody(bytes.NewReader([]byte(mapping))))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Another run may have created it in the meantime
+	if resp.IsError() && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("error creating changelog index %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+	return nil
+}
+
+// newRunID returns a sortable, unique id for a run, e.g.
+// 20240102T150405-9f86d081.
+func newRunID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Entries returns the changelog entries of the document id, oldest first.
+// With index set, only writes to that index are returned.
+func Entries(client *elasticsearch7.Client, changelogIndex, index, id string, size int) ([]Entry, error) {
+	filters := []interface{}{
+		map[string]interface{}{"term": map[string]string{"doc_id": id}},
+	}
+	if index != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"index": index}})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"size":  size,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filters}},
+		"sort":  []interface{}{map[string]string{"@timestamp": "asc"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Search(
+		client.Search.WithIndex(changelogIndex),
+		client.Search.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("changelog index %s does not exist", changelogIndex)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("error searching changelog %s, status: %s, response body: %s", changelogIndex, resp.Status(), resp.String())
+	}
+
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				Source Entry `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(response.Hits.Hits))
+	for i, hit := range response.Hits.Hits {
+		entries[i] = hit.Source
+	}
+	return entries, nil
+}
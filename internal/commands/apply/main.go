@@ -0,0 +1,329 @@
+// Package apply creates or updates an index and its aliases from an index
+// spec, then loads the spec's source into it.
+package apply
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/plugin/transform"
+	"github.com/nickcanz/search-go/internal/plugin/wasm"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/telemetry"
+	"github.com/nickcanz/search-go/internal/upsert"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// Main runs apply with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	pluginsDirPtr := flags.String("plugins-dir", "", "Directory to find the spec's transforms in, needed when it lists any")
+	wasmMemoryPtr := flags.Int("wasm-memory", 64, "Memory limit in megabytes for each .wasm transform")
+	wasmTimeoutPtr := flags.Duration("wasm-timeout", time.Second, "Time limit for a .wasm transform to transform one document")
+	datasetPtr := flags.String("dataset", "", "Apply the spec of this dataset from the catalog")
+	planPtr := flags.Bool("plan", false, "Only print what applying the spec would change")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+	start := time.Now()
+
+	specPath := "indexspec.yaml"
+	if flags.NArg() > 0 {
+		specPath = flags.Arg(0)
+	}
+	var spec *indexspec.Spec
+	var err error
+	if *datasetPtr != "" {
+		spec, err = indexspec.LoadDataset(*datasetPtr)
+	} else {
+		spec, err = indexspec.Load(specPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("apply")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	changes, err := plan(client, spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if *planPtr {
+		return
+	}
+
+	transforms, err := transform.Named(*pluginsDirPtr, spec.Transforms, wasm.Limits{
+		MemoryMB: *wasmMemoryPtr,
+		Timeout:  *wasmTimeoutPtr,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transforms.Close()
+
+	if err := ensureIndex(client, spec); err != nil {
+		log.Fatal(err)
+	}
+
+	docs, err := loadDocuments(client, spec, transforms)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("indexed %d documents into %s\n", docs, spec.Index)
+
+	if err := syncAliases(client, spec); err != nil {
+		log.Fatal(err)
+	}
+
+	telemetry.Report("apply", flags, docs, time.Since(start))
+}
+
+// ensureIndex creates the spec's index, or adds the spec's mappings to it
+// if it already exists. Settings are only applied when the index is
+// created, since most of them cannot change on an open index.
+func ensureIndex(client *elasticsearch7.Client, spec *indexspec.Spec) error {
+	resp, err := client.Indices.Exists([]string{spec.Index})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		body, err := spec.IndexBody()
+		if err != nil {
+			return err
+		}
+		resp, err := client.Indices.Create(spec.Index, client.Indices.Create.WithBody(bytes.NewReader(body)))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.IsError() {
+			return fmt.Errorf("error creating %s, status: %s, response body: %s", spec.Index, resp.Status(), resp.String())
+		}
+		fmt.Printf("created index %s\n", spec.Index)
+		return nil
+	}
+	if resp.IsError() {
+		return fmt.Errorf("error checking for %s, status: %s", spec.Index, resp.Status())
+	}
+
+	if len(spec.Mappings) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(spec.Mappings)
+	if err != nil {
+		return err
+	}
+	resp, err = client.Indices.PutMapping(bytes.NewReader(body), client.Indices.PutMapping.WithIndex(spec.Index))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error updating the mappings of %s, status: %s, response body: %s", spec.Index, resp.Status(), resp.String())
+	}
+	return nil
+}
+
+// loadDocuments indexes every document in the spec's source file after
+// running it through the transforms, and returns how many were indexed.
+func loadDocuments(client *elasticsearch7.Client, spec *indexspec.Spec, transforms *transform.Chain) (int64, error) {
+	file, err := os.Open(spec.Source.File)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	bulkIndexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Index:  spec.Index,
+		Client: client,
+		OnError: func(ctx context.Context, err error) {
+			log.Fatalf("bulkindexer OnError %#v", err)
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	suggestTitles := suggestsTitles(spec)
+	reader := bufio.NewReader(file)
+	for {
+		readBytes, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("error reading readBytes: %w", err)
+		}
+		readBytes = bytes.TrimSpace(readBytes)
+		if len(readBytes) > 0 {
+			if err := addDocument(bulkIndexer, spec, transforms, suggestTitles, readBytes); err != nil {
+				return 0, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := bulkIndexer.Close(context.Background()); err != nil {
+		return 0, err
+	}
+	stats := bulkIndexer.Stats()
+	if stats.NumFailed > 0 {
+		return 0, fmt.Errorf("%d documents failed to index", stats.NumFailed)
+	}
+	// Documents with an id are upserted, which counts them as updates
+	return int64(stats.NumIndexed + stats.NumUpdated), nil
+}
+
+func addDocument(bulkIndexer esutil.BulkIndexer, spec *indexspec.Spec, transforms *transform.Chain, suggestTitles bool, document []byte) error {
+	if !json.Valid(document) {
+		return fmt.Errorf("invalid JSON in %s: %s", spec.Source.File, document)
+	}
+	document, err := stamp(document, time.Now().UTC(), suggestTitles)
+	if err != nil {
+		return fmt.Errorf("error stamping a document in %s: %w", spec.Source.File, err)
+	}
+	document, err = transforms.Apply(document)
+	if err != nil {
+		return err
+	}
+	if document == nil {
+		return nil
+	}
+
+	id, err := spec.ID.DocumentID(document)
+	if err != nil {
+		return err
+	}
+	document, err = hash(document)
+	if err != nil {
+		return err
+	}
+
+	// Documents with an id are upserted the way load-books writes them,
+	// so their earlier timestamps are kept
+	action, body := "index", document
+	if id != "" {
+		action = "update"
+		body, err = upsert.Body(document)
+		if err != nil {
+			return err
+		}
+	}
+	return bulkIndexer.Add(
+		context.Background(),
+		esutil.BulkIndexerItem{
+			Action:     action,
+			DocumentID: id,
+			Body:       bytes.NewReader(body),
+			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				if err != nil {
+					log.Printf("ERROR: %s", err)
+				} else {
+					log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
+				}
+			},
+		})
+}
+
+// aliasTargets returns the indices alias currently points to.
+func aliasTargets(client *elasticsearch7.Client, alias string) ([]string, error) {
+	resp, err := client.Indices.GetAlias(client.Indices.GetAlias.WithName(alias))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting alias %s, status: %s, response body: %s", alias, resp.Status(), resp.String())
+	}
+
+	var aliases map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		return nil, err
+	}
+	var indices []string
+	for index := range aliases {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// syncAliases points each of the spec's aliases at its index and nothing
+// else, in a single atomic request.
+func syncAliases(client *elasticsearch7.Client, spec *indexspec.Spec) error {
+	var actions []map[string]interface{}
+	for _, alias := range spec.Aliases {
+		targets, err := aliasTargets(client, alias)
+		if err != nil {
+			return err
+		}
+
+		current := false
+		for _, target := range targets {
+			if target == spec.Index {
+				current = true
+				continue
+			}
+			actions = append(actions, map[string]interface{}{
+				"remove": map[string]string{"index": target, "alias": alias},
+			})
+		}
+		if !current {
+			actions = append(actions, map[string]interface{}{
+				"add": map[string]string{"index": spec.Index, "alias": alias},
+			})
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Indices.UpdateAliases(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error updating aliases, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+	fmt.Printf("pointed %d aliases at %s\n", len(spec.Aliases), spec.Index)
+	return nil
+}
@@ -0,0 +1,145 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/internal/indexspec"
+)
+
+// plan compares the spec with the live cluster and returns what applying
+// it would change, one line per change. Lines start with "+" for what
+// apply would create, "~" for what it would change, "-" for what it would
+// remove, and "!" for drift it cannot fix, such as a changed field type.
+func plan(client *elasticsearch7.Client, spec *indexspec.Spec) ([]string, error) {
+	var changes []string
+
+	resp, err := client.Indices.Exists([]string{spec.Index})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		changes = append(changes, fmt.Sprintf("+ create index %s", spec.Index))
+	} else if resp.IsError() {
+		return nil, fmt.Errorf("error checking for %s, status: %s", spec.Index, resp.Status())
+	} else {
+		mappingChanges, err := planMappings(client, spec)
+		if err != nil {
+			return nil, err
+		}
+		settingsChanges, err := planSettings(client, spec)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, mappingChanges...)
+		changes = append(changes, settingsChanges...)
+	}
+
+	changes = append(changes, fmt.Sprintf("~ index documents from %s", spec.Source.File))
+
+	for _, alias := range spec.Aliases {
+		targets, err := aliasTargets(client, alias)
+		if err != nil {
+			return nil, err
+		}
+		current := false
+		for _, target := range targets {
+			if target == spec.Index {
+				current = true
+				continue
+			}
+			changes = append(changes, fmt.Sprintf("- remove alias %s from %s", alias, target))
+		}
+		if !current {
+			changes = append(changes, fmt.Sprintf("+ add alias %s to %s", alias, spec.Index))
+		}
+	}
+	return changes, nil
+}
+
+func planMappings(client *elasticsearch7.Client, spec *indexspec.Spec) ([]string, error) {
+	resp, err := client.Indices.GetMapping(client.Indices.GetMapping.WithIndex(spec.Index))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting the mappings of %s, status: %s, response body: %s", spec.Index, resp.Status(), resp.String())
+	}
+
+	var mappingResponse map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&mappingResponse); err != nil {
+		return nil, err
+	}
+
+	live := indexspec.Fields(mappingResponse[spec.Index].Mappings)
+	declared := indexspec.Fields(spec.Mappings)
+
+	var changes []string
+	for _, field := range sortedKeys(declared) {
+		liveDefinition, ok := live[field]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("+ add field %s %s", field, declared[field]))
+		case liveDefinition != declared[field]:
+			changes = append(changes, fmt.Sprintf("! field %s is %s, spec has %s", field, liveDefinition, declared[field]))
+		}
+	}
+	for _, field := range sortedKeys(live) {
+		if _, ok := declared[field]; !ok {
+			changes = append(changes, fmt.Sprintf("! field %s %s is not in the spec", field, live[field]))
+		}
+	}
+	return changes, nil
+}
+
+func planSettings(client *elasticsearch7.Client, spec *indexspec.Spec) ([]string, error) {
+	resp, err := client.Indices.GetSettings(
+		client.Indices.GetSettings.WithIndex(spec.Index),
+		client.Indices.GetSettings.WithFlatSettings(true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting the settings of %s, status: %s, response body: %s", spec.Index, resp.Status(), resp.String())
+	}
+
+	var settingsResponse map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&settingsResponse); err != nil {
+		return nil, err
+	}
+
+	live := indexspec.FlatSettingValues(settingsResponse[spec.Index].Settings)
+	declared := spec.FlatSettings()
+
+	var changes []string
+	for _, setting := range sortedKeys(declared) {
+		liveValue, ok := live[setting]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("! setting %s is unset, spec has %s", setting, declared[setting]))
+		case liveValue != declared[setting]:
+			changes = append(changes, fmt.Sprintf("! setting %s is %s, spec has %s", setting, liveValue, declared[setting]))
+		}
+	}
+	return changes, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
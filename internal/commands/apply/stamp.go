@@ -0,0 +1,75 @@
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/contenthash"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/suggest"
+)
+
+// timestamps are the fields load-books stamps every document with. They
+// change on every load, so they aren't part of the content hash.
+var timestamps = []string{"indexed_at", "created_at", "updated_at"}
+
+// stamp sets a document's timestamps to now, and builds its title_suggest
+// when suggestTitles is set, the way load-books does before running the
+// transforms. Reapplying a spec keeps the created_at, and the updated_at of
+// unchanged documents, that were indexed before, since documents with an
+// id are upserted.
+func stamp(document []byte, now time.Time, suggestTitles bool) ([]byte, error) {
+	// Numbers are kept as written, rather than rounded through float64
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	for _, field := range timestamps {
+		fields[field] = now
+	}
+	if suggestTitles {
+		title, _ := fields["title"].(string)
+		if suggestion := suggest.Title(title, ratingsCount(fields["ratings_count"])); suggestion != nil {
+			fields[suggest.Field] = suggestion
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// ratingsCount reads a ratings count written as a number or, like the
+// goodreads dump does, as a string. Anything else counts as no ratings.
+func ratingsCount(value interface{}) int {
+	var text string
+	switch value := value.(type) {
+	case json.Number:
+		text = value.String()
+	case string:
+		text = strings.TrimSpace(value)
+	}
+	count, err := strconv.Atoi(text)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// suggestsTitles reports whether a spec maps title_suggest as a completion
+// field, and so wants it built.
+func suggestsTitles(spec *indexspec.Spec) bool {
+	var definition struct {
+		Type string `json:"type"`
+	}
+	field, ok := indexspec.Fields(spec.Mappings)[suggest.Field]
+	return ok && json.Unmarshal([]byte(field), &definition) == nil && definition.Type == "completion"
+}
+
+// hash stores the content hash of a transformed document in it.
+func hash(document []byte) ([]byte, error) {
+	document, _, err := contenthash.Add(document, timestamps...)
+	return document, err
+}
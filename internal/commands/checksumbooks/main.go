@@ -0,0 +1,259 @@
+// Package checksumbooks compares the books in an index with a source file
+// by content hash, to find books missing from either or changed since.
+package checksumbooks
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/commands/loadbooks"
+	"github.com/nickcanz/search-go/internal/contenthash"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+type ScrollResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			ID     string          `json:"_id"`
+			Source json.RawMessage `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Checksum summarizes a set of documents independently of the order they
+// were read or indexed in.
+type Checksum struct {
+	Count   int
+	IDs     string
+	Content string
+}
+
+// Main runs checksum-books with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("checksum-books", flag.ExitOnError)
+	indexPtr := flags.String("index", "", "Index to checksum")
+	filePtr := flags.String("file", "", "Source file to checksum")
+	datasetPtr := flags.String("dataset", "", "Checksum this dataset's index and source file from the catalog, unless -index or -file is given")
+	authorsFilePtr := flags.String("authors-file", "", "Fill in author names from this goodreads_book_authors.json file, as the load did")
+	genresFilePtr := flags.String("genres-file", "", "Fill in genres from this goodreads_book_genres_initial.json file, as the load did")
+	rawPtr := flags.Bool("raw", false, "Hash each line of -file as it is, as apply indexes a dataset's source, rather than as the book load-books indexes for it. On with -dataset")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	if *datasetPtr != "" {
+		spec, err := indexspec.LoadDataset(*datasetPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *indexPtr == "" {
+			*indexPtr = spec.SearchIndex()
+		}
+		if *filePtr == "" {
+			*filePtr = spec.Source.File
+		}
+		*rawPtr = true
+	}
+
+	if *indexPtr == "" && *filePtr == "" {
+		log.Fatalf("No -index, -file or -dataset provided to checksum")
+	}
+
+	var fileSum, indexSum *Checksum
+
+	if *filePtr != "" {
+		hashLine := hashBook
+		if !*rawPtr {
+			lookups, err := loadbooks.OpenLookups(*authorsFilePtr, *genresFilePtr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			hashLine = func(line []byte) (string, error) {
+				return hashLoadedBook(line, lookups)
+			}
+		}
+		sum, err := checksumFile(*filePtr, hashLine)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("file %s: %d documents, content checksum %s\n", *filePtr, sum.Count, sum.Content)
+		fileSum = sum
+	}
+
+	if *indexPtr != "" {
+		err := godotenv.Load()
+		if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+			log.Fatal("Error loading .env file")
+		}
+		if *profilePtr != "" {
+			if _, err := profile.Activate(*profilePtr); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		client, err := esclient.NewClientFromEnv("checksum-books")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sum, err := checksumIndex(client, *indexPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("index %s: %d documents, id checksum %s, content checksum %s\n", *indexPtr, sum.Count, sum.IDs, sum.Content)
+		indexSum = sum
+	}
+
+	if fileSum != nil && indexSum != nil {
+		if fileSum.Count != indexSum.Count || fileSum.Content != indexSum.Content {
+			fmt.Println("MISMATCH: index content does not match source file")
+			os.Exit(1)
+		}
+		fmt.Println("OK: index content matches source file")
+	}
+}
+
+// loadFields are added to every book by load-books, so they are in the
+// indexed _source but not in the source file. _content_hash is left out as
+// well by contenthash.
+var loadFields = []string{"indexed_at", "created_at", "updated_at", "title_suggest"}
+
+// hashBook hashes a book's JSON, either an indexed _source or a document
+// built from the source file, in a canonical form with sorted keys and numbers as
+// written, leaving out the fields load-books adds.
+func hashBook(document []byte) (string, error) {
+	_, hash, err := contenthash.Add(document, loadFields...)
+	return hash, err
+}
+
+// hashLoadedBook hashes a line of a goodreads file as the document
+// load-books indexes for it, so it matches the book's _source.
+func hashLoadedBook(line []byte, lookups *loadbooks.Lookups) (string, error) {
+	book, err := loadbooks.ReadBook(line, lookups)
+	if err != nil {
+		return "", err
+	}
+	document, err := book.Document(time.Time{})
+	if err != nil {
+		return "", err
+	}
+	return hashBook(document)
+}
+
+// combine hashes a list of values after sorting them, so that the result
+// does not depend on the order the values were collected in.
+func combine(values []string) string {
+	sort.Strings(values)
+	hash := sha256.New()
+	for _, value := range values {
+		io.WriteString(hash, value)
+		io.WriteString(hash, "\n")
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// checksumFile checksums the lines of the file at path, hashing each one
+// with hashLine.
+func checksumFile(path string, hashLine func(line []byte) (string, error)) (*Checksum, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	var hashes []string
+	line := 0
+	for {
+		readBytes, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading readBytes: %w", err)
+		}
+		line++
+		// Blank lines are skipped the way load-books skips them
+		if len(bytes.TrimSpace(readBytes)) > 0 {
+			hash, err := hashLine(readBytes)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling json on line %d: %w", line, err)
+			}
+			hashes = append(hashes, hash)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return &Checksum{Count: len(hashes), Content: combine(hashes)}, nil
+}
+
+func checksumIndex(client *elasticsearch7.Client, index string) (*Checksum, error) {
+	resp, err := client.Search(
+		client.Search.WithIndex(index),
+		client.Search.WithSort("_doc"),
+		client.Search.WithSize(1000),
+		client.Search.WithScroll(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, hashes []string
+	var scrollID string
+	for {
+		if resp.IsError() {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error scrolling, status: %s, response body: %s", resp.Status(), resp.String())
+		}
+
+		var scrollResponse ScrollResponse
+		err = json.NewDecoder(resp.Body).Decode(&scrollResponse)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		scrollID = scrollResponse.ScrollID
+
+		if len(scrollResponse.Hits.Hits) == 0 {
+			break
+		}
+
+		for _, hit := range scrollResponse.Hits.Hits {
+			hash, err := hashBook(hit.Source)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling _source of %s: %w", hit.ID, err)
+			}
+			ids = append(ids, hit.ID)
+			hashes = append(hashes, hash)
+		}
+
+		resp, err = client.Scroll(
+			client.Scroll.WithScrollID(scrollID),
+			client.Scroll.WithScroll(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if scrollID != "" {
+		resp, err := client.ClearScroll(client.ClearScroll.WithScrollID(scrollID))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	return &Checksum{Count: len(hashes), IDs: combine(ids), Content: combine(hashes)}, nil
+}
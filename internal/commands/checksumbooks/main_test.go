@@ -0,0 +1,72 @@
+package checksumbooks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// goodreadsLine is a line of goodreads_books.json as it comes, with the
+// fields load-books doesn't index and ratings and years as strings.
+const goodreadsLine = `{"isbn": "0312853122", "text_reviews_count": "1", "series": [], "country_code": "US", "language_code": "", "popular_shelves": [{"count": "3", "name": "to-read"}, {"count": "1", "name": "p"}], "asin": "", "is_ebook": "false", "average_rating": "4.00", "kindle_asin": "", "similar_books": [], "description": "", "format": "Paperback", "link": "https://www.goodreads.com/book/show/5333265-w-c-fields", "authors": [{"author_id": "604031", "role": ""}], "publisher": "St. Martin's Press", "num_pages": "256", "publication_day": "1", "isbn13": "9780312853129", "publication_month": "9", "edition_information": "", "publication_year": "1984", "url": "https://www.goodreads.com/book/show/5333265-w-c-fields", "image_url": "https://images.gr-assets.com/books/1310220028m/5333265.jpg", "book_id": "5333265", "ratings_count": "3", "work_id": "5400751", "title": "W.C. Fields: A Life on Film", "title_without_series": "W.C. Fields: A Life on Film"}`
+
+// loadedSource is the _source load-books indexes for goodreadsLine.
+const loadedSource = `{"title":"W.C. Fields: A Life on Film","url":"https://www.goodreads.com/book/show/5333265-w-c-fields","description":"","book_id":"5333265","isbn":"0312853122","isbn13":"9780312853129","authors":[{"author_id":"604031"}],"average_rating":4,"publication_year":1984,"ratings_count":3,"title_suggest":{"input":["W.C. Fields: A Life on Film"],"weight":3},"indexed_at":"2024-03-01T12:00:00Z","created_at":"2024-01-01T08:30:00Z","updated_at":"2024-03-01T12:00:00Z","_content_hash":"0123"}`
+
+func TestHashLoadedBookMatchesSource(t *testing.T) {
+	fileHash, err := hashLoadedBook([]byte(goodreadsLine), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexHash, err := hashBook([]byte(loadedSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileHash != indexHash {
+		t.Errorf("the file line hashes to %s and its _source to %s, want them equal", fileHash, indexHash)
+	}
+
+	// The raw line keeps fields load-books drops, so it can't match
+	rawHash, err := hashBook([]byte(goodreadsLine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rawHash == indexHash {
+		t.Error("the raw line hashed the same as the loaded _source")
+	}
+}
+
+func TestChecksumFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+	other := `{"title":"Another","url":"https://www.goodreads.com/book/show/1","ratings_count":"12"}`
+	if err := os.WriteFile(path, []byte(goodreadsLine+"\n\n"+other), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := checksumFile(path, hashBook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Count != 2 {
+		t.Errorf("counted %d books, want 2 with the blank line skipped", sum.Count)
+	}
+
+	otherHash, err := hashLoadedBook([]byte(other), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexHash, err := hashBook([]byte(loadedSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := checksumFile(path, func(line []byte) (string, error) {
+		return hashLoadedBook(line, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The index's checksum doesn't depend on the order books were read in
+	if want := combine([]string{otherHash, indexHash}); loaded.Content != want {
+		t.Errorf("content checksum %s, want %s", loaded.Content, want)
+	}
+}
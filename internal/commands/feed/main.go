@@ -0,0 +1,165 @@
+// Package feed writes an Atom feed of the books most recently added to an
+// index.
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/tombstone"
+	"github.com/nickcanz/search-go/pkg/esclient"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+type Book struct {
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	IndexedAt   time.Time `json:"indexed_at"`
+}
+
+type RecentBooksResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID     string `json:"_id"`
+			Source Book   `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Feed is an Atom feed document.
+type Feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    *Link    `xml:"link,omitempty"`
+	Entries []Entry  `xml:"entry"`
+}
+
+type Entry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Link    Link   `xml:"link"`
+	Summary string `xml:"summary,omitempty"`
+}
+
+type Link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// Main runs feed with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("feed", flag.ExitOnError)
+	indexPtr := flags.String("index", "books", "Index to list new books from")
+	sizePtr := flags.Int("size", 50, "Number of books in the feed")
+	titlePtr := flags.String("title", "New books", "Title of the feed")
+	feedURLPtr := flags.String("feed-url", "", "URL the feed will be served from, used as its id and self link")
+	outputPtr := flags.String("output", "-", "File to write the feed to, - writes to stdout")
+	sincePtr := flags.Duration("since", 0, "Only list books indexed within this long, e.g. 24h (disabled when 0)")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("feed")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"size":  *sizePtr,
+		"query": tombstone.Live(recentQuery(*sincePtr)),
+		"sort":  []interface{}{map[string]string{"indexed_at": "desc"}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := client.Search(
+		client.Search.WithIndex(*indexPtr),
+		client.Search.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		log.Fatalf("Error querying, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var recentBooks RecentBooksResponse
+	if err := json.NewDecoder(resp.Body).Decode(&recentBooks); err != nil {
+		log.Fatal(err)
+	}
+
+	feed := Feed{
+		ID:      *feedURLPtr,
+		Title:   *titlePtr,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+	if feed.ID == "" {
+		feed.ID = "urn:search-go:" + *indexPtr
+	} else {
+		feed.Link = &Link{Href: *feedURLPtr, Rel: "self"}
+	}
+	for i, hit := range recentBooks.Hits.Hits {
+		book := hit.Source
+		if i == 0 {
+			// A feed was last updated when its newest entry was
+			feed.Updated = book.IndexedAt.Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, Entry{
+			ID:      book.Url,
+			Title:   book.Title,
+			Updated: book.IndexedAt.Format(time.RFC3339),
+			Link:    Link{Href: book.Url},
+			Summary: book.Description,
+		})
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputPtr != "-" {
+		file, err := os.Create(*outputPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	io.WriteString(output, xml.Header)
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Fatal(err)
+	}
+	io.WriteString(output, "\n")
+}
+
+// recentQuery matches books with a load time, indexed within since when
+// it is set.
+func recentQuery(since time.Duration) query.Query {
+	if since <= 0 {
+		return query.Exists("indexed_at")
+	}
+	return query.Range("indexed_at").Gte(time.Now().Add(-since).UTC().Format(time.RFC3339))
+}
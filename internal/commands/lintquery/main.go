@@ -0,0 +1,136 @@
+// Package lintquery checks a search body for mistakes, and validates it
+// against an index with the validate API.
+package lintquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+type ValidateResponse struct {
+	Valid        bool   `json:"valid"`
+	Error        string `json:"error"`
+	Explanations []struct {
+		Index       string `json:"index"`
+		Valid       bool   `json:"valid"`
+		Explanation string `json:"explanation"`
+		Error       string `json:"error"`
+	} `json:"explanations"`
+}
+
+// Main runs lint-query with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lint-query", flag.ExitOnError)
+	filePtr := flags.String("file", "-", "File containing the query JSON, - reads from stdin")
+	indexPtr := flags.String("index", "books", "Index to validate the query against")
+	datasetPtr := flags.String("dataset", "", "Use the index of this dataset from the catalog instead of -index")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	if *datasetPtr != "" {
+		spec, err := indexspec.LoadDataset(*datasetPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*indexPtr = spec.SearchIndex()
+	}
+
+	var input io.Reader = os.Stdin
+	if *filePtr != "-" {
+		file, err := os.Open(*filePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	queryBytes, err := io.ReadAll(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(queryBytes, &body); err != nil {
+		fmt.Printf("INVALID: query is not a JSON object: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Accept either a full search body or just the query clause
+	query, ok := body["query"]
+	if ok {
+		for key := range body {
+			if key != "query" {
+				fmt.Printf("note: only the query clause is validated, skipping %q\n", key)
+			}
+		}
+	} else {
+		query = queryBytes
+	}
+
+	validateBody, err := json.Marshal(map[string]json.RawMessage{"query": query})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("lint-query")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := client.Indices.ValidateQuery(
+		client.Indices.ValidateQuery.WithIndex(*indexPtr),
+		client.Indices.ValidateQuery.WithExplain(true),
+		client.Indices.ValidateQuery.WithBody(bytes.NewReader(validateBody)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		log.Fatalf("Error validating, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var validateResponse ValidateResponse
+	err = json.NewDecoder(resp.Body).Decode(&validateResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, explanation := range validateResponse.Explanations {
+		if explanation.Valid {
+			fmt.Printf("%s: %s\n", explanation.Index, explanation.Explanation)
+		} else {
+			fmt.Printf("%s: %s\n", explanation.Index, explanation.Error)
+		}
+	}
+
+	if !validateResponse.Valid {
+		if validateResponse.Error != "" {
+			fmt.Println(validateResponse.Error)
+		}
+		fmt.Println("INVALID")
+		os.Exit(1)
+	}
+	fmt.Println("VALID")
+}
@@ -0,0 +1,154 @@
+package loadbooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/internal/audit"
+)
+
+// versionedIndex returns the name of a new index for alias, e.g.
+// books-v20240102150405.
+func versionedIndex(alias string, now time.Time) string {
+	return fmt.Sprintf("%s-v%s", alias, now.UTC().Format("20060102150405"))
+}
+
+// aliasTargets returns the indices alias currently points to.
+func aliasTargets(client *elasticsearch7.Client, alias string) ([]string, error) {
+	resp, err := client.Indices.GetAlias(client.Indices.GetAlias.WithName(alias))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting alias %s, status: %s, response body: %s", alias, resp.Status(), resp.String())
+	}
+
+	var aliases map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		return nil, err
+	}
+	var indices []string
+	for index := range aliases {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// checkAlias makes sure alias can be pointed at a new index, which it
+// can't be if an index already has its name.
+func checkAlias(client *elasticsearch7.Client, alias string) error {
+	targets, err := aliasTargets(client, alias)
+	if err != nil || len(targets) > 0 {
+		return err
+	}
+	resp, err := client.Indices.Exists([]string{alias})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("%s is an index, not an alias, reindex it into a versioned index or delete it before loading with -alias %s", alias, alias)
+	}
+	return nil
+}
+
+// swapAlias points alias at index instead of the old indices in a single
+// request, so searches see either the old books or the new ones and never
+// a mix.
+func swapAlias(client *elasticsearch7.Client, alias, index string, old []string) error {
+	actions := []map[string]interface{}{
+		{"add": map[string]string{"index": index, "alias": alias}},
+	}
+	for _, oldIndex := range old {
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]string{"index": oldIndex, "alias": alias},
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Indices.UpdateAliases(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error moving alias %s to %s, status: %s, response body: %s", alias, index, resp.Status(), resp.String())
+	}
+	log.Printf("pointed alias %s at %s", alias, index)
+	return nil
+}
+
+// moveAlias points alias at index, and with deleteOld deletes the indices
+// it pointed to before.
+func moveAlias(client *elasticsearch7.Client, alias, index string, deleteOld bool, profileName string) error {
+	old, err := aliasTargets(client, alias)
+	if err != nil {
+		return err
+	}
+	if err := swapAlias(client, alias, index, old); err != nil {
+		return err
+	}
+	if !deleteOld || len(old) == 0 {
+		return nil
+	}
+
+	auditLog, err := audit.New(client, "load-books", profileName)
+	if err != nil {
+		return err
+	}
+	return deleteIndices(client, old, auditLog)
+}
+
+// deleteIndices deletes the indices an alias was moved away from,
+// recording each deletion in the audit log.
+func deleteIndices(client *elasticsearch7.Client, indices []string, auditLog *audit.Logger) error {
+	for _, index := range indices {
+		var docs int64
+		countResp, err := client.Count(client.Count.WithIndex(index))
+		if err == nil {
+			var countResponse struct {
+				Count int64 `json:"count"`
+			}
+			if !countResp.IsError() {
+				json.NewDecoder(countResp.Body).Decode(&countResponse)
+				docs = countResponse.Count
+			}
+			countResp.Body.Close()
+		}
+
+		var deleteErr error
+		resp, err := client.Indices.Delete([]string{index})
+		if err != nil {
+			deleteErr = err
+		} else {
+			if resp.IsError() {
+				deleteErr = fmt.Errorf("error deleting %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+			}
+			resp.Body.Close()
+		}
+
+		if err := auditLog.Record("delete", index, docs, deleteErr); err != nil {
+			return err
+		}
+		if deleteErr != nil {
+			return deleteErr
+		}
+		log.Printf("deleted old index %s with %d docs", index, docs)
+	}
+	return nil
+}
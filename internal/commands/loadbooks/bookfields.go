@@ -0,0 +1,221 @@
+package loadbooks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/suggest"
+)
+
+// Author is one of a book's authors. The goodreads dump only has the
+// author's id, Name is filled in from -authors-file.
+type Author struct {
+	AuthorID string `json:"author_id"`
+	Role     string `json:"role,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// looseFloat is a number the goodreads dump writes as a string, e.g.
+// "average_rating": "4.01". It is indexed as a number, or as null, which
+// Elasticsearch treats as missing, when the dump has an empty string.
+type looseFloat struct {
+	value float64
+	set   bool
+}
+
+func (f *looseFloat) UnmarshalJSON(data []byte) error {
+	text, err := looseNumber(data)
+	if err != nil || text == "" {
+		return err
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("%s is not a number", data)
+	}
+	*f = looseFloat{value: value, set: true}
+	return nil
+}
+
+func (f looseFloat) MarshalJSON() ([]byte, error) {
+	if !f.set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.value)
+}
+
+// looseInt is looseFloat for whole numbers, e.g. "publication_year": "2011".
+type looseInt struct {
+	value int
+	set   bool
+}
+
+func (i *looseInt) UnmarshalJSON(data []byte) error {
+	text, err := looseNumber(data)
+	if err != nil || text == "" {
+		return err
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return fmt.Errorf("%s is not a whole number", data)
+	}
+	*i = looseInt{value: value, set: true}
+	return nil
+}
+
+func (i looseInt) MarshalJSON() ([]byte, error) {
+	if !i.set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(i.value)
+}
+
+// looseNumber returns the text of a JSON number or of a string holding one,
+// or "" for null and the empty string.
+func looseNumber(data []byte) (string, error) {
+	if bytes.Equal(data, []byte("null")) {
+		return "", nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(text), nil
+	}
+	return string(data), nil
+}
+
+// Lookups fill in what the goodreads dump keeps in separate files: author
+// names, keyed by author id, and genres, keyed by book id. Either map is
+// nil when its file wasn't given.
+type Lookups struct {
+	authors map[string]string
+	genres  map[string][]string
+}
+
+// OpenLookups reads the goodreads_book_authors.json and
+// goodreads_book_genres_initial.json files given with -authors-file and
+// -genres-file. Either path can be empty.
+func OpenLookups(authorsPath, genresPath string) (*Lookups, error) {
+	lookups := &Lookups{}
+	if authorsPath != "" {
+		lookups.authors = map[string]string{}
+		err := readLines(authorsPath, func(line []byte) error {
+			var author struct {
+				AuthorID string `json:"author_id"`
+				Name     string `json:"name"`
+			}
+			if err := json.Unmarshal(line, &author); err != nil {
+				return err
+			}
+			lookups.authors[author.AuthorID] = author.Name
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if genresPath != "" {
+		lookups.genres = map[string][]string{}
+		err := readLines(genresPath, func(line []byte) error {
+			var book struct {
+				BookID string         `json:"book_id"`
+				Genres map[string]int `json:"genres"`
+			}
+			if err := json.Unmarshal(line, &book); err != nil {
+				return err
+			}
+			lookups.genres[book.BookID] = splitGenres(book.Genres)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lookups, nil
+}
+
+// splitGenres turns the genre groups of the goodreads dump, like
+// "fantasy, paranormal", into single sorted genres.
+func splitGenres(groups map[string]int) []string {
+	seen := map[string]bool{}
+	var genres []string
+	for group := range groups {
+		for _, genre := range strings.Split(group, ",") {
+			genre = strings.TrimSpace(genre)
+			if genre != "" && !seen[genre] {
+				seen[genre] = true
+				genres = append(genres, genre)
+			}
+		}
+	}
+	sort.Strings(genres)
+	return genres
+}
+
+// Fill adds author names and genres to book, keeping any the book already
+// has.
+func (l *Lookups) Fill(book *Book) {
+	for i, author := range book.Authors {
+		if author.Name == "" {
+			book.Authors[i].Name = l.authors[author.AuthorID]
+		}
+	}
+	if len(book.Genres) == 0 && book.BookID != "" {
+		book.Genres = l.genres[book.BookID]
+	}
+}
+
+// ReadBook decodes a line of the goodreads dump into the Book load-books
+// indexes for it: fields it doesn't search on are dropped, ratings and
+// years become numbers, author names and genres are filled in from
+// lookups, which may be nil, and title_suggest is built.
+func ReadBook(line []byte, lookups *Lookups) (Book, error) {
+	var book Book
+	if err := json.Unmarshal(line, &book); err != nil {
+		return Book{}, err
+	}
+	if lookups != nil {
+		lookups.Fill(&book)
+	}
+	book.TitleSuggest = suggest.Title(book.Title, book.RatingsCount.value)
+	return book, nil
+}
+
+// Document returns the JSON load-books sends for the book, before any
+// transforms run, with every timestamp set to now.
+func (b Book) Document(now time.Time) ([]byte, error) {
+	b.IndexedAt, b.CreatedAt, b.UpdatedAt = now, now, now
+	return json.Marshal(b)
+}
+
+// readLines calls fn with every non blank line of the newline delimited
+// JSON file at path.
+func readLines(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return fmt.Errorf("error reading line %d of %s: %w", line, path, err)
+		}
+	}
+	return scanner.Err()
+}
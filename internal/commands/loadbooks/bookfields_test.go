@@ -0,0 +1,98 @@
+package loadbooks
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const testBook = `{"title":"The Lord of the Rings","url":"https://www.goodreads.com/book/show/33","description":"One Ring to rule them all","book_id":"33","isbn":"0618640150","average_rating":"4.50","publication_year":"2005","ratings_count":"2000000","authors":[{"author_id":"656983","role":""}],"popular_shelves":[{"count":"10","name":"fantasy"}]}`
+
+func TestBookLooseNumbers(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: testBook, want: `"average_rating":4.5,"publication_year":2005,"ratings_count":2000000`},
+		{line: `{"average_rating":4.5,"publication_year":2005,"ratings_count":12}`, want: `"average_rating":4.5,"publication_year":2005,"ratings_count":12`},
+		{line: `{"average_rating":"","publication_year":" ","ratings_count":null}`, want: `"average_rating":null,"publication_year":null,"ratings_count":null`},
+		{line: `{}`, want: `"average_rating":null,"publication_year":null,"ratings_count":null`},
+	}
+	for _, test := range tests {
+		var book Book
+		if err := json.Unmarshal([]byte(test.line), &book); err != nil {
+			t.Errorf("%s: %v", test.line, err)
+			continue
+		}
+		got, err := json.Marshal(struct {
+			AverageRating   looseFloat `json:"average_rating"`
+			PublicationYear looseInt   `json:"publication_year"`
+			RatingsCount    looseInt   `json:"ratings_count"`
+		}{book.AverageRating, book.PublicationYear, book.RatingsCount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "{" + test.want + "}"; string(got) != want {
+			t.Errorf("%s became %s, want %s", test.line, got, want)
+		}
+	}
+}
+
+func TestBookLooseNumberErrors(t *testing.T) {
+	for _, line := range []string{
+		`{"average_rating":"four"}`,
+		`{"publication_year":"2005.5"}`,
+		`{"ratings_count":true}`,
+		`{"ratings_count":"12`,
+	} {
+		var book Book
+		if err := json.Unmarshal([]byte(line), &book); err == nil {
+			t.Errorf("%s unmarshalled, want an error", line)
+		}
+	}
+}
+
+// FuzzBookLine checks that any line that parses as a book is indexed as
+// valid JSON, whatever the goodreads dump put in it.
+func FuzzBookLine(f *testing.F) {
+	f.Add(testBook)
+	f.Add(`{"title":"","average_rating":"","publication_year":null}`)
+	f.Add(`{"average_rating":1e400}`)
+	f.Add(`{"ratings_count":"-0"}`)
+	f.Add(`{"authors":[{"author_id":1}]}`)
+	f.Fuzz(func(t *testing.T, line string) {
+		var book Book
+		if err := json.Unmarshal([]byte(line), &book); err != nil {
+			return
+		}
+		document, err := json.Marshal(book)
+		if err != nil {
+			// NaN and infinite ratings can't be indexed, and marshalling
+			// is where load-books reports them
+			return
+		}
+		if !json.Valid(document) {
+			t.Fatalf("%q was indexed as invalid JSON %s", line, document)
+		}
+		var again Book
+		if err := json.Unmarshal(document, &again); err != nil {
+			t.Fatalf("%q was indexed as %s, which doesn't parse back: %v", line, document, err)
+		}
+	})
+}
+
+func BenchmarkBookLine(b *testing.B) {
+	line := []byte(testBook)
+	now := time.Now()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	for i := 0; i < b.N; i++ {
+		book, err := ReadBook(line, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := book.Document(now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
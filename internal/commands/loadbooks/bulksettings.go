@@ -0,0 +1,104 @@
+package loadbooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+// bulkSettings are the index settings turned off for the length of a load
+// with -bulk-settings: refreshing makes new segments that a load has no
+// use for, and replicas index every document a second time.
+var bulkSettings = map[string]interface{}{
+	"index.refresh_interval":   "-1",
+	"index.number_of_replicas": "0",
+}
+
+// applyBulkSettings switches index to bulkSettings and returns its earlier
+// values of them, nil for those that were left at the default.
+func applyBulkSettings(client *elasticsearch7.Client, index string) (map[string]interface{}, error) {
+	resp, err := client.Indices.GetSettings(
+		client.Indices.GetSettings.WithIndex(index),
+		client.Indices.GetSettings.WithFlatSettings(true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting the settings of %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+
+	var settingsResponse map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&settingsResponse); err != nil {
+		return nil, err
+	}
+
+	previous := map[string]interface{}{}
+	for setting := range bulkSettings {
+		// A missing setting is restored by setting it to null
+		previous[setting] = settingsResponse[index].Settings[setting]
+	}
+	if err := putSettings(client, index, bulkSettings); err != nil {
+		return nil, err
+	}
+	log.Printf("disabled refresh and replicas on %s for the load", index)
+	return previous, nil
+}
+
+// restoreSettings puts back the settings applyBulkSettings returned.
+func restoreSettings(client *elasticsearch7.Client, index string, previous map[string]interface{}) error {
+	if err := putSettings(client, index, previous); err != nil {
+		return err
+	}
+	log.Printf("restored the refresh interval and replicas of %s", index)
+
+	// Make the load searchable now rather than at the next scheduled refresh
+	resp, err := client.Indices.Refresh(client.Indices.Refresh.WithIndex(index))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error refreshing %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+	return nil
+}
+
+func putSettings(client *elasticsearch7.Client, index string, settings map[string]interface{}) error {
+	body, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Indices.PutSettings(bytes.NewReader(body), client.Indices.PutSettings.WithIndex(index))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error updating the settings of %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+	return nil
+}
+
+// forceMerge merges index down to at most segments segments, waiting until
+// it is done. Merging a freshly loaded index makes searches faster and the
+// index smaller.
+func forceMerge(client *elasticsearch7.Client, index string, segments int) error {
+	log.Printf("force merging %s to %d segments", index, segments)
+	resp, err := client.Indices.Forcemerge(
+		client.Indices.Forcemerge.WithIndex(index),
+		client.Indices.Forcemerge.WithMaxNumSegments(segments))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error force merging %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package loadbooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+type NodeStatsResponse struct {
+	Nodes map[string]struct {
+		Name string `json:"name"`
+		JVM  struct {
+			Mem struct {
+				HeapUsedPercent int `json:"heap_used_percent"`
+			} `json:"mem"`
+		} `json:"jvm"`
+		ThreadPool struct {
+			Write struct {
+				Queue    int   `json:"queue"`
+				Rejected int64 `json:"rejected"`
+			} `json:"write"`
+		} `json:"thread_pool"`
+		IndexingPressure struct {
+			Memory struct {
+				Total struct {
+					CoordinatingRejections int64 `json:"coordinating_rejections"`
+					PrimaryRejections      int64 `json:"primary_rejections"`
+					ReplicaRejections      int64 `json:"replica_rejections"`
+				} `json:"total"`
+			} `json:"memory"`
+		} `json:"indexing_pressure"`
+	} `json:"nodes"`
+}
+
+// clusterThrottle polls node stats and lowers the loader's rate while any
+// node is short on heap or rejecting writes, then raises it again once the
+// cluster recovers.
+type clusterThrottle struct {
+	client         *elasticsearch7.Client
+	limiter        *rateLimiter
+	interval       time.Duration
+	maxHeapPercent int
+
+	rejections map[string]int64
+	peakRate   float64
+}
+
+func newClusterThrottle(client *elasticsearch7.Client, limiter *rateLimiter, interval time.Duration, maxHeapPercent int) *clusterThrottle {
+	return &clusterThrottle{
+		client:         client,
+		limiter:        limiter,
+		interval:       interval,
+		maxHeapPercent: maxHeapPercent,
+		rejections:     map[string]int64{},
+	}
+}
+
+func (t *clusterThrottle) Start() {
+	go func() {
+		lastAdded := docsAdded.Value()
+		for range time.Tick(t.interval) {
+			added := docsAdded.Value()
+			rate := float64(added-lastAdded) / t.interval.Seconds()
+			lastAdded = added
+
+			reason, err := t.pressure()
+			if err != nil {
+				log.Printf("error polling node stats, not adjusting rate: %v", err)
+				continue
+			}
+			t.adjust(rate, reason)
+		}
+	}()
+}
+
+// pressure returns why the cluster is under pressure, or "" when it isn't.
+func (t *clusterThrottle) pressure() (string, error) {
+	resp, err := t.client.Nodes.Stats(
+		t.client.Nodes.Stats.WithMetric("jvm", "thread_pool", "indexing_pressure"))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return "", fmt.Errorf("status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var nodeStats NodeStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&nodeStats); err != nil {
+		return "", err
+	}
+
+	reason := ""
+	for id, node := range nodeStats.Nodes {
+		rejected := node.ThreadPool.Write.Rejected +
+			node.IndexingPressure.Memory.Total.CoordinatingRejections +
+			node.IndexingPressure.Memory.Total.PrimaryRejections +
+			node.IndexingPressure.Memory.Total.ReplicaRejections
+		previous, seen := t.rejections[id]
+		t.rejections[id] = rejected
+
+		switch {
+		case node.JVM.Mem.HeapUsedPercent >= t.maxHeapPercent:
+			reason = fmt.Sprintf("node %s heap at %d%%", node.Name, node.JVM.Mem.HeapUsedPercent)
+		case seen && rejected > previous:
+			reason = fmt.Sprintf("node %s rejected %d writes", node.Name, rejected-previous)
+		}
+	}
+	return reason, nil
+}
+
+func (t *clusterThrottle) adjust(rate float64, reason string) {
+	limit, throttled := t.limiter.LimitFrom("cluster")
+
+	if reason != "" {
+		if rate > t.peakRate {
+			t.peakRate = rate
+		}
+		if !throttled || rate < limit {
+			limit = rate
+		}
+		limit = limit / 2
+		if limit < 1 {
+			limit = 1
+		}
+		log.Printf("Cluster under pressure (%s), slowing to %.0f docs/sec", reason, limit)
+		t.limiter.SetLimit("cluster", limit)
+		return
+	}
+
+	if !throttled {
+		return
+	}
+	limit = limit * 1.5
+	if limit >= t.peakRate {
+		log.Printf("Cluster recovered, removing throttle")
+		t.limiter.ClearLimit("cluster")
+		t.peakRate = 0
+		return
+	}
+	log.Printf("Cluster healthy, speeding up to %.0f docs/sec", limit)
+	t.limiter.SetLimit("cluster", limit)
+}
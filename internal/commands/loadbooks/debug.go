@@ -0,0 +1,31 @@
+package loadbooks
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	_ "net/http/pprof" // Registers the /debug/pprof handlers on http.DefaultServeMux
+)
+
+var (
+	docsRead  = expvar.NewInt("docs_read")
+	bytesRead = expvar.NewInt("bytes_read")
+	docsAdded = expvar.NewInt("docs_added")
+	// docsUnchanged counts books skipped by -skip-unchanged
+	docsUnchanged = expvar.NewInt("docs_unchanged")
+)
+
+// startDebugServer serves the pprof and expvar endpoints on localhost only,
+// so profiles can be captured from a long running load without exposing
+// them to the network.
+func startDebugServer(port int) {
+	addr := fmt.Sprintf("localhost:%d", port)
+	log.Printf("Serving debug endpoints on http://%s/debug/pprof and http://%s/debug/vars", addr, addr)
+
+	go func() {
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Printf("debug server stopped: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,112 @@
+package loadbooks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/nickcanz/search-go/internal/deadletter"
+	"github.com/nickcanz/search-go/internal/faults"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/plugin/transform"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// TestIndexBooksWithFaults loads books into a cluster that is slow,
+// overloaded and drops connections, and checks that every book ends up
+// either indexed or in the dead-letter file.
+func TestIndexBooksWithFaults(t *testing.T) {
+	cluster := faults.NewCluster()
+	defer cluster.Close()
+	transport := faults.New(nil, faults.Config{
+		Latency:         time.Millisecond,
+		LatencyRate:     0.3,
+		TooManyRequests: 0.2,
+		Reset:           0.1,
+		BulkItem:        0.1,
+	})
+	client, err := esclient.NewClient(esclient.Config{
+		URL:       cluster.URL,
+		Transport: transport,
+		// Enough that a request failing every try is too unlikely to
+		// make the test flaky
+		MaxRetries:   20,
+		RetryBackoff: func(int) time.Duration { return time.Millisecond },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bulkIndexers := make([]esutil.BulkIndexer, 2)
+	for i := range bulkIndexers {
+		bulkIndexers[i], err = esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+			Index:      "books",
+			Client:     client,
+			NumWorkers: 2,
+			// Small flushes, so the books go in many bulk requests
+			FlushBytes: 2000,
+			OnError: func(ctx context.Context, err error) {
+				t.Errorf("bulk request failed after retrying: %v", err)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadLetterPath := filepath.Join(t.TempDir(), "failed.json")
+	deadLetters, err := deadletter.Create(deadLetterPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &loader{
+		index:        "books",
+		bulkIndexers: bulkIndexers,
+		limiter:      newRateLimiter(),
+		transforms:   &transform.Chain{},
+		ids:          indexspec.IDStrategy{Strategy: "field", Field: "url"},
+		deadLetters:  deadLetters,
+		abort:        func(error) {},
+	}
+	const books = 300
+	var input strings.Builder
+	for i := 0; i < books; i++ {
+		fmt.Fprintf(&input, `{"title":"Book %d","url":"https://www.goodreads.com/book/show/%d"}`+"\n", i, i)
+	}
+	read := l.indexBooks(context.Background(), bufio.NewReader(strings.NewReader(input.String())))
+	for _, bulkIndexer := range bulkIndexers {
+		if err := bulkIndexer.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := deadLetters.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := deadletter.Read(deadLetterPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := cluster.Documents("books")
+	if read != books || len(indexed)+len(entries) != books {
+		t.Errorf("read %d books, indexed %d and dead-lettered %d, want all %d accounted for", read, len(indexed), len(entries), books)
+	}
+	for _, entry := range entries {
+		if _, ok := indexed[entry.DocumentID]; ok {
+			t.Errorf("%s was both indexed and dead-lettered", entry.DocumentID)
+		}
+		if entry.Status != 429 {
+			t.Errorf("%s dead-lettered with %d %s, want only the rejected bulk items", entry.DocumentID, entry.Status, entry.Error)
+		}
+	}
+
+	counts := transport.Counts()
+	if counts.Latency == 0 || counts.TooManyRequests == 0 || counts.Reset == 0 || counts.BulkItems == 0 {
+		t.Errorf("injected %+v, want some of every fault", counts)
+	}
+}
@@ -0,0 +1,93 @@
+package loadbooks
+
+import (
+	"bytes"
+	_ "embed" // Embeds the default index body
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+// defaultIndexBody creates the books index when no -mapping is given.
+//
+//go:embed books_index.json
+var defaultIndexBody []byte
+
+// loadIndexBody returns the create index request body: the body in
+// bodyPath, or the default one, with its mappings replaced by the ones in
+// mappingPath and its settings by the ones in settingsPath when they are
+// given. Every file has to hold a JSON object, so a broken file stops the
+// load before the index is created.
+func loadIndexBody(bodyPath, mappingPath, settingsPath string) ([]byte, error) {
+	body := map[string]interface{}{}
+	if bodyPath != "" {
+		if err := readObject(bodyPath, &body); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(defaultIndexBody, &body); err != nil {
+		return nil, err
+	}
+
+	for key, path := range map[string]string{"mappings": mappingPath, "settings": settingsPath} {
+		if path == "" {
+			continue
+		}
+		var section map[string]interface{}
+		if err := readObject(path, &section); err != nil {
+			return nil, err
+		}
+		// Accept the section on its own or still wrapped in its key, as
+		// copied from a full index body
+		if wrapped, ok := section[key].(map[string]interface{}); ok && len(section) == 1 {
+			section = wrapped
+		}
+		body[key] = section
+	}
+
+	return json.Marshal(body)
+}
+
+func readObject(path string, object *map[string]interface{}) error {
+	objectBytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(objectBytes, object); err != nil {
+		return fmt.Errorf("%s does not contain a valid JSON object: %w", path, err)
+	}
+	return nil
+}
+
+// createIndex creates index with body. An index that already exists is
+// loaded into as it is when existsOK, which it isn't for a new versioned
+// index of an alias: that one has to be created by this load, with this
+// body, before the alias can be pointed at it.
+func createIndex(client *elasticsearch7.Client, index string, body []byte, existsOK bool) error {
+	resp, err := client.Indices.Create(index, client.Indices.Create.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !resp.IsError() {
+		return nil
+	}
+
+	var errorResponse struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if json.Unmarshal(respBody, &errorResponse) == nil && errorResponse.Error.Type == "resource_already_exists_exception" && existsOK {
+		log.Printf("index %s already exists, loading into it with its existing mapping", index)
+		return nil
+	}
+	return fmt.Errorf("error creating %s, status: %s, response body: %s", index, resp.Status(), respBody)
+}
@@ -0,0 +1,52 @@
+package loadbooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+func TestCreateIndex(t *testing.T) {
+	const (
+		exists   = `{"error":{"type":"resource_already_exists_exception","reason":"index [books/abc] already exists"},"status":400}`
+		rejected = `{"error":{"type":"mapper_parsing_exception","reason":"Failed to parse mapping [_doc]: No handler for type [txt] declared on field [title]"},"status":400}`
+	)
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		existsOK bool
+		wantErr  bool
+	}{
+		{name: "created", status: http.StatusOK, response: `{"acknowledged":true}`},
+		{name: "exists", status: http.StatusBadRequest, response: exists, existsOK: true},
+		{name: "exists with alias", status: http.StatusBadRequest, response: exists, wantErr: true},
+		{name: "rejected mapping", status: http.StatusBadRequest, response: rejected, existsOK: true, wantErr: true},
+		{name: "rejected mapping with alias", status: http.StatusBadRequest, response: rejected, wantErr: true},
+		{name: "cluster error", status: http.StatusServiceUnavailable, response: `{}`, existsOK: true, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.URL.Path != "/books" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.response))
+			}))
+			defer cluster.Close()
+			client, err := esclient.NewClient(esclient.Config{URL: cluster.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = createIndex(client, "books", defaultIndexBody, test.existsOK)
+			if (err != nil) != test.wantErr {
+				t.Errorf("createIndex() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package loadbooks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// openInput opens the file at path, or stdin when path is "-".
+func openInput(path string) (*os.File, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(path)
+}
+
+// decompress returns a reader over the decompressed contents of input when
+// it is gzip or bzip2 compressed, detected by its magic bytes, and
+// reports whether it was. Other input is read as it is.
+func decompress(input io.Reader) (io.Reader, bool, error) {
+	buffered := bufio.NewReader(input)
+	magic, err := buffered.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, false, err
+		}
+		return reader, true, nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(buffered), true, nil
+	}
+	return buffered, false, nil
+}
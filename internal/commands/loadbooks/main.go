@@ -0,0 +1,658 @@
+// Package loadbooks bulk loads the Goodreads books dataset, or a dataset
+// from the catalog, into an index.
+package loadbooks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/joho/godotenv" // A helper library
+	"github.com/nickcanz/search-go/internal/changelog"
+	"github.com/nickcanz/search-go/internal/contenthash"
+	"github.com/nickcanz/search-go/internal/deadletter"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/plugin/transform"
+	"github.com/nickcanz/search-go/internal/plugin/wasm"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/suggest"
+	"github.com/nickcanz/search-go/internal/telemetry"
+	"github.com/nickcanz/search-go/internal/upsert"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+type Book struct {
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	Description string `json:"description"`
+
+	// The rest of the goodreads fields worth searching and filtering on.
+	// Ratings and years come as strings in the dump, and are indexed as
+	// numbers
+	BookID          string     `json:"book_id,omitempty"`
+	ISBN            string     `json:"isbn,omitempty"`
+	ISBN13          string     `json:"isbn13,omitempty"`
+	ASIN            string     `json:"asin,omitempty"`
+	KindleASIN      string     `json:"kindle_asin,omitempty"`
+	Authors         []Author   `json:"authors,omitempty"`
+	AverageRating   looseFloat `json:"average_rating"`
+	PublicationYear looseInt   `json:"publication_year"`
+	RatingsCount    looseInt   `json:"ratings_count"`
+	Genres          []string   `json:"genres,omitempty"`
+
+	// TitleSuggest is built from the title for suggest-books
+	TitleSuggest *suggest.Suggestion `json:"title_suggest,omitempty"`
+
+	// IndexedAt is when the loader read the book, so new additions to the
+	// catalog can be listed
+	IndexedAt time.Time `json:"indexed_at"`
+	// CreatedAt is when the book was first loaded and UpdatedAt when its
+	// content last changed. Reloads with stable ids keep the indexed ones,
+	// see package upsert
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// flagOrEnv returns value if it is set, otherwise the environment variable
+// env, otherwise fallback.
+func flagOrEnv(value, env, fallback string) string {
+	if value != "" {
+		return value
+	}
+	if value := os.Getenv(env); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// loader holds what is needed to turn input lines into bulk index requests.
+type loader struct {
+	index        string
+	bulkIndexers []esutil.BulkIndexer
+	// routings is the routing value of each bulk indexer, when
+	// -stream-routing is on
+	routings   []string
+	memory     *memoryGuard
+	limiter    *rateLimiter
+	transforms *transform.Chain
+	ids        indexspec.IDStrategy
+	// rejects collects unparseable lines with -skip-invalid, and is nil
+	// when they stop the load
+	rejects *rejectWriter
+	// schema checks books before they are indexed, and is nil without
+	// -schema
+	schema *schemaValidator
+	// unchanged holds back books to skip the ones already indexed with the
+	// same content, and is nil without -skip-unchanged
+	unchanged *unchangedFilter
+	// deadLetters collects documents Elasticsearch refused
+	deadLetters *deadletter.Writer
+	// lookups fills in author names and genres from -authors-file and
+	// -genres-file
+	lookups *Lookups
+	// changelog records every book written, and is nil without
+	// -changelog-index
+	changelog *changelog.Writer
+	// abort stops the load, flushing what was read, when a transform
+	// can't transform any more books
+	abort context.CancelCauseFunc
+}
+
+// Main runs load-books with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("load-books", flag.ExitOnError)
+	indexPtr := flags.String("index", "", "Index to load into, defaults to $SEARCH_GO_INDEX or books")
+	aliasPtr := flags.String("alias", "", "Load into a new index named <alias>-v<timestamp> and point this alias at it once the load succeeds, instead of loading into -index")
+	deleteOldPtr := flags.Bool("delete-old", false, "Delete the indices -alias pointed to before, once it has been moved")
+	yesPtr := flags.Bool("yes-i-mean-prod", false, "Skip the confirmation prompt for -delete-old when -profile is tagged production")
+	filePtr := flags.String("file", "", "Newline delimited JSON file of books to load, - reads from stdin, defaults to $SEARCH_GO_FILE or goodreads_books.1000.json")
+	mappingPtr := flags.String("mapping", "", "JSON file with the settings and mappings to create the index with, defaults to $SEARCH_GO_MAPPING or the built in books mapping")
+	mappingFilePtr := flags.String("mapping-file", "", "JSON file with the mappings to create the index with, replacing the mappings of -mapping or the built in ones")
+	settingsFilePtr := flags.String("settings-file", "", "JSON file with the settings to create the index with, replacing the settings of -mapping or the built in ones")
+	authorsFilePtr := flags.String("authors-file", "", "goodreads_book_authors.json file to fill in author names from")
+	genresFilePtr := flags.String("genres-file", "", "goodreads_book_genres_initial.json file to fill in genres from")
+	debugPortPtr := flags.Int("debug-port", 0, "Serve pprof and expvar endpoints on this localhost port (disabled when 0)")
+	maxMemoryPtr := flags.Int("max-memory", 0, "Pause reading input while heap usage is above this many megabytes (disabled when 0)")
+	streamsPtr := flags.Int("streams", 1, "Number of bulk indexers to load with in parallel, documents are split between them by a hash of their url")
+	workersPtr := flags.Int("workers", 1, "Number of workers each bulk indexer flushes with in parallel")
+	flushBytesPtr := flags.Int("flush-bytes", 5e+6, "Flush a bulk request once it reaches this many bytes")
+	flushIntervalPtr := flags.Duration("flush-interval", 30*time.Second, "Flush a bulk request at least this often, even if it isn't full")
+	streamRoutingPtr := flags.Bool("stream-routing", false, "Use each stream's number as its routing value so a stream writes to a single shard")
+	partitionsPtr := flags.Int("partitions", 0, "Split the input into this many byte ranges and only load the ones this loader claims (disabled when 0)")
+	loadIDPtr := flags.String("load-id", "", "Name shared by every loader taking part in a partitioned load")
+	coordinationIndexPtr := flags.String("coordination-index", "load-coordination", "Index used to claim partitions of a partitioned load")
+	clusterThrottlePtr := flags.Bool("cluster-throttle", false, "Poll node stats and slow down while the cluster is under pressure")
+	clusterPollIntervalPtr := flags.Duration("cluster-poll-interval", 10*time.Second, "How often to poll node stats with -cluster-throttle")
+	maxHeapPercentPtr := flags.Int("max-heap-percent", 85, "Node heap usage that counts as pressure with -cluster-throttle")
+	rampStartPtr := flags.Float64("ramp-start", 0, "Start the load at this many docs/sec and ramp up (disabled when 0)")
+	rampStepPtr := flags.Float64("ramp-step", 100, "Docs/sec to add at each -ramp-interval")
+	rampIntervalPtr := flags.Duration("ramp-interval", 30*time.Second, "How often to raise the rate while ramping up")
+	rampMaxPtr := flags.Float64("ramp-max", 0, "Rate in docs/sec to stop ramping up at and hold for the rest of the load")
+	windowPtr := flags.String("window", "", "Only load at full speed during this daily window, e.g. 01:00-06:00")
+	windowTimezonePtr := flags.String("window-timezone", "Local", "Time zone of -window, e.g. America/New_York")
+	windowTricklePtr := flags.Float64("window-trickle", 0, "Docs/sec to load at outside of -window (paused when 0)")
+	idStrategyPtr := flags.String("id-strategy", "auto", "How to pick each book's _id: auto lets Elasticsearch pick, field uses -id-field, hash uses a hash of -id-field. Only field and hash make reloading overwrite books instead of duplicating them")
+	idFieldPtr := flags.String("id-field", "url", "Field to take the _id from with -id-strategy field or hash")
+	skipInvalidPtr := flags.Bool("skip-invalid", false, "Skip lines that aren't valid JSON and write them to -rejects-file instead of stopping the load")
+	rejectsFilePtr := flags.String("rejects-file", "rejects.json", "File to write lines skipped by -skip-invalid to")
+	skipUnchangedPtr := flags.Bool("skip-unchanged", false, "Skip books already indexed with the same content hash, needs -id-strategy field or hash")
+	schemaPtr := flags.String("schema", "", "JSON Schema file or http(s) URL that every book must match")
+	schemaInvalidPtr := flags.String("schema-invalid", "fail", "What to do with a book that doesn't match -schema: fail stops the load, skip carries on without it")
+	schemaReportPtr := flags.String("schema-report", "schema-errors.json", "File to write books that don't match -schema to, with why")
+	changelogIndexPtr := flags.String("changelog-index", "", "Index to record every book written to, for books history, defaults to $SEARCH_GO_CHANGELOG_INDEX (disabled when empty)")
+	deadLetterFilePtr := flags.String("dead-letter-file", "failed.json", "File to write documents Elasticsearch refuses to, with the error, for retry-failed to resubmit")
+	bulkSettingsPtr := flags.Bool("bulk-settings", false, "Disable refresh and replicas while loading, and restore them when the load is done")
+	forceMergePtr := flags.Int("force-merge", 0, "Force merge the index down to this many segments once the load is done (disabled when 0)")
+	progressIntervalPtr := flags.Duration("progress-interval", 10*time.Second, "How often to log load progress (disabled when 0)")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	pluginsDirPtr := flags.String("plugins-dir", "", "Directory to run transform-* plugins and transform-*.wasm modules from on every document (none when empty)")
+	wasmMemoryPtr := flags.Int("wasm-memory", 64, "Memory limit in megabytes for each transform-*.wasm module")
+	wasmTimeoutPtr := flags.Duration("wasm-timeout", time.Second, "Time limit for a transform-*.wasm module to transform one document")
+	flags.Parse(args)
+	start := time.Now()
+
+	if *rampStartPtr > 0 && (*rampStepPtr <= 0 || *rampMaxPtr < *rampStartPtr) {
+		log.Fatalf("-ramp-start needs a positive -ramp-step and a -ramp-max of at least %.0f", *rampStartPtr)
+	}
+
+	if *partitionsPtr > 0 && *loadIDPtr == "" {
+		log.Fatalf("No -load-id provided, it is required with -partitions so every loader claims from the same set")
+	}
+
+	ids := indexspec.IDStrategy{Strategy: *idStrategyPtr, Field: *idFieldPtr}
+	if err := ids.Validate(); err != nil {
+		log.Fatalf("Invalid -id-strategy: %v", err)
+	}
+	if ids.Strategy == "hash" && ids.Field == "" {
+		// Every load stamps a new indexed_at, so the whole document never
+		// hashes the same twice
+		log.Fatalf("-id-strategy hash needs an -id-field")
+	}
+
+	if *skipUnchangedPtr && ids.Strategy == "auto" {
+		log.Fatalf("-skip-unchanged needs -id-strategy field or hash to find each book's earlier copy")
+	}
+	if *skipUnchangedPtr && *streamRoutingPtr {
+		log.Fatalf("-skip-unchanged can't look up books loaded with -stream-routing")
+	}
+
+	if *streamsPtr < 1 {
+		log.Fatalf("-streams must be at least 1, got %d", *streamsPtr)
+	}
+	if *aliasPtr != "" && *indexPtr != "" {
+		log.Fatalf("-alias picks the index to load into, so it can't be combined with -index")
+	}
+	if *aliasPtr != "" && (*partitionsPtr > 0 || *skipUnchangedPtr) {
+		// Each loader would create an index of its own, and a new index
+		// has nothing to compare with
+		log.Fatalf("-alias can't be combined with -partitions or -skip-unchanged")
+	}
+	if *deleteOldPtr && *aliasPtr == "" {
+		log.Fatalf("-delete-old needs an -alias to find the old indices by")
+	}
+	if *bulkSettingsPtr && *partitionsPtr > 0 {
+		// Each loader would restore the settings while the others are
+		// still loading
+		log.Fatalf("-bulk-settings can't be used with -partitions")
+	}
+	if *forceMergePtr < 0 {
+		log.Fatalf("-force-merge must be 0 or more segments, got %d", *forceMergePtr)
+	}
+	if *workersPtr < 1 {
+		log.Fatalf("-workers must be at least 1, got %d", *workersPtr)
+	}
+	if *flushBytesPtr < 1 {
+		log.Fatalf("-flush-bytes must be at least 1, got %d", *flushBytesPtr)
+	}
+	if *flushIntervalPtr <= 0 {
+		log.Fatalf("-flush-interval must be positive, got %s", *flushIntervalPtr)
+	}
+
+	fmt.Println("Hello from load-books")
+
+	if *debugPortPtr != 0 {
+		startDebugServer(*debugPortPtr)
+	}
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	var activeProfile *profile.Profile
+	if *profilePtr != "" {
+		activeProfile, err = profile.Activate(*profilePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Check the index body before anything is created
+	indexBody, err := loadIndexBody(flagOrEnv(*mappingPtr, "SEARCH_GO_MAPPING", ""), *mappingFilePtr, *settingsFilePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lookups, err := OpenLookups(*authorsFilePtr, *genresFilePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := esclient.NewClientFromEnv("load-books")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	indexName := flagOrEnv(*indexPtr, "SEARCH_GO_INDEX", "books")
+	if *aliasPtr != "" {
+		if err := checkAlias(client, *aliasPtr); err != nil {
+			log.Fatal(err)
+		}
+		indexName = versionedIndex(*aliasPtr, time.Now())
+		log.Printf("loading into %s for alias %s", indexName, *aliasPtr)
+	}
+	if *deleteOldPtr {
+		// Ask now rather than at the end of a long load
+		err := profile.ConfirmDestructive(activeProfile, fmt.Sprintf("delete the indices behind alias %s after loading", *aliasPtr), *yesPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	inputPath := flagOrEnv(*filePtr, "SEARCH_GO_FILE", "goodreads_books.1000.json")
+
+	if err := createIndex(client, indexName, indexBody, *aliasPtr == ""); err != nil {
+		log.Fatal(err)
+	}
+
+	var previousSettings map[string]interface{}
+	if *bulkSettingsPtr {
+		previousSettings, err = applyBulkSettings(client, indexName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	bulkIndexers := make([]esutil.BulkIndexer, *streamsPtr)
+	var routings []string
+	for i := range bulkIndexers {
+		bulkIndexerConfig := esutil.BulkIndexerConfig{
+			Index:         indexName,
+			NumWorkers:    *workersPtr,
+			FlushBytes:    *flushBytesPtr,
+			FlushInterval: *flushIntervalPtr,
+			Client:        client,
+			ErrorTrace:    true,
+			OnError: func(ctx context.Context, err error) {
+				log.Fatalf("bulkindexer OnError %#v", err)
+			},
+		}
+		if *streamRoutingPtr {
+			bulkIndexerConfig.Routing = strconv.Itoa(i)
+			routings = append(routings, bulkIndexerConfig.Routing)
+		}
+
+		bulkIndexers[i], err = esutil.NewBulkIndexer(bulkIndexerConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	file, err := openInput(inputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	input, compressed, err := decompress(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if inputPath == "-" && *partitionsPtr > 0 {
+		log.Fatalf("-partitions needs a -file to split, not stdin")
+	}
+	if compressed && *partitionsPtr > 0 {
+		log.Fatalf("-partitions splits the file by byte ranges, which doesn't work for compressed %s", inputPath)
+	}
+
+	var memory *memoryGuard
+	if *maxMemoryPtr > 0 {
+		memory = newMemoryGuard(*maxMemoryPtr)
+	}
+
+	limiter := newRateLimiter()
+	if *windowPtr != "" {
+		window, err := parseLoadWindow(*windowPtr, *windowTimezonePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		window.enforce(limiter, *windowTricklePtr)
+	}
+	if *rampStartPtr > 0 {
+		rampUp(limiter, *rampStartPtr, *rampStepPtr, *rampMaxPtr, *rampIntervalPtr)
+	}
+	if *clusterThrottlePtr {
+		newClusterThrottle(client, limiter, *clusterPollIntervalPtr, *maxHeapPercentPtr).Start()
+	}
+
+	// Stop reading on the first SIGINT or SIGTERM and flush what was read.
+	// A second signal kills the loader as usual. A transform that can't
+	// go on stops the load the same way, with abort.
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
+	ctx, abort := context.WithCancelCause(signalCtx)
+	defer abort(nil)
+
+	transforms, err := transform.Discover(*pluginsDirPtr, wasm.Limits{
+		MemoryMB: *wasmMemoryPtr,
+		Timeout:  *wasmTimeoutPtr,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transforms.Close()
+	for _, name := range transforms.Names() {
+		log.Printf("Using transform %s", name)
+	}
+
+	deadLetters, err := deadletter.Create(*deadLetterFilePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if pending, err := deadletter.Pending(*deadLetterFilePtr); err != nil {
+		log.Fatal(err)
+	} else if pending > 0 {
+		log.Printf("%s still holds %d books that failed in an earlier load, new failures are added after them", *deadLetterFilePtr, pending)
+	}
+
+	books := &loader{
+		index:        indexName,
+		bulkIndexers: bulkIndexers,
+		routings:     routings,
+		memory:       memory,
+		limiter:      limiter,
+		transforms:   transforms,
+		ids:          ids,
+		deadLetters:  deadLetters,
+		lookups:      lookups,
+		abort:        abort,
+	}
+	books.changelog, err = changelog.New(client, flagOrEnv(*changelogIndexPtr, changelog.EnvIndex, ""), "load-books")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *skipUnchangedPtr {
+		books.unchanged = newUnchangedFilter(client, indexName, 500, books.add)
+	}
+	if *schemaPtr != "" {
+		books.schema, err = openSchema(*schemaPtr, *schemaInvalidPtr, *schemaReportPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *skipInvalidPtr {
+		books.rejects, err = openRejects(*rejectsFilePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Partitioned loads only read the partitions they claim, and compressed
+	// files are bigger once read, so then the file size says nothing about
+	// how much is left
+	var totalBytes int64
+	if *partitionsPtr == 0 && !compressed {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			totalBytes = info.Size()
+		}
+	}
+	progress := startProgress(bulkIndexers, totalBytes, *progressIntervalPtr)
+
+	if *partitionsPtr > 0 {
+		coordinator := newCoordinator(client, *coordinationIndexPtr, *loadIDPtr)
+		for k := 0; k < *partitionsPtr; k++ {
+			claimed, err := coordinator.Claim(k)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !claimed {
+				log.Printf("partition %d/%d already claimed, skipping", k, *partitionsPtr)
+				continue
+			}
+
+			partition, err := openPartition(file, k, *partitionsPtr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("loading partition %d/%d", k, *partitionsPtr)
+			docs := books.indexBooks(ctx, bufio.NewReader(partition))
+			if ctx.Err() != nil {
+				// Leave the partition claimed but incomplete, it was only
+				// partly read
+				break
+			}
+
+			if err := coordinator.Complete(k, docs); err != nil {
+				log.Fatal(err)
+			}
+		}
+	} else {
+		books.indexBooks(ctx, bufio.NewReader(input))
+	}
+
+	// Flush whatever was read, even when interrupted
+	for _, bulkIndexer := range bulkIndexers {
+		if err := bulkIndexer.Close(context.Background()); err != nil {
+			log.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if err := books.changelog.Close(); err != nil {
+		log.Fatal(err)
+	}
+	progress.Stop()
+	reportStreamStats(bulkIndexers)
+	if previousSettings != nil {
+		if err := restoreSettings(client, indexName, previousSettings); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *forceMergePtr > 0 && ctx.Err() == nil {
+		if err := forceMerge(client, indexName, *forceMergePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if books.rejects != nil {
+		if err := books.rejects.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *skipUnchangedPtr {
+		log.Printf("skipped %d unchanged books", docsUnchanged.Value())
+	}
+	if books.schema != nil {
+		if err := books.schema.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	failed := deadLetters.Count()
+	if failed > 0 {
+		log.Printf("%d books failed to index, written to %s, resubmit them with retry-failed -file %s", failed, *deadLetterFilePtr, *deadLetterFilePtr)
+	}
+	if err := deadLetters.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	telemetry.Report("load-books", flags, docsAdded.Value(), time.Since(start))
+
+	if *aliasPtr != "" && ctx.Err() == nil {
+		if failed > 0 {
+			log.Fatalf("Not pointing alias %s at %s because %d books failed to index", *aliasPtr, indexName, failed)
+		}
+		if err := moveAlias(client, *aliasPtr, indexName, *deleteOldPtr, *profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+			log.Printf("Load stopped after reading %d books, the documents read so far were flushed: %v", docsRead.Value(), cause)
+		} else {
+			log.Printf("Load interrupted after reading %d books, the documents read so far were flushed", docsRead.Value())
+		}
+		transforms.Close()
+		os.Exit(1)
+	}
+}
+
+// indexBooks reads books from reader until EOF or until ctx is done, adds
+// them to the bulk indexers and returns the number of books read.
+func (l *loader) indexBooks(ctx context.Context, reader *bufio.Reader) int64 {
+	var count, line int64
+	for {
+		if l.memory != nil {
+			l.memory.Wait(ctx)
+		}
+		l.limiter.Wait(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+
+		readBytes, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			log.Fatalf("error reading readBytes: %v", err)
+		}
+		// The last line may not end in a newline
+		if err == io.EOF && len(readBytes) == 0 {
+			break
+		}
+		bytesRead.Add(int64(len(readBytes)))
+		line++
+
+		// Files written on Windows end lines with \r\n, and blank lines
+		// are not books
+		readBytes = bytes.TrimRight(readBytes, "\r\n")
+		if len(bytes.TrimSpace(readBytes)) == 0 {
+			continue
+		}
+		docsRead.Add(1)
+		count++
+
+		book, err := ReadBook(readBytes, l.lookups)
+		if err != nil {
+			if l.rejects == nil {
+				log.Fatalf("error unmarshalling json on line %d: %v", line, err)
+			}
+			l.rejects.Reject(line, readBytes, err)
+			continue
+		}
+		if l.schema != nil && !l.schema.Validate(line, readBytes) {
+			continue
+		}
+
+		documentBytes, err := book.Document(time.Now().UTC())
+		if err != nil {
+			log.Fatalf("error marshalling json: %v", err)
+		}
+
+		// A transform that fails on one document, such as a WASM module
+		// running out of time, doesn't stop the load, but one that exited
+		// would fail on every book after it. Either way the documents
+		// already buffered are still indexed
+		transformed, err := l.transforms.Apply(documentBytes)
+		if transform.Fatal(err) {
+			l.abort(fmt.Errorf("line %d: %w", line, err))
+			break
+		}
+		if err != nil {
+			log.Printf("ERROR: line %d: %v", line, err)
+			if err := l.deadLetters.Write(deadletter.NewTransformEntry(l.index, documentBytes, err)); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		if transformed == nil {
+			continue
+		}
+		documentBytes = transformed
+
+		id, err := l.ids.DocumentID(documentBytes)
+		if err != nil {
+			log.Fatalf("error picking an id for line %d: %v", line, err)
+		}
+
+		// The timestamps change on every load, so they aren't part of the
+		// content
+		documentBytes, hash, err := contenthash.Add(documentBytes, "indexed_at", "created_at", "updated_at")
+		if err != nil {
+			log.Fatalf("error hashing line %d: %v", line, err)
+		}
+
+		stream := streamFor(book, len(l.bulkIndexers))
+		var routing string
+		if l.routings != nil {
+			routing = l.routings[stream]
+		}
+		pending := pendingBook{stream: stream, routing: routing, id: id, hash: hash, document: documentBytes}
+		if l.unchanged != nil {
+			l.unchanged.Queue(pending)
+		} else {
+			l.add(pending)
+		}
+	}
+	if l.unchanged != nil {
+		l.unchanged.Flush()
+	}
+	return count
+}
+
+// add adds a book to its stream's bulk indexer. Books with an id are
+// upserted so their earlier timestamps are kept.
+func (l *loader) add(book pendingBook) {
+	action, body := "index", book.document
+	if book.id != "" {
+		var err error
+		action = "update"
+		body, err = upsert.Body(book.document)
+		if err != nil {
+			log.Fatalf("error marshalling json: %v", err)
+		}
+	}
+
+	err := l.bulkIndexers[book.stream].Add(
+		context.Background(),
+		esutil.BulkIndexerItem{
+			Action:     action,
+			DocumentID: book.id,
+			Body:       bytes.NewReader(body),
+			OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+				l.changelog.Record(res.Index, res.DocumentID, changelog.Op(item.Action, res.Result))
+			},
+			// OnFailure is called for each failed operation
+			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+				if err != nil {
+					log.Printf("ERROR: %s", err)
+				} else {
+					log.Printf("ERROR: %s: %s", res.Error.Type, res.Error.Reason)
+				}
+				entry := deadletter.NewEntry(l.index, book.routing, book.document, item, res, err)
+				if err := l.deadLetters.Write(entry); err != nil {
+					log.Fatal(err)
+				}
+			},
+		})
+	if err != nil {
+		log.Fatalf("error adding item to bulk indexer: %v", err)
+	}
+	docsAdded.Add(1)
+}
@@ -0,0 +1,121 @@
+package loadbooks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/nickcanz/search-go/internal/deadletter"
+	"github.com/nickcanz/search-go/internal/plugin"
+	"github.com/nickcanz/search-go/internal/plugin/transform"
+	"github.com/nickcanz/search-go/internal/plugin/wasm"
+)
+
+// fakeBulkIndexer collects the items added to it instead of sending them.
+type fakeBulkIndexer struct {
+	items []esutil.BulkIndexerItem
+}
+
+func (f *fakeBulkIndexer) Add(ctx context.Context, item esutil.BulkIndexerItem) error {
+	f.items = append(f.items, item)
+	return nil
+}
+
+func (f *fakeBulkIndexer) Close(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeBulkIndexer) Stats() esutil.BulkIndexerStats {
+	return esutil.BulkIndexerStats{}
+}
+
+// testLoader returns a loader that runs the shell script transform and
+// adds books to the returned bulk indexer.
+func testLoader(t *testing.T, script string) (*loader, *fakeBulkIndexer, context.Context) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("transform plugins in these tests are shell scripts")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "transform-test"), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	transforms, err := transform.Named(dir, []string{"transform-test"}, wasm.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(transforms.Close)
+
+	deadLetters, err := deadletter.Create(filepath.Join(dir, "failed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deadLetters.Close() })
+
+	ctx, abort := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { abort(nil) })
+
+	bulkIndexer := &fakeBulkIndexer{}
+	return &loader{
+		index:        "books",
+		bulkIndexers: []esutil.BulkIndexer{bulkIndexer},
+		limiter:      newRateLimiter(),
+		transforms:   transforms,
+		deadLetters:  deadLetters,
+		abort:        abort,
+	}, bulkIndexer, ctx
+}
+
+const testBooks = `{"title":"One","url":"https://www.goodreads.com/book/show/1"}
+{"title":"Two","url":"https://www.goodreads.com/book/show/2"}
+{"title":"Three","url":"https://www.goodreads.com/book/show/3"}
+`
+
+func TestIndexBooksStopsWhenPluginExits(t *testing.T) {
+	// Answers the first book and exits
+	l, bulkIndexer, ctx := testLoader(t, "read line\necho \"$line\"\n")
+
+	l.indexBooks(ctx, bufio.NewReader(strings.NewReader(testBooks)))
+
+	if ctx.Err() == nil {
+		t.Fatal("expected the load to be stopped after the plugin exited")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, plugin.ErrExited) {
+		t.Errorf("load stopped with %v, want an error wrapping plugin.ErrExited", cause)
+	}
+	if len(bulkIndexer.items) != 1 {
+		t.Errorf("indexed %d books, want only the one the plugin answered", len(bulkIndexer.items))
+	}
+	if failed := l.deadLetters.Count(); failed != 0 {
+		t.Errorf("dead-lettered %d books, want none when the plugin is gone", failed)
+	}
+}
+
+func TestIndexBooksDeadLettersOneBadDocument(t *testing.T) {
+	// Returns invalid JSON for the second book only
+	l, bulkIndexer, ctx := testLoader(t, `n=0
+while read line; do
+	n=$((n + 1))
+	if [ "$n" -eq 2 ]; then echo "{broken"; else echo "$line"; fi
+done
+`)
+
+	l.indexBooks(ctx, bufio.NewReader(strings.NewReader(testBooks)))
+
+	if ctx.Err() != nil {
+		t.Fatalf("load stopped with %v, want it to carry on past one bad document", context.Cause(ctx))
+	}
+	if len(bulkIndexer.items) != 2 {
+		t.Errorf("indexed %d books, want 2", len(bulkIndexer.items))
+	}
+	if failed := l.deadLetters.Count(); failed != 1 {
+		t.Errorf("dead-lettered %d books, want 1", failed)
+	}
+}
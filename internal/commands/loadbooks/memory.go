@@ -0,0 +1,54 @@
+package loadbooks
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// memoryGuard pauses the reader loop while heap usage is above a limit,
+// giving the bulk indexer time to drain its queue instead of letting the
+// process grow until it is OOM killed.
+type memoryGuard struct {
+	limit      uint64
+	checkEvery int
+	count      int
+}
+
+func newMemoryGuard(limitMB int) *memoryGuard {
+	limit := uint64(limitMB) * 1024 * 1024
+
+	// Ask the garbage collector to work harder as we approach the limit
+	debug.SetMemoryLimit(int64(limit))
+
+	return &memoryGuard{limit: limit, checkEvery: 1000}
+}
+
+// Wait blocks while heap usage is at or above the limit, or until ctx is
+// done. Reading memory stats stops the world, so the heap is only checked
+// every checkEvery calls.
+func (g *memoryGuard) Wait(ctx context.Context) {
+	g.count++
+	if g.count%g.checkEvery != 0 {
+		return
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc < g.limit {
+		return
+	}
+
+	log.Printf("Heap usage %d MB is over the -max-memory limit of %d MB, pausing reads", stats.HeapAlloc/1024/1024, g.limit/1024/1024)
+	start := time.Now()
+	for stats.HeapAlloc >= g.limit*9/10 {
+		if !sleep(ctx, 500*time.Millisecond) {
+			return
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+	}
+	log.Printf("Heap usage down to %d MB, resuming reads after %s", stats.HeapAlloc/1024/1024, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,129 @@
+package loadbooks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+// lineBoundary returns the offset of the first line that starts at or after
+// offset, so that every line belongs to exactly one partition.
+func lineBoundary(file *os.File, offset, size int64) (int64, error) {
+	if offset <= 0 {
+		return 0, nil
+	}
+	if offset >= size {
+		return size, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, offset-1, size-offset+1))
+	skipped, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+// openPartition returns a reader over the whole lines of partition k of n
+// equally sized byte ranges of file.
+func openPartition(file *os.File, k, n int) (*io.SectionReader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	start, err := lineBoundary(file, size*int64(k)/int64(n), size)
+	if err != nil {
+		return nil, err
+	}
+	end, err := lineBoundary(file, size*int64(k+1)/int64(n), size)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NewSectionReader(file, start, end-start), nil
+}
+
+// coordinator lets loaders on different machines split one load between
+// them. Each partition is claimed by creating a document in the
+// coordination index, which only one loader can succeed at.
+type coordinator struct {
+	client *elasticsearch7.Client
+	index  string
+	loadID string
+	host   string
+}
+
+func newCoordinator(client *elasticsearch7.Client, index, loadID string) *coordinator {
+	host, _ := os.Hostname()
+	return &coordinator{client: client, index: index, loadID: loadID, host: host}
+}
+
+func (c *coordinator) documentID(k int) string {
+	return fmt.Sprintf("%s-%d", c.loadID, k)
+}
+
+// Claim reports whether this loader won partition k.
+func (c *coordinator) Claim(k int) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"load_id":    c.loadID,
+		"partition":  k,
+		"host":       c.host,
+		"status":     "claimed",
+		"claimed_at": time.Now().UTC(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.client.Create(
+		c.index,
+		c.documentID(k),
+		bytes.NewReader(body),
+		c.client.Create.WithRefresh("true"))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	if resp.IsError() {
+		return false, fmt.Errorf("error claiming partition %d, status: %s, response body: %s", k, resp.Status(), resp.String())
+	}
+	return true, nil
+}
+
+// Complete marks partition k as fully read and handed to the bulk indexer.
+func (c *coordinator) Complete(k int, docs int64) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"doc": map[string]interface{}{
+			"status":       "complete",
+			"docs":         docs,
+			"completed_at": time.Now().UTC(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Update(c.index, c.documentID(k), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("error completing partition %d, status: %s, response body: %s", k, resp.Status(), resp.String())
+	}
+	return nil
+}
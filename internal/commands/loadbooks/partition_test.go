@@ -0,0 +1,101 @@
+package loadbooks
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemp(t *testing.T, content string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "books.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestLineBoundary(t *testing.T) {
+	content := "aaa\nbb\n\ncccc\n"
+	file := writeTemp(t, content)
+	size := int64(len(content))
+
+	tests := []struct {
+		offset int64
+		want   int64
+	}{
+		{offset: -1, want: 0},
+		{offset: 0, want: 0},
+		// Inside the first line, so the next line starts the partition
+		{offset: 1, want: 4},
+		{offset: 3, want: 4},
+		// At the start of a line already
+		{offset: 4, want: 4},
+		{offset: 5, want: 7},
+		{offset: 7, want: 7},
+		{offset: 8, want: 8},
+		{offset: 9, want: 13},
+		{offset: size, want: size},
+		{offset: size + 10, want: size},
+	}
+	for _, test := range tests {
+		got, err := lineBoundary(file, test.offset, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("lineBoundary(%d) = %d, want %d", test.offset, got, test.want)
+		}
+	}
+}
+
+func TestLineBoundaryWithoutTrailingNewline(t *testing.T) {
+	content := "aaa\nbbb"
+	file := writeTemp(t, content)
+	got, err := lineBoundary(file, 5, int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != int64(len(content)) {
+		t.Errorf("lineBoundary inside the last line = %d, want the end of the file", got)
+	}
+}
+
+// TestOpenPartition checks that however a file is split, every line is
+// read by exactly one partition.
+func TestOpenPartition(t *testing.T) {
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, strings.Repeat("x", i%7)+"\n")
+	}
+	content := strings.Join(lines, "")
+	file := writeTemp(t, content)
+
+	for n := 1; n <= 12; n++ {
+		var joined strings.Builder
+		for k := 0; k < n; k++ {
+			partition, err := openPartition(file, k, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := io.ReadAll(partition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(read) > 0 && read[len(read)-1] != '\n' {
+				t.Errorf("partition %d of %d ends in the middle of a line", k, n)
+			}
+			joined.Write(read)
+		}
+		if joined.String() != content {
+			t.Errorf("%d partitions don't add up to the file", n)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package loadbooks
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+)
+
+// progressReporter periodically logs how far along a load is, using the
+// docs_read and bytes_read counters and the bulk indexer stats.
+type progressReporter struct {
+	bulkIndexers []esutil.BulkIndexer
+	// totalBytes is the size of the input, or 0 when the loader won't
+	// read all of it and there is no ETA
+	totalBytes int64
+	start      time.Time
+	done       chan struct{}
+}
+
+func startProgress(bulkIndexers []esutil.BulkIndexer, totalBytes int64, interval time.Duration) *progressReporter {
+	p := &progressReporter{
+		bulkIndexers: bulkIndexers,
+		totalBytes:   totalBytes,
+		start:        time.Now(),
+		done:         make(chan struct{}),
+	}
+	if interval > 0 {
+		go p.run(interval)
+	}
+	return p
+}
+
+func (p *progressReporter) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.report()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *progressReporter) report() {
+	elapsed := time.Since(p.start).Seconds()
+	docs, bytes := docsRead.Value(), bytesRead.Value()
+
+	var indexed, failed uint64
+	for _, bulkIndexer := range p.bulkIndexers {
+		stats := bulkIndexer.Stats()
+		indexed += stats.NumIndexed
+		failed += stats.NumFailed
+	}
+
+	eta := ""
+	if p.totalBytes > 0 && bytes > 0 {
+		remaining := time.Duration(float64(p.totalBytes-bytes) / float64(bytes) * elapsed * float64(time.Second))
+		eta = fmt.Sprintf(", %.1f%% read, ETA %s", 100*float64(bytes)/float64(p.totalBytes), remaining.Round(time.Second))
+	}
+	log.Printf("progress: read %d, indexed %d, failed %d, %.0f docs/sec, %.1f MB/sec%s",
+		docs, indexed, failed, float64(docs)/elapsed, float64(bytes)/elapsed/1024/1024, eta)
+}
+
+// Stop ends the periodic reports and logs the overall read rate.
+func (p *progressReporter) Stop() {
+	close(p.done)
+	elapsed := time.Since(p.start)
+	log.Printf("read %d books, %.1f MB in %s, %.0f docs/sec",
+		docsRead.Value(), float64(bytesRead.Value())/1024/1024, elapsed.Round(time.Millisecond), float64(docsRead.Value())/elapsed.Seconds())
+}
@@ -0,0 +1,31 @@
+package loadbooks
+
+import (
+	"log"
+	"time"
+)
+
+// rampUp raises the loader's rate from start by step every interval until
+// it reaches max, so a cluster serving live traffic can absorb a backfill
+// gradually. The rate stays at max for the rest of the load.
+func rampUp(limiter *rateLimiter, start, step, max float64, interval time.Duration) {
+	rate := start
+	limiter.SetLimit("ramp", rate)
+	log.Printf("Ramping up from %.0f docs/sec", rate)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			rate += step
+			if rate >= max {
+				limiter.SetLimit("ramp", max)
+				log.Printf("Ramp up complete at %.0f docs/sec", max)
+				return
+			}
+			limiter.SetLimit("ramp", rate)
+			log.Printf("Ramping up to %.0f docs/sec", rate)
+		}
+	}()
+}
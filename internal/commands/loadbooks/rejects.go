@@ -0,0 +1,45 @@
+package loadbooks
+
+import (
+	"log"
+	"os"
+
+	"github.com/nickcanz/search-go/internal/filelock"
+)
+
+// rejectWriter collects input lines that could not be parsed, so a load
+// can carry on past them and they can be fixed and loaded later.
+type rejectWriter struct {
+	path  string
+	file  *os.File
+	count int64
+}
+
+func openRejects(path string) (*rejectWriter, error) {
+	file, err := filelock.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rejectWriter{path: path, file: file}, nil
+}
+
+// Reject logs why line number lineNumber was skipped and appends it to the
+// rejects file.
+func (r *rejectWriter) Reject(lineNumber int64, line []byte, err error) {
+	log.Printf("skipping invalid line %d: %v", lineNumber, err)
+	r.count++
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	if _, err := r.file.Write(line); err != nil {
+		log.Fatalf("error writing to %s: %v", r.path, err)
+	}
+}
+
+// Close closes the rejects file and reports how many lines were skipped.
+func (r *rejectWriter) Close() error {
+	if r.count > 0 {
+		log.Printf("skipped %d invalid lines, written to %s", r.count, r.path)
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,132 @@
+package loadbooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/filelock"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	_ "github.com/santhosh-tekuri/jsonschema/v5/httploader" // Loads schemas from http and https URLs
+)
+
+// schemaValidator checks input lines against a JSON Schema, so a producer
+// that changes the shape of its books can't quietly break the index.
+type schemaValidator struct {
+	schema *jsonschema.Schema
+	// skip carries on past invalid books instead of stopping the load
+	skip bool
+
+	reportPath string
+	report     *os.File
+	count      int64
+}
+
+// schemaViolation is a line of the validation report.
+type schemaViolation struct {
+	Line     int64           `json:"line"`
+	Errors   []schemaError   `json:"errors"`
+	Document json.RawMessage `json:"document"`
+}
+
+type schemaError struct {
+	// Path is the JSON pointer of the offending value, e.g. /title
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// openSchema compiles the schema at location, a file or an http(s) URL,
+// and opens the validation report.
+func openSchema(location, onInvalid, reportPath string) (*schemaValidator, error) {
+	if onInvalid != "fail" && onInvalid != "skip" {
+		return nil, fmt.Errorf("unknown -schema-invalid %q, expected fail or skip", onInvalid)
+	}
+	schema, err := jsonschema.Compile(location)
+	if err != nil {
+		return nil, fmt.Errorf("error loading schema %s: %w", location, err)
+	}
+	report, err := filelock.Create(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaValidator{
+		schema:     schema,
+		skip:       onInvalid == "skip",
+		reportPath: reportPath,
+		report:     report,
+	}, nil
+}
+
+// Validate reports whether the book on line number lineNumber matches the
+// schema. A book that doesn't is written to the report, and stops the load
+// unless invalid books are skipped.
+func (v *schemaValidator) Validate(lineNumber int64, line []byte) bool {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		log.Fatalf("error unmarshalling json on line %d: %v", lineNumber, err)
+	}
+
+	err := v.schema.Validate(document)
+	if err == nil {
+		return true
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		log.Fatalf("error validating line %d: %v", lineNumber, err)
+	}
+
+	violation := schemaViolation{Line: lineNumber, Document: json.RawMessage(line)}
+	basics := validationErr.BasicOutput().Errors
+	for _, basic := range basics {
+		// The basic output also lists every schema that failed because a
+		// keyword under it did, which says nothing new
+		if hasCause(basic, basics) {
+			continue
+		}
+		violation.Errors = append(violation.Errors, schemaError{Path: basic.InstanceLocation, Error: basic.Error})
+	}
+	v.write(violation)
+
+	if !v.skip {
+		log.Fatalf("line %d doesn't match the schema: %v", lineNumber, validationErr)
+	}
+	log.Printf("skipping line %d, it doesn't match the schema: %v", lineNumber, validationErr)
+	return false
+}
+
+// hasCause reports whether basic only failed because of another error in
+// basics, or is the error for the whole document.
+func hasCause(basic jsonschema.BasicError, basics []jsonschema.BasicError) bool {
+	for _, other := range basics {
+		if other.KeywordLocation != basic.KeywordLocation && strings.HasPrefix(other.KeywordLocation, basic.KeywordLocation+"/") {
+			return true
+		}
+	}
+	return basic.KeywordLocation == ""
+}
+
+func (v *schemaValidator) write(violation schemaViolation) {
+	v.count++
+	entry, err := json.Marshal(violation)
+	if err != nil {
+		log.Fatalf("error marshalling json: %v", err)
+	}
+	if _, err := v.report.Write(append(entry, '\n')); err != nil {
+		log.Fatalf("error writing to %s: %v", v.reportPath, err)
+	}
+}
+
+// Close closes the report and says how many books didn't match the
+// schema.
+func (v *schemaValidator) Close() error {
+	if v.count > 0 {
+		log.Printf("%d books didn't match the schema, written to %s", v.count, v.reportPath)
+	}
+	return v.report.Close()
+}
@@ -0,0 +1,36 @@
+package loadbooks
+
+import (
+	"hash/fnv"
+	"log"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+)
+
+// streamFor assigns a book to one of n streams by hashing its url, so the
+// same book always lands in the same stream across runs.
+func streamFor(book Book, n int) int {
+	if n == 1 {
+		return 0
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(book.Url))
+	return int(hash.Sum32() % uint32(n))
+}
+
+// reportStreamStats logs the stats of each stream's bulk indexer and the
+// combined totals once all of them have been closed.
+func reportStreamStats(bulkIndexers []esutil.BulkIndexer) {
+	var total esutil.BulkIndexerStats
+	for i, bulkIndexer := range bulkIndexers {
+		stats := bulkIndexer.Stats()
+		if len(bulkIndexers) > 1 {
+			log.Printf("stream %d: added %d, indexed %d, failed %d, requests %d", i, stats.NumAdded, stats.NumIndexed, stats.NumFailed, stats.NumRequests)
+		}
+		total.NumAdded += stats.NumAdded
+		total.NumIndexed += stats.NumIndexed
+		total.NumFailed += stats.NumFailed
+		total.NumRequests += stats.NumRequests
+	}
+	log.Printf("total: added %d, indexed %d, failed %d, requests %d", total.NumAdded, total.NumIndexed, total.NumFailed, total.NumRequests)
+}
@@ -0,0 +1,97 @@
+package loadbooks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out documents read by the loader. Several sources can
+// each set a limit in documents per second and the lowest one applies. A
+// limit of zero pauses the loader until it is raised or cleared.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limits map[string]float64
+	next   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{limits: map[string]float64{}}
+}
+
+// SetLimit sets the limit from source, replacing any earlier one.
+func (l *rateLimiter) SetLimit(source string, docsPerSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[source] = docsPerSecond
+}
+
+// ClearLimit removes the limit set by source.
+func (l *rateLimiter) ClearLimit(source string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.limits, source)
+}
+
+// LimitFrom returns the limit set by source and whether there is one.
+func (l *rateLimiter) LimitFrom(source string) (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	docsPerSecond, limited := l.limits[source]
+	return docsPerSecond, limited
+}
+
+func (l *rateLimiter) limit() (float64, bool) {
+	var lowest float64
+	limited := false
+	for _, docsPerSecond := range l.limits {
+		if !limited || docsPerSecond < lowest {
+			lowest = docsPerSecond
+			limited = true
+		}
+	}
+	return lowest, limited
+}
+
+// Wait blocks until the next document may be read or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) {
+	for {
+		l.mu.Lock()
+		docsPerSecond, limited := l.limit()
+		if !limited {
+			l.mu.Unlock()
+			return
+		}
+		if docsPerSecond <= 0 {
+			l.mu.Unlock()
+			if !sleep(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+
+		now := time.Now()
+		if l.next.Before(now) {
+			l.next = now
+		}
+		wait := l.next.Sub(now)
+		l.next = l.next.Add(time.Duration(float64(time.Second) / docsPerSecond))
+		l.mu.Unlock()
+
+		sleep(ctx, wait)
+		return
+	}
+}
+
+// sleep pauses for d and reports whether it did so without ctx being done
+// first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,72 @@
+package loadbooks
+
+import (
+	"log"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/internal/contenthash"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// pendingBook is a book ready to be added to a bulk indexer.
+type pendingBook struct {
+	stream   int
+	routing  string
+	id       string
+	hash     string
+	document []byte
+}
+
+// unchangedFilter holds back books in batches and looks up the content
+// hashes already indexed for them, so only new and changed books are sent
+// to the bulk indexers.
+type unchangedFilter struct {
+	client    *elasticsearch7.Client
+	index     string
+	batchSize int
+	add       func(pendingBook)
+	pending   []pendingBook
+}
+
+func newUnchangedFilter(client *elasticsearch7.Client, index string, batchSize int, add func(pendingBook)) *unchangedFilter {
+	return &unchangedFilter{
+		client:    client,
+		index:     index,
+		batchSize: batchSize,
+		add:       add,
+	}
+}
+
+// Queue adds book to the batch, checking the batch once it is full.
+func (f *unchangedFilter) Queue(book pendingBook) {
+	f.pending = append(f.pending, book)
+	if len(f.pending) >= f.batchSize {
+		f.Flush()
+	}
+}
+
+// Flush checks the books in the batch against the index and adds the ones
+// that are new or have changed.
+func (f *unchangedFilter) Flush() {
+	if len(f.pending) == 0 {
+		return
+	}
+
+	ids := make([]string, len(f.pending))
+	for i, book := range f.pending {
+		ids[i] = book.id
+	}
+	existing, err := esclient.MGet(f.client, f.index, ids, contenthash.Field)
+	if err != nil {
+		log.Fatalf("error looking up content hashes: %v", err)
+	}
+
+	for i, book := range f.pending {
+		if existing[i].Found && contenthash.Stored(existing[i].Source) == book.hash {
+			docsUnchanged.Add(1)
+			continue
+		}
+		f.add(book)
+	}
+	f.pending = f.pending[:0]
+}
@@ -0,0 +1,89 @@
+package loadbooks
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// loadWindow is a daily time range, such as 01:00-06:00, during which the
+// loader runs at full speed. A window may wrap past midnight.
+type loadWindow struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+func parseLoadWindow(value, timezone string) (*loadWindow, error) {
+	startValue, endValue, found := strings.Cut(value, "-")
+	if !found {
+		return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", value)
+	}
+
+	start, err := parseClock(startValue)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(endValue)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadWindow{start: start, end: end, location: location}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w *loadWindow) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.start <= w.end {
+		return sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+	return sinceMidnight >= w.start || sinceMidnight < w.end
+}
+
+// enforce limits the loader to trickle docs/sec outside of the window, or
+// pauses it entirely when trickle is 0, checking again every minute.
+func (w *loadWindow) enforce(limiter *rateLimiter, trickle float64) {
+	update := func() bool {
+		if w.Contains(time.Now()) {
+			limiter.ClearLimit("window")
+			return true
+		}
+		limiter.SetLimit("window", trickle)
+		return false
+	}
+
+	inside := update()
+	if !inside {
+		log.Printf("Outside of the load window, limiting to %.0f docs/sec", trickle)
+	}
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			wasInside := inside
+			inside = update()
+			switch {
+			case inside && !wasInside:
+				log.Printf("Load window opened, running at full speed")
+			case !inside && wasInside:
+				log.Printf("Load window closed, limiting to %.0f docs/sec", trickle)
+			}
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+package loadbooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLoadWindow(t *testing.T) {
+	window, err := parseLoadWindow("01:00-06:30", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.start != time.Hour || window.end != 6*time.Hour+30*time.Minute {
+		t.Errorf("window is %s-%s, want 1h0m0s-6h30m0s", window.start, window.end)
+	}
+	if window.location != time.UTC {
+		t.Errorf("window is in %s, want UTC", window.location)
+	}
+
+	window, err = parseLoadWindow(" 22:00 - 02:00 ", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.start != 22*time.Hour || window.end != 2*time.Hour || window.location.String() != "Europe/Berlin" {
+		t.Errorf("window is %s-%s in %s", window.start, window.end, window.location)
+	}
+}
+
+func TestParseLoadWindowErrors(t *testing.T) {
+	tests := []struct{ value, timezone string }{
+		{value: "", timezone: "UTC"},
+		{value: "01:00", timezone: "UTC"},
+		{value: "1am-6am", timezone: "UTC"},
+		{value: "25:00-06:00", timezone: "UTC"},
+		{value: "01:00-06:60", timezone: "UTC"},
+		{value: "01:00-06:00", timezone: "Nowhere/Special"},
+	}
+	for _, test := range tests {
+		if _, err := parseLoadWindow(test.value, test.timezone); err == nil {
+			t.Errorf("parseLoadWindow(%q, %q) succeeded, want an error", test.value, test.timezone)
+		}
+	}
+}
+
+func TestLoadWindowContains(t *testing.T) {
+	at := func(clock string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse("2006-01-02 15:04:05", "2024-03-01 "+clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	day, err := parseLoadWindow("01:00-06:00", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight, err := parseLoadWindow("22:00-02:00", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		window *loadWindow
+		clock  string
+		want   bool
+	}{
+		{window: day, clock: "00:59:59", want: false},
+		{window: day, clock: "01:00:00", want: true},
+		{window: day, clock: "05:59:59", want: true},
+		{window: day, clock: "06:00:00", want: false},
+		{window: overnight, clock: "21:59:59", want: false},
+		{window: overnight, clock: "22:00:00", want: true},
+		{window: overnight, clock: "00:30:00", want: true},
+		{window: overnight, clock: "02:00:00", want: false},
+		{window: overnight, clock: "12:00:00", want: false},
+	}
+	for _, test := range tests {
+		if got := test.window.Contains(at(test.clock)); got != test.want {
+			t.Errorf("%s-%s Contains(%s) = %t, want %t", test.window.start, test.window.end, test.clock, got, test.want)
+		}
+	}
+
+	// Times are compared in the window's zone
+	berlin, err := parseLoadWindow("01:00-06:00", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !berlin.Contains(at("00:30:00")) {
+		t.Error("00:30 UTC is 01:30 in Berlin, which should be inside 01:00-06:00")
+	}
+}
@@ -0,0 +1,78 @@
+// Package login saves encrypted credentials for a named profile.
+package login
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/credentials"
+)
+
+// Main runs login with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	profilePtr := flags.String("profile", "default", "Name of the profile to save credentials under, e.g. dev, staging, or prod")
+	fromEnvPtr := flags.Bool("from-env", false, "Save the ES_URL, ES_USER, and ES_PASSWORD from .env instead of prompting for them")
+	flags.Parse(args)
+
+	var creds credentials.Credentials
+	if *fromEnvPtr {
+		err := godotenv.Load()
+		if err != nil && os.Getenv("ES_URL") == "" {
+			log.Fatal("Error loading .env file")
+		}
+		creds = credentials.Credentials{
+			URL:      os.Getenv("ES_URL"),
+			User:     os.Getenv("ES_USER"),
+			Password: os.Getenv("ES_PASSWORD"),
+		}
+	} else {
+		stdin := bufio.NewReader(os.Stdin)
+		creds.URL = prompt(stdin, "Elasticsearch URL: ")
+		creds.User = prompt(stdin, "Access key: ")
+
+		password, err := credentials.ReadSecret("Access secret: ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		creds.Password = password
+	}
+
+	if creds.URL == "" {
+		log.Fatalf("No Elasticsearch URL provided")
+	}
+
+	passphrase, err := credentials.ReadSecret(fmt.Sprintf("New passphrase for profile %s: ", *profilePtr))
+	if err != nil {
+		log.Fatal(err)
+	}
+	confirm, err := credentials.ReadSecret("Confirm passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if passphrase == "" || passphrase != confirm {
+		log.Fatalf("Passphrases are empty or don't match")
+	}
+
+	if err := credentials.Save(*profilePtr, creds, passphrase); err != nil {
+		log.Fatal(err)
+	}
+
+	path, _ := credentials.Path(*profilePtr)
+	fmt.Printf("Saved encrypted credentials for profile %s to %s\n", *profilePtr, path)
+	fmt.Printf("Use them with -profile %s\n", *profilePtr)
+}
+
+func prompt(stdin *bufio.Reader, message string) string {
+	fmt.Fprint(os.Stderr, message)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal(err)
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,447 @@
+// Package migrate applies numbered mapping migrations to an alias, and
+// reports which have been applied.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/audit"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// Main runs migrate with the command line arguments after its name.
+func Main(args []string) {
+	if len(args) < 1 || (args[0] != "up" && args[0] != "status") {
+		fmt.Fprintf(os.Stderr, "Usage: migrate up|status [flags]\n\n  up      applies pending migrations in order\n  status  lists migrations and whether they have been applied\n")
+		os.Exit(2)
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	dirPtr := flags.String("dir", "migrations", "Directory of numbered migration files")
+	targetPtr := flags.String("target", "books", "Alias, or index, to migrate. Migrations that need a reindex only work on an alias")
+	stateIndexPtr := flags.String("state-index", "search-go-migrations", "Index recording which migrations have been applied")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	yesPtr := flags.Bool("yes-i-mean-prod", false, "Skip the confirmation prompt before a reindex when -profile is tagged production")
+	flags.Parse(args[1:])
+
+	// A reindex moves the target alias, so it has to name exactly one
+	if strings.ContainsAny(*targetPtr, "*?,") || *targetPtr == "_all" {
+		log.Fatalf("-target must be a single alias or index, not the pattern %q", *targetPtr)
+	}
+
+	migrations, err := loadMigrations(*dirPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	var activeProfile *profile.Profile
+	if *profilePtr != "" {
+		activeProfile, err = profile.Activate(*profilePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("migrate")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := loadState(client, *stateIndexPtr, *targetPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	index, isAlias, err := resolveTarget(client, *targetPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if command == "status" {
+		if err := status(client, migrations, s, index); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if s.applied(m.Version) != nil {
+			continue
+		}
+
+		liveMappings, liveSettings, err := liveIndex(client, index)
+		if err != nil {
+			log.Fatal(err)
+		}
+		strategy, reasons := m.strategy(liveMappings)
+		fmt.Printf("applying %s %s\n", m.Name, strategy)
+		for _, reason := range reasons {
+			fmt.Printf("  %s\n", reason)
+		}
+
+		if strategy == "reindex" {
+			if !isAlias {
+				log.Fatalf("%s needs a reindex, which only works when -target is an alias that can be moved to the new index", m.Name)
+			}
+			// A reindex moves the alias searches use, so production
+			// asks first
+			err = profile.ConfirmDestructive(activeProfile, fmt.Sprintf("reindex %s and move alias %s for %s", index, *targetPtr, m.Name), *yesPtr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			var auditLog *audit.Logger
+			auditLog, err = audit.New(client, "migrate", *profilePtr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			index, err = applyReindex(client, auditLog, *targetPtr, index, m, liveMappings, liveSettings)
+		} else {
+			err = applyInPlace(client, index, m)
+		}
+		if err != nil {
+			log.Fatalf("error applying %s: %v", m.Name, err)
+		}
+
+		s.Applied = append(s.Applied, appliedMigration{
+			Version:   m.Version,
+			Name:      m.Name,
+			Strategy:  strategy,
+			Index:     index,
+			AppliedAt: time.Now().UTC(),
+		})
+		if err := saveState(client, *stateIndexPtr, s); err != nil {
+			log.Fatal(err)
+		}
+		applied++
+	}
+	fmt.Printf("applied %d migrations to %s\n", applied, *targetPtr)
+}
+
+// status prints each migration and whether it has been applied, and for
+// pending ones how it would be applied to the live index.
+func status(client *elasticsearch7.Client, migrations []*migration, s *state, index string) error {
+	liveMappings, _, err := liveIndex(client, index)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if applied := s.applied(m.Version); applied != nil {
+			fmt.Printf("applied  %s %s to %s at %s\n", m.Name, applied.Strategy, applied.Index, applied.AppliedAt.Format(time.RFC3339))
+			continue
+		}
+		strategy, reasons := m.strategy(liveMappings)
+		fmt.Printf("pending  %s %s\n", m.Name, strategy)
+		for _, reason := range reasons {
+			fmt.Printf("  %s\n", reason)
+		}
+	}
+	return nil
+}
+
+// resolveTarget returns the index behind target, and whether target is an
+// alias.
+func resolveTarget(client *elasticsearch7.Client, target string) (string, bool, error) {
+	resp, err := client.Indices.GetAlias(client.Indices.GetAlias.WithName(target))
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		exists, err := client.Indices.Exists([]string{target})
+		if err != nil {
+			return "", false, err
+		}
+		exists.Body.Close()
+		if exists.IsError() {
+			return "", false, fmt.Errorf("%s is neither an alias nor an index", target)
+		}
+		return target, false, nil
+	}
+	if resp.IsError() {
+		return "", false, fmt.Errorf("error getting alias %s, status: %s, response body: %s", target, resp.Status(), resp.String())
+	}
+
+	var aliases map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		return "", false, err
+	}
+	if len(aliases) != 1 {
+		var indices []string
+		for index := range aliases {
+			indices = append(indices, index)
+		}
+		return "", false, fmt.Errorf("alias %s points to %d indices (%s), migrations need it to point to one", target, len(aliases), strings.Join(indices, ", "))
+	}
+	for index := range aliases {
+		return index, true, nil
+	}
+	return "", false, nil
+}
+
+// liveIndex returns the mappings and flat settings of index.
+func liveIndex(client *elasticsearch7.Client, index string) (map[string]interface{}, map[string]interface{}, error) {
+	mappingResp, err := client.Indices.GetMapping(client.Indices.GetMapping.WithIndex(index))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mappingResp.Body.Close()
+	if mappingResp.IsError() {
+		return nil, nil, fmt.Errorf("error getting the mappings of %s, status: %s, response body: %s", index, mappingResp.Status(), mappingResp.String())
+	}
+	var mappingResponse map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := json.NewDecoder(mappingResp.Body).Decode(&mappingResponse); err != nil {
+		return nil, nil, err
+	}
+
+	settingsResp, err := client.Indices.GetSettings(
+		client.Indices.GetSettings.WithIndex(index),
+		client.Indices.GetSettings.WithFlatSettings(true))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer settingsResp.Body.Close()
+	if settingsResp.IsError() {
+		return nil, nil, fmt.Errorf("error getting the settings of %s, status: %s, response body: %s", index, settingsResp.Status(), settingsResp.String())
+	}
+	var settingsResponse map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.NewDecoder(settingsResp.Body).Decode(&settingsResponse); err != nil {
+		return nil, nil, err
+	}
+
+	return mappingResponse[index].Mappings, settingsResponse[index].Settings, nil
+}
+
+// applyInPlace adds the migration's mappings to index and updates its
+// settings.
+func applyInPlace(client *elasticsearch7.Client, index string, m *migration) error {
+	if len(m.Mappings) > 0 {
+		body, err := json.Marshal(m.Mappings)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Indices.PutMapping(bytes.NewReader(body), client.Indices.PutMapping.WithIndex(index))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.IsError() {
+			return fmt.Errorf("error updating the mappings of %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+		}
+	}
+
+	if len(m.Settings) > 0 {
+		body, err := json.Marshal(m.flatSettings())
+		if err != nil {
+			return err
+		}
+		resp, err := client.Indices.PutSettings(bytes.NewReader(body), client.Indices.PutSettings.WithIndex(index))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.IsError() {
+			return fmt.Errorf("error updating the settings of %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+		}
+	}
+	return nil
+}
+
+// generatedSettings are settings Elasticsearch sets itself when creating
+// an index, by prefix, so they aren't copied to a new index.
+var generatedSettings = []string{
+	"index.uuid",
+	"index.creation_date",
+	"index.provided_name",
+	"index.version.",
+	"index.resize.",
+	"index.routing.allocation.initial_recovery.",
+	"index.blocks.",
+}
+
+// applyReindex creates a new index with the live mappings and settings
+// plus the migration's, copies every document into it and moves the alias
+// over in one step. The old index is left in place to roll back to or
+// prune later. The reindex and the alias move are recorded in the audit
+// log. It returns the new index.
+//
+// A run that failed part way leaves the migration pending, so it is
+// picked up again: a new index the alias doesn't point to yet is deleted
+// and built again, and an alias that was already moved only needs the
+// migration recorded.
+func applyReindex(client *elasticsearch7.Client, auditLog *audit.Logger, alias, oldIndex string, m *migration, liveMappings, liveSettings map[string]interface{}) (string, error) {
+	newIndex := fmt.Sprintf("%s-m%04d", alias, m.Version)
+	if oldIndex == newIndex {
+		fmt.Printf("  alias %s already points to %s\n", alias, newIndex)
+		return newIndex, nil
+	}
+	if err := deleteLeftover(client, auditLog, newIndex); err != nil {
+		return "", err
+	}
+
+	mappings := liveMappings
+	if mappings == nil {
+		mappings = map[string]interface{}{}
+	}
+	mergeMappings(mappings, m.Mappings)
+
+	settings := map[string]interface{}{}
+	for setting, value := range liveSettings {
+		generated := false
+		for _, prefix := range generatedSettings {
+			if setting == prefix || strings.HasPrefix(setting, prefix) {
+				generated = true
+			}
+		}
+		if !generated {
+			settings[setting] = value
+		}
+	}
+	for setting, value := range m.flatSettings() {
+		settings[setting] = value
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"settings": settings, "mappings": mappings})
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Indices.Create(newIndex, client.Indices.Create.WithBody(bytes.NewReader(body)))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return "", fmt.Errorf("error creating %s, status: %s, response body: %s", newIndex, resp.Status(), resp.String())
+	}
+	fmt.Printf("  created index %s\n", newIndex)
+
+	total, err := reindex(client, oldIndex, newIndex)
+	if err := auditLog.Record("reindex", oldIndex+" into "+newIndex, total, err); err != nil {
+		log.Printf("error writing audit log: %v", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("  reindexed %d documents from %s\n", total, oldIndex)
+
+	err = swapAlias(client, alias, oldIndex, newIndex)
+	if err := auditLog.Record("move alias", alias+" from "+oldIndex+" to "+newIndex, total, err); err != nil {
+		log.Printf("error writing audit log: %v", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("  moved alias %s from %s to %s\n", alias, oldIndex, newIndex)
+	return newIndex, nil
+}
+
+// deleteLeftover deletes index if an earlier run created it and failed
+// before moving the alias to it, so nothing searches it.
+func deleteLeftover(client *elasticsearch7.Client, auditLog *audit.Logger, index string) error {
+	exists, err := client.Indices.Exists([]string{index})
+	if err != nil {
+		return err
+	}
+	exists.Body.Close()
+	if exists.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if exists.IsError() {
+		return fmt.Errorf("error checking for %s, status: %s", index, exists.Status())
+	}
+
+	var deleteErr error
+	resp, err := client.Indices.Delete([]string{index})
+	if err != nil {
+		deleteErr = err
+	} else {
+		if resp.IsError() {
+			deleteErr = fmt.Errorf("error deleting %s, left by an earlier run, status: %s, response body: %s", index, resp.Status(), resp.String())
+		}
+		resp.Body.Close()
+	}
+	if err := auditLog.Record("delete", index+" left by an earlier run", 0, deleteErr); err != nil {
+		log.Printf("error writing audit log: %v", err)
+	}
+	if deleteErr != nil {
+		return deleteErr
+	}
+	fmt.Printf("  deleted %s, left half built by an earlier run\n", index)
+	return nil
+}
+
+// reindex copies every document of oldIndex into newIndex and returns how
+// many there were.
+func reindex(client *elasticsearch7.Client, oldIndex, newIndex string) (int64, error) {
+	reindexBody, err := json.Marshal(map[string]interface{}{
+		"source": map[string]string{"index": oldIndex},
+		"dest":   map[string]string{"index": newIndex},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Reindex(
+		bytes.NewReader(reindexBody),
+		client.Reindex.WithWaitForCompletion(true),
+		client.Reindex.WithRefresh(true))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return 0, fmt.Errorf("error reindexing %s into %s, status: %s, response body: %s", oldIndex, newIndex, resp.Status(), resp.String())
+	}
+	var reindexResponse struct {
+		Total    int64             `json:"total"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reindexResponse); err != nil {
+		return 0, err
+	}
+	if len(reindexResponse.Failures) > 0 {
+		return reindexResponse.Total, fmt.Errorf("%d documents failed to reindex into %s, the alias still points to %s: %s", len(reindexResponse.Failures), newIndex, oldIndex, reindexResponse.Failures[0])
+	}
+	return reindexResponse.Total, nil
+}
+
+// swapAlias moves alias from oldIndex to newIndex in one request.
+func swapAlias(client *elasticsearch7.Client, alias, oldIndex, newIndex string) error {
+	aliasBody, err := json.Marshal(map[string]interface{}{
+		"actions": []map[string]interface{}{
+			{"remove": map[string]string{"index": oldIndex, "alias": alias}},
+			{"add": map[string]string{"index": newIndex, "alias": alias}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Indices.UpdateAliases(bytes.NewReader(aliasBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return fmt.Errorf("error moving alias %s to %s, status: %s, response body: %s", alias, newIndex, resp.Status(), resp.String())
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nickcanz/search-go/internal/audit"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+func TestApplyReindexRerun(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldIndex string
+		// leftover is set when an earlier run created books-m0002 and
+		// failed before moving the alias
+		leftover bool
+		want     []string
+	}{
+		{
+			name:     "first run",
+			oldIndex: "books-1",
+			want:     []string{"HEAD /books-m0002", "PUT /books-m0002", "POST /_reindex", "POST /_aliases"},
+		},
+		{
+			name:     "after a failed reindex",
+			oldIndex: "books-1",
+			leftover: true,
+			want:     []string{"HEAD /books-m0002", "DELETE /books-m0002", "PUT /books-m0002", "POST /_reindex", "POST /_aliases"},
+		},
+		{
+			name:     "after the alias moved",
+			oldIndex: "books-m0002",
+			leftover: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []string
+			exists := test.leftover
+			cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodHead && !exists:
+					w.WriteHeader(http.StatusNotFound)
+				case r.Method == http.MethodDelete:
+					exists = false
+					w.Write([]byte(`{"acknowledged":true}`))
+				case r.Method == http.MethodPut && exists:
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error":{"type":"resource_already_exists_exception"},"status":400}`))
+				case r.Method == http.MethodPut:
+					exists = true
+					w.Write([]byte(`{"acknowledged":true}`))
+				case r.URL.Path == "/_reindex":
+					w.Write([]byte(`{"total":3,"failures":[]}`))
+				default:
+					w.Write([]byte(`{"acknowledged":true}`))
+				}
+			}))
+			defer cluster.Close()
+			client, err := esclient.NewClient(esclient.Config{URL: cluster.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("SEARCH_GO_AUDIT_LOG", filepath.Join(t.TempDir(), "audit.log"))
+			t.Setenv("SEARCH_GO_AUDIT_INDEX", "")
+			auditLog, err := audit.New(client, "migrate", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := &migration{Version: 2, Name: "0002_add_title_keyword", Settings: map[string]interface{}{"index": map[string]interface{}{"number_of_shards": 2}}}
+			newIndex, err := applyReindex(client, auditLog, "books", test.oldIndex, m, map[string]interface{}{}, map[string]interface{}{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if newIndex != "books-m0002" {
+				t.Errorf("migrated into %s, want books-m0002", newIndex)
+			}
+			if !reflect.DeepEqual(requests, test.want) {
+				t.Errorf("requests %v, want %v", requests, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"gopkg.in/yaml.v3"
+)
+
+// migration is one numbered change to an index's mappings or settings,
+// read from a file named like 0002_add_title_keyword.yaml.
+type migration struct {
+	Version     int                    `yaml:"-"`
+	Name        string                 `yaml:"-"`
+	Description string                 `yaml:"description"`
+	Mappings    map[string]interface{} `yaml:"mappings"`
+	Settings    map[string]interface{} `yaml:"settings"`
+}
+
+// loadMigrations reads every migration in dir, ordered by version.
+func loadMigrations(dir string) ([]*migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []*migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+			continue
+		}
+		number, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("%s: migration files are named <number>_<name>.yaml, e.g. 0001_add_indexed_at.yaml", filepath.Join(dir, name))
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("%s and %s have the same version %d", other, name, version)
+		}
+		seen[version] = name
+
+		path := filepath.Join(dir, name)
+		migrationBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		m := &migration{Version: version, Name: strings.TrimSuffix(strings.TrimSuffix(name, ".yaml"), ".yml")}
+		if err := yaml.Unmarshal(migrationBytes, m); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+		if len(m.Mappings) == 0 && len(m.Settings) == 0 {
+			return nil, fmt.Errorf("%s changes neither mappings nor settings", path)
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// flatSettings returns the migration's settings flattened the same way as
+// an index spec's.
+func (m *migration) flatSettings() map[string]string {
+	return (&indexspec.Spec{Settings: m.Settings}).FlatSettings()
+}
+
+// staticSettings are settings that can't be changed on an open index, by
+// prefix, so changing them takes a reindex.
+var staticSettings = []string{
+	"index.number_of_shards",
+	"index.number_of_routing_shards",
+	"index.analysis.",
+	"index.codec",
+	"index.sort.",
+	"index.similarity.",
+}
+
+func isStatic(setting string) bool {
+	for _, prefix := range staticSettings {
+		if setting == prefix || strings.HasPrefix(setting, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// strategy decides whether m can be applied to an index with the given
+// live mappings in place, by adding fields and changing dynamic settings,
+// or needs a reindex into a new index. It returns "in-place" or "reindex"
+// and, for a reindex, why.
+func (m *migration) strategy(liveMappings map[string]interface{}) (string, []string) {
+	var reasons []string
+
+	live := indexspec.Fields(liveMappings)
+	declared := indexspec.Fields(m.Mappings)
+	for _, field := range sortedKeys(declared) {
+		if liveDefinition, ok := live[field]; ok && liveDefinition != declared[field] {
+			reasons = append(reasons, fmt.Sprintf("field %s changes from %s to %s", field, liveDefinition, declared[field]))
+		}
+	}
+	settings := m.flatSettings()
+	for _, setting := range sortedKeys(settings) {
+		if isStatic(setting) {
+			reasons = append(reasons, fmt.Sprintf("setting %s can't change on an open index", setting))
+		}
+	}
+
+	if len(reasons) > 0 {
+		return "reindex", reasons
+	}
+	return "in-place", nil
+}
+
+// mergeMappings applies the mappings in src on top of dst, merging
+// fields by name with mergeFields.
+func mergeMappings(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if key != "properties" {
+			dst[key] = value
+			continue
+		}
+		srcProperties, _ := value.(map[string]interface{})
+		dstProperties, ok := dst["properties"].(map[string]interface{})
+		if !ok {
+			dstProperties = map[string]interface{}{}
+			dst["properties"] = dstProperties
+		}
+		mergeFields(dstProperties, srcProperties)
+	}
+}
+
+// subfieldKeys are the parameters of a field that hold more fields: an
+// object's properties and a multi-field's fields.
+var subfieldKeys = map[string]bool{"properties": true, "fields": true}
+
+// mergeFields merges the field definitions in src into dst. A field src
+// declares with a type replaces the parameters of dst's, while one without
+// a type, such as one only adding a multi-field, keeps dst's and changes
+// the ones it names. Subfields are merged the same way at every depth.
+func mergeFields(dst, src map[string]interface{}) {
+	for name, field := range src {
+		srcField, srcOK := field.(map[string]interface{})
+		dstField, dstOK := dst[name].(map[string]interface{})
+		if !srcOK || !dstOK {
+			dst[name] = field
+			continue
+		}
+
+		merged := map[string]interface{}{}
+		_, retyped := srcField["type"]
+		for key, value := range dstField {
+			if !retyped || subfieldKeys[key] {
+				merged[key] = value
+			}
+		}
+		for key, value := range srcField {
+			srcSubfields, srcOK := value.(map[string]interface{})
+			dstSubfields, dstOK := merged[key].(map[string]interface{})
+			if !subfieldKeys[key] || !srcOK || !dstOK {
+				merged[key] = value
+				continue
+			}
+			subfields := map[string]interface{}{}
+			for subfield, definition := range dstSubfields {
+				subfields[subfield] = definition
+			}
+			mergeFields(subfields, srcSubfields)
+			merged[key] = subfields
+		}
+		dst[name] = merged
+	}
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
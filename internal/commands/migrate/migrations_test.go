@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergeMappings(t *testing.T) {
+	const live = `{
+		"dynamic": "strict",
+		"properties": {
+			"title": {"type": "text", "analyzer": "english", "fields": {"raw": {"type": "keyword"}}},
+			"authors": {"properties": {"author_id": {"type": "keyword"}, "role": {"type": "keyword"}}},
+			"average_rating": {"type": "float"}
+		}
+	}`
+	tests := []struct {
+		name      string
+		migration string
+		want      string
+	}{
+		{
+			name:      "adds a multi-field",
+			migration: `{"properties": {"title": {"fields": {"keyword": {"type": "keyword", "normalizer": "book_keyword"}}}}}`,
+			want: `{
+				"title": {"type": "text", "analyzer": "english", "fields": {"raw": {"type": "keyword"}, "keyword": {"type": "keyword", "normalizer": "book_keyword"}}},
+				"authors": {"properties": {"author_id": {"type": "keyword"}, "role": {"type": "keyword"}}},
+				"average_rating": {"type": "float"}
+			}`,
+		},
+		{
+			name:      "changes one parameter",
+			migration: `{"properties": {"title": {"analyzer": "standard"}}}`,
+			want: `{
+				"title": {"type": "text", "analyzer": "standard", "fields": {"raw": {"type": "keyword"}}},
+				"authors": {"properties": {"author_id": {"type": "keyword"}, "role": {"type": "keyword"}}},
+				"average_rating": {"type": "float"}
+			}`,
+		},
+		{
+			name:      "retypes a field",
+			migration: `{"properties": {"title": {"type": "keyword"}, "average_rating": {"type": "scaled_float", "scaling_factor": 100}}}`,
+			want: `{
+				"title": {"type": "keyword", "fields": {"raw": {"type": "keyword"}}},
+				"authors": {"properties": {"author_id": {"type": "keyword"}, "role": {"type": "keyword"}}},
+				"average_rating": {"type": "scaled_float", "scaling_factor": 100}
+			}`,
+		},
+		{
+			name:      "changes a nested field",
+			migration: `{"properties": {"authors": {"properties": {"name": {"type": "text"}, "role": {"null_value": "author"}}}}}`,
+			want: `{
+				"title": {"type": "text", "analyzer": "english", "fields": {"raw": {"type": "keyword"}}},
+				"authors": {"properties": {"author_id": {"type": "keyword"}, "role": {"type": "keyword", "null_value": "author"}, "name": {"type": "text"}}},
+				"average_rating": {"type": "float"}
+			}`,
+		},
+		{
+			name:      "adds a field",
+			migration: `{"properties": {"indexed_at": {"type": "date"}}}`,
+			want: `{
+				"title": {"type": "text", "analyzer": "english", "fields": {"raw": {"type": "keyword"}}},
+				"authors": {"properties": {"author_id": {"type": "keyword"}, "role": {"type": "keyword"}}},
+				"average_rating": {"type": "float"},
+				"indexed_at": {"type": "date"}
+			}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var dst, src, want map[string]interface{}
+			for _, decode := range []struct {
+				from string
+				into *map[string]interface{}
+			}{{live, &dst}, {test.migration, &src}, {test.want, &want}} {
+				if err := json.Unmarshal([]byte(decode.from), decode.into); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mergeMappings(dst, src)
+
+			if dst["dynamic"] != "strict" {
+				t.Errorf("dynamic is %v, want it kept", dst["dynamic"])
+			}
+			got, _ := json.Marshal(dst["properties"])
+			wantJSON, _ := json.Marshal(want)
+			if string(got) != string(wantJSON) {
+				t.Errorf("merged properties\n%s\nwant\n%s", got, wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+// appliedMigration records one migration applied to a target.
+type appliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Strategy  string    `json:"strategy"`
+	Index     string    `json:"index"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// state is the document in the state index, with the target as its _id,
+// that tracks which migrations have been applied to the target.
+type state struct {
+	Target  string             `json:"target"`
+	Applied []appliedMigration `json:"applied"`
+}
+
+func (s *state) applied(version int) *appliedMigration {
+	for i := range s.Applied {
+		if s.Applied[i].Version == version {
+			return &s.Applied[i]
+		}
+	}
+	return nil
+}
+
+// loadState returns the target's state, which is empty if nothing has
+// been applied to it yet.
+func loadState(client *elasticsearch7.Client, stateIndex, target string) (*state, error) {
+	resp, err := client.Get(stateIndex, target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &state{Target: target}, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting the migration state of %s, status: %s, response body: %s", target, resp.Status(), resp.String())
+	}
+
+	var getResponse struct {
+		Source state `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&getResponse); err != nil {
+		return nil, err
+	}
+	return &getResponse.Source, nil
+}
+
+// saveState writes the target's state, refreshing so the next run sees it.
+func saveState(client *elasticsearch7.Client, stateIndex string, s *state) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Index(
+		stateIndex,
+		bytes.NewReader(body),
+		client.Index.WithDocumentID(s.Target),
+		client.Index.WithRefresh("true"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("error saving the migration state of %s, status: %s, response body: %s", s.Target, resp.Status(), resp.String())
+	}
+	return nil
+}
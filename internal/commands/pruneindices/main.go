@@ -0,0 +1,168 @@
+// Package pruneindices deletes dated indices older than a retention
+// period.
+package pruneindices
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/audit"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/targets"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// Main runs prune-indices with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("prune-indices", flag.ExitOnError)
+	patternPtr := flags.String("pattern", "", "Index pattern to prune, e.g. books-*")
+	olderThanPtr := flags.Int("older-than", 0, "Prune indices older than this many days")
+	actionPtr := flags.String("action", "delete", "What to do with old indices: delete or close")
+	nameDateLayoutPtr := flags.String("name-date-layout", "", "Take the index date from the end of its name using this Go time layout, e.g. 2006.01.02, instead of the creation date")
+	includeAliasedPtr := flags.Bool("include-aliased", false, "Also prune indices an alias points to, which are skipped by default since searches may still use them")
+	dryRunPtr := flags.Bool("dry-run", false, "Only print what would be pruned")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	yesPtr := flags.Bool("yes", false, "Skip confirming the indices the pattern resolves to")
+	yesProdPtr := flags.Bool("yes-i-mean-prod", false, "Skip the confirmation prompt when -profile is tagged production")
+	flags.Parse(args)
+
+	if *patternPtr == "" {
+		log.Fatalf("No index pattern provided for -pattern parameter")
+	}
+	if targets.MatchesEverything(*patternPtr) {
+		log.Fatalf("-pattern %q matches every index, use a narrower pattern such as books-*", *patternPtr)
+	}
+	if *olderThanPtr <= 0 {
+		log.Fatalf("-older-than must be at least 1 day")
+	}
+	if *actionPtr != "delete" && *actionPtr != "close" {
+		log.Fatalf("Unknown -action %q, expected delete or close", *actionPtr)
+	}
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	var activeProfile *profile.Profile
+	if *profilePtr != "" {
+		activeProfile, err = profile.Activate(*profilePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("prune-indices")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	indices, err := targets.Resolve(client, *patternPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -*olderThanPtr)
+	var pruning []targets.Index
+	for _, index := range indices {
+		created, err := indexDate(index, *nameDateLayoutPtr)
+		if err != nil {
+			log.Printf("skipping %s: %v", index.Name, err)
+			continue
+		}
+		if !created.Before(cutoff) {
+			continue
+		}
+		if *actionPtr == "close" && index.Status == "close" {
+			continue
+		}
+		// The pattern may match the index an alias like books points to,
+		// which is old only because it hasn't been reloaded in a while
+		if len(index.Aliases) > 0 && !*includeAliasedPtr {
+			log.Printf("skipping %s: alias %s points to it", index.Name, strings.Join(index.Aliases, ", "))
+			continue
+		}
+
+		fmt.Printf("%s %s (created %s, %d docs)\n", *actionPtr, index.Name, created.Format("2006-01-02"), index.Docs)
+		pruning = append(pruning, index)
+	}
+
+	if *dryRunPtr {
+		fmt.Println("Dry run, no indices were changed")
+		return
+	}
+	if len(pruning) == 0 {
+		return
+	}
+
+	if err := targets.Confirm(*actionPtr, *patternPtr, pruning, *yesPtr); err != nil {
+		log.Fatal(err)
+	}
+	var docs int64
+	for _, index := range pruning {
+		docs += index.Docs
+	}
+	err = profile.ConfirmDestructive(activeProfile, fmt.Sprintf("%s %d indices with %d docs", *actionPtr, len(pruning), docs), *yesProdPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auditLog, err := audit.New(client, "prune-indices", *profilePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Indices are pruned by name, so an index created to match the
+	// pattern after it was confirmed is left alone
+	for _, index := range pruning {
+		pruneErr := prune(client, *actionPtr, index.Name)
+		if err := auditLog.Record(*actionPtr, index.Name, index.Docs, pruneErr); err != nil {
+			log.Printf("error writing audit log: %v", err)
+		}
+		if pruneErr != nil {
+			log.Fatal(pruneErr)
+		}
+	}
+}
+
+// indexDate returns when an index was created, either from its creation
+// date setting or, given a layout, from the date at the end of its name.
+func indexDate(index targets.Index, nameDateLayout string) (time.Time, error) {
+	if nameDateLayout != "" {
+		if len(index.Name) < len(nameDateLayout) {
+			return time.Time{}, fmt.Errorf("name is shorter than %q", nameDateLayout)
+		}
+		return time.Parse(nameDateLayout, index.Name[len(index.Name)-len(nameDateLayout):])
+	}
+
+	if index.Created.IsZero() {
+		return time.Time{}, fmt.Errorf("no creation date")
+	}
+	return index.Created, nil
+}
+
+func prune(client *elasticsearch7.Client, action, index string) error {
+	var resp *esapi.Response
+	var err error
+	switch action {
+	case "delete":
+		resp, err = client.Indices.Delete([]string{index})
+	case "close":
+		resp, err = client.Indices.Close([]string{index})
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("error running %s on %s, status: %s, response body: %s", action, index, resp.Status(), resp.String())
+	}
+	return nil
+}
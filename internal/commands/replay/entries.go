@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/tombstone"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+// logLine is one line of a query log. Lines written by other tools give
+// the search text in query, lines of an Elasticsearch search slow log give
+// the request body as a string in source.
+type logLine struct {
+	Timestamp   string          `json:"timestamp"`
+	AtTimestamp string          `json:"@timestamp"`
+	Query       string          `json:"query"`
+	Body        json.RawMessage `json:"body"`
+	Source      string          `json:"source"`
+}
+
+// entry is a search to replay.
+type entry struct {
+	line int
+	// time is zero when the line had no timestamp
+	time  time.Time
+	label string
+	body  []byte
+}
+
+// timestampLayouts are the timestamp formats of query logs and of
+// Elasticsearch's JSON logs, which separate seconds from milliseconds with
+// a comma.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05,000Z07:00",
+	"2006-01-02T15:04:05,000Z0700",
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown timestamp format %q", value)
+}
+
+// readEntries reads every search in a query log, skipping blank lines.
+// Query text is searched the way search-books searches it by default, in
+// the title, url and description of books that aren't deleted, size at a
+// time.
+func readEntries(r io.Reader, size, limit int) ([]entry, error) {
+	var entries []entry
+	scanner := bufio.NewScanner(r)
+	// Slow log lines carry whole request bodies
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+
+		var line logLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		e := entry{line: number}
+
+		timestamp := line.Timestamp
+		if timestamp == "" {
+			timestamp = line.AtTimestamp
+		}
+		if timestamp != "" {
+			t, err := parseTimestamp(timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number, err)
+			}
+			e.time = t
+		}
+
+		switch {
+		case len(line.Body) > 0:
+			e.body = line.Body
+			e.label = string(line.Body)
+		case line.Source != "":
+			e.body = []byte(line.Source)
+			e.label = line.Source
+		case line.Query != "":
+			body, err := json.Marshal(map[string]interface{}{
+				"query": tombstone.Live(query.MultiMatch(line.Query, "title", "url", "description")),
+				"size":  size,
+			})
+			if err != nil {
+				return nil, err
+			}
+			e.body = body
+			e.label = line.Query
+		default:
+			return nil, fmt.Errorf("line %d has no query, body or source to replay", number)
+		}
+		if len(e.label) > 80 {
+			e.label = e.label[:77] + "..."
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// offsets returns how long after the start of a replay at speed each entry
+// is sent: the time since the first entry divided by speed. Entries without
+// a timestamp are sent with the one before them, and every entry is sent
+// straight away when speed is 0.
+func offsets(entries []entry, speed float64) []time.Duration {
+	result := make([]time.Duration, len(entries))
+	if speed == 0 {
+		return result
+	}
+	var first time.Time
+	var previous time.Duration
+	for i, e := range entries {
+		if e.time.IsZero() {
+			result[i] = previous
+			continue
+		}
+		if first.IsZero() {
+			first = e.time
+		}
+		offset := time.Duration(float64(e.time.Sub(first)) / speed)
+		// Logs written by several nodes can be slightly out of order
+		if offset < previous {
+			offset = previous
+		}
+		result[i] = offset
+		previous = offset
+	}
+	return result
+}
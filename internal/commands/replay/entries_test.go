@@ -0,0 +1,116 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLog = `{"timestamp":"2024-03-01T12:00:00Z","query":"dragons"}
+
+{"@timestamp":"2024-03-01T12:00:01,500Z","source":"{\"query\":{\"match_all\":{}}}"}
+{"body":{"query":{"term":{"isbn":"0618640150"}},"size":1}}
+{"timestamp":"2024-03-01T12:00:00.250Z","query":"elves"}
+`
+
+func TestReadEntries(t *testing.T) {
+	entries, err := readEntries(strings.NewReader(testLog), 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("read %d entries, want 4", len(entries))
+	}
+
+	lines := []int{1, 3, 4, 5}
+	labels := []string{"dragons", `{"query":{"match_all":{}}}`, `{"query":{"term":{"isbn":"0618640150"}},"size":1}`, "elves"}
+	for i, e := range entries {
+		if e.line != lines[i] || e.label != labels[i] {
+			t.Errorf("entry %d is line %d %q, want line %d %q", i, e.line, e.label, lines[i], labels[i])
+		}
+	}
+	if want := `{"query":{"bool":{"must":[{"multi_match":{"fields":["title","url","description"],"query":"dragons"}}],"must_not":[{"term":{"deleted":{"value":true}}}]}},"size":5}`; string(entries[0].body) != want {
+		t.Errorf("query text became %s, want %s", entries[0].body, want)
+	}
+	if !entries[2].time.IsZero() {
+		t.Errorf("line without a timestamp has time %s", entries[2].time)
+	}
+	if want := time.Date(2024, 3, 1, 12, 0, 1, 500e6, time.UTC); !entries[1].time.Equal(want) {
+		t.Errorf("slow log timestamp is %s, want %s", entries[1].time, want)
+	}
+}
+
+func TestReadEntriesLimit(t *testing.T) {
+	entries, err := readEntries(strings.NewReader(testLog), 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("read %d entries with a limit of 2", len(entries))
+	}
+}
+
+func TestReadEntriesErrors(t *testing.T) {
+	for _, log := range []string{
+		"not json\n",
+		`{"timestamp":"yesterday","query":"dragons"}`,
+		`{"timestamp":"2024-03-01T12:00:00Z"}`,
+	} {
+		if _, err := readEntries(strings.NewReader(log), 10, 0); err == nil {
+			t.Errorf("readEntries(%q) succeeded, want an error", log)
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	entries, err := readEntries(strings.NewReader(testLog), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last line is older than the one before it, so it is sent
+	// straight after instead of going back in time
+	want := []time.Duration{0, 750 * time.Millisecond, 750 * time.Millisecond, 750 * time.Millisecond}
+	got := offsets(entries, 2)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("offsets at speed 2 = %v, want %v", got, want)
+			break
+		}
+	}
+	for _, offset := range offsets(entries, 0) {
+		if offset != 0 {
+			t.Errorf("offsets at speed 0 = %v, want all 0", offsets(entries, 0))
+			break
+		}
+	}
+}
+
+func FuzzReadEntries(f *testing.F) {
+	f.Add(testLog)
+	f.Add(`{"query":"` + strings.Repeat("é", 100) + `"}`)
+	f.Add(`{"body":null}`)
+	f.Add(`{"@timestamp":"2024-03-01T12:00:01,500+0100","source":"x"}`)
+	f.Fuzz(func(t *testing.T, log string) {
+		entries, err := readEntries(strings.NewReader(log), 10, 0)
+		if err != nil {
+			return
+		}
+		previous := 0
+		for _, e := range entries {
+			if e.line <= previous {
+				t.Fatalf("entry for line %d came after line %d", e.line, previous)
+			}
+			previous = e.line
+			if len(e.body) == 0 {
+				t.Fatalf("line %d has nothing to send", e.line)
+			}
+		}
+		last := time.Duration(0)
+		for _, offset := range offsets(entries, 1) {
+			if offset < last {
+				t.Fatalf("offsets go back in time: %v", offsets(entries, 1))
+			}
+			last = offset
+		}
+	})
+}
@@ -0,0 +1,318 @@
+// Package replay runs the searches in a query log against an index, and
+// optionally compares them with a baseline.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// result is how one index answered one search.
+type result struct {
+	latency time.Duration
+	took    int
+	total   int64
+	ids     []string
+	err     error
+}
+
+// outcome is a replayed search, with how far behind schedule it was sent.
+type outcome struct {
+	entry    entry
+	lag      time.Duration
+	target   result
+	baseline *result
+}
+
+type searchResponse struct {
+	Took int `json:"took"`
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			ID string `json:"_id"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Main runs replay with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	filePtr := flags.String("file", "", "Query log to replay, one JSON object per line with a query, body or source (an Elasticsearch search slow log)")
+	indexPtr := flags.String("index", "books", "Index or alias to replay the searches against")
+	baselinePtr := flags.String("baseline", "", "Index or alias to also run every search against and compare results and latency with")
+	speedPtr := flags.Float64("speed", 1, "Pace relative to the log's timestamps, e.g. 2 for twice as fast; 0 sends searches as fast as -concurrency allows")
+	concurrencyPtr := flags.Int("concurrency", 8, "Maximum number of searches in flight at once")
+	sizePtr := flags.Int("size", 10, "Number of hits to request for log lines that only give query text")
+	limitPtr := flags.Int("limit", 0, "Only replay this many searches from the start of the log (disabled when 0)")
+	showDiffsPtr := flags.Bool("show-diffs", false, "Print every search whose hits differ between -index and -baseline")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	if *filePtr == "" {
+		log.Fatalf("No query log provided for -file parameter")
+	}
+	if *speedPtr < 0 {
+		log.Fatalf("-speed must be at least 0, got %g", *speedPtr)
+	}
+	if *concurrencyPtr < 1 {
+		log.Fatalf("-concurrency must be at least 1, got %d", *concurrencyPtr)
+	}
+	if *showDiffsPtr && *baselinePtr == "" {
+		log.Fatalf("-show-diffs needs a -baseline to compare with")
+	}
+
+	file, err := os.Open(*filePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := readEntries(file, *sizePtr, *limitPtr)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", *filePtr, err)
+	}
+	if len(entries) == 0 {
+		log.Fatalf("%s has no searches to replay", *filePtr)
+	}
+
+	err = godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("replay")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Replaying %d searches from %s against %s\n", len(entries), *filePtr, *indexPtr)
+	started := time.Now()
+	outcomes := replay(client, entries, offsets(entries, *speedPtr), *indexPtr, *baselinePtr, *concurrencyPtr)
+	elapsed := time.Since(started).Round(time.Millisecond)
+
+	for _, o := range outcomes {
+		if o.target.err != nil {
+			log.Printf("line %d against %s: %v", o.entry.line, *indexPtr, o.target.err)
+		}
+		if o.baseline != nil && o.baseline.err != nil {
+			log.Printf("line %d against %s: %v", o.entry.line, *baselinePtr, o.baseline.err)
+		}
+	}
+	if *showDiffsPtr {
+		printDiffs(outcomes, *indexPtr, *baselinePtr)
+	}
+	if err := printReport(outcomes, elapsed, *indexPtr, *baselinePtr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// replay sends each entry at its offset from the start, with at most
+// concurrency searches in flight. A search that can't be sent on time
+// because all of them are busy is sent as soon as one finishes, and how
+// late it was is recorded as its lag. Outcomes are returned in log order.
+func replay(client *elasticsearch7.Client, entries []entry, offsets []time.Duration, index, baseline string, concurrency int) []outcome {
+	outcomes := make([]outcome, len(entries))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i, e := range entries {
+		if wait := time.Until(start.Add(offsets[i])); wait > 0 {
+			time.Sleep(wait)
+		}
+		slots <- struct{}{}
+		lag := time.Since(start.Add(offsets[i]))
+
+		wg.Add(1)
+		go func(i int, e entry, lag time.Duration) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			o := outcome{entry: e, lag: lag, target: search(client, index, e.body)}
+			if baseline != "" {
+				r := search(client, baseline, e.body)
+				o.baseline = &r
+			}
+			outcomes[i] = o
+		}(i, e, lag)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// search runs body against index. Only the IDs and totals of the hits are
+// returned by Elasticsearch, so decoding large sources doesn't add to the
+// measured latency.
+func search(client *elasticsearch7.Client, index string, body []byte) result {
+	started := time.Now()
+	resp, err := client.Search(
+		client.Search.WithIndex(index),
+		client.Search.WithBody(bytes.NewReader(body)),
+		client.Search.WithFilterPath("took", "hits.total", "hits.hits._id"))
+	if err != nil {
+		return result{err: err}
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return result{err: fmt.Errorf("status: %s, response body: %s", resp.Status(), resp.String())}
+	}
+
+	var response searchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return result{err: err}
+	}
+	r := result{latency: time.Since(started), took: response.Took, total: response.Hits.Total.Value}
+	for _, hit := range response.Hits.Hits {
+		r.ids = append(r.ids, hit.ID)
+	}
+	return r
+}
+
+// overlap returns the share of hits two results have in common, ignoring
+// their order. Two results without hits overlap completely.
+func overlap(a, b result) float64 {
+	most := len(a.ids)
+	if len(b.ids) > most {
+		most = len(b.ids)
+	}
+	if most == 0 {
+		return 1
+	}
+	inA := map[string]bool{}
+	for _, id := range a.ids {
+		inA[id] = true
+	}
+	common := 0
+	for _, id := range b.ids {
+		if inA[id] {
+			common++
+		}
+	}
+	return float64(common) / float64(most)
+}
+
+// differs reports whether two results have different hits or hits in a
+// different order.
+func differs(a, b result) bool {
+	return strings.Join(a.ids, "\x00") != strings.Join(b.ids, "\x00")
+}
+
+// compared returns the outcomes that succeeded against both indices.
+func compared(outcomes []outcome) []outcome {
+	var both []outcome
+	for _, o := range outcomes {
+		if o.baseline != nil && o.target.err == nil && o.baseline.err == nil {
+			both = append(both, o)
+		}
+	}
+	return both
+}
+
+func printDiffs(outcomes []outcome, index, baseline string) {
+	for _, o := range compared(outcomes) {
+		if !differs(o.target, *o.baseline) {
+			continue
+		}
+		fmt.Printf("line %d: %s\n", o.entry.line, o.entry.label)
+		fmt.Printf("  %s: %d total, %s\n", index, o.target.total, strings.Join(o.target.ids, ", "))
+		fmt.Printf("  %s: %d total, %s\n", baseline, o.baseline.total, strings.Join(o.baseline.ids, ", "))
+	}
+}
+
+// printReport prints latency percentiles per index and, with a baseline,
+// how often and how much the results differed.
+func printReport(outcomes []outcome, elapsed time.Duration, index, baseline string) error {
+	var maxLag time.Duration
+	for _, o := range outcomes {
+		if o.lag > maxLag {
+			maxLag = o.lag
+		}
+	}
+	fmt.Printf("Replayed %d searches in %s, at most %s behind schedule\n\n", len(outcomes), elapsed, maxLag.Round(time.Millisecond))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tERRORS\tP50\tP90\tP99\tMAX\tTOOK P50")
+	printLatencies(w, index, outcomes, func(o outcome) *result { return &o.target })
+	if baseline != "" {
+		printLatencies(w, baseline, outcomes, func(o outcome) *result { return o.baseline })
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if baseline == "" {
+		return nil
+	}
+
+	both := compared(outcomes)
+	if len(both) == 0 {
+		fmt.Printf("\nNo search succeeded against both %s and %s to compare\n", index, baseline)
+		return nil
+	}
+	different := 0
+	totalOverlap := 0.0
+	for _, o := range both {
+		if differs(o.target, *o.baseline) {
+			different++
+		}
+		totalOverlap += overlap(o.target, *o.baseline)
+	}
+	fmt.Printf("\nHits differed for %d of %d searches, which had %.1f%% of their hits in common on average\n",
+		different, len(both), 100*totalOverlap/float64(len(both)))
+	return nil
+}
+
+// printLatencies writes a row of client latency percentiles and the median
+// server took time for the results of one index.
+func printLatencies(w *tabwriter.Writer, name string, outcomes []outcome, pick func(outcome) *result) {
+	var latencies []time.Duration
+	var tooks []int
+	errors := 0
+	for _, o := range outcomes {
+		r := pick(o)
+		if r.err != nil {
+			errors++
+			continue
+		}
+		latencies = append(latencies, r.latency)
+		tooks = append(tooks, r.took)
+	}
+	if len(latencies) == 0 {
+		fmt.Fprintf(w, "%s\t%d\t-\t-\t-\t-\t-\n", name, errors)
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	sort.Ints(tooks)
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%dms\n", name, errors,
+		percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99),
+		latencies[len(latencies)-1].Round(time.Millisecond), tooks[len(tooks)/2])
+}
+
+// percentile returns the pth percentile of sorted latencies, by the nearest
+// rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Round(time.Millisecond)
+}
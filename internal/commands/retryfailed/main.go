@@ -0,0 +1,168 @@
+// Package retryfailed resubmits the documents load-books recorded in its
+// dead-letter file.
+package retryfailed
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/changelog"
+	"github.com/nickcanz/search-go/internal/deadletter"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/upsert"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// Main runs retry-failed with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("retry-failed", flag.ExitOnError)
+	filePtr := flags.String("file", "failed.json", "Dead-letter file written by load-books to resubmit")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	// The file stays locked until it is rewritten, so a load can't add
+	// failures to it that the rewrite would then lose
+	deadLetters, err := deadletter.Lock(*filePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer deadLetters.Close()
+	entries, err := deadLetters.Entries()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("Nothing to retry in %s\n", *filePtr)
+		return
+	}
+
+	err = godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("retry-failed")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	changes, err := changelog.FromEnv(client, "retry-failed")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Documents that fail again, and the ones that can't be retried, are
+	// only written back once every entry was tried, so a crash part way
+	// through leaves the file as it was
+	indexed, failed, skipped, err := retry(client, changes, entries)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	remaining := append(skipped, failed...)
+	if len(remaining) > 0 {
+		err = deadLetters.Rewrite(remaining)
+	} else {
+		err = deadLetters.Remove()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Retried %d documents, %d indexed, %d failed again, %d skipped\n", len(entries)-len(skipped), indexed, len(failed), len(skipped))
+	if len(skipped) > 0 {
+		log.Printf("%d documents a transform failed on were kept in %s, rerun load-books for them", len(skipped), *filePtr)
+	}
+	if len(failed) > 0 {
+		log.Fatalf("%d documents failed again and were written back to %s", len(failed), *filePtr)
+	}
+}
+
+// retry resubmits entries, returning how many were indexed, the ones that
+// failed again and the ones that can't be retried.
+func retry(client *elasticsearch7.Client, changes *changelog.Writer, entries []deadletter.Entry) (indexed uint64, failed, skipped []deadletter.Entry, err error) {
+	var mu sync.Mutex
+
+	// Bulk items can't set their own routing, so entries are grouped into
+	// a bulk indexer per routing value
+	bulkIndexers := map[string]esutil.BulkIndexer{}
+	for _, entry := range entries {
+		entry := entry
+		// Resubmitting these would index them without their transforms,
+		// so they stay in the file until the load is rerun for them
+		if entry.Untransformed {
+			log.Printf("Skipping a document a transform failed on, rerun load-books for it: %s", entry.Error)
+			skipped = append(skipped, entry)
+			continue
+		}
+		bulkIndexer, ok := bulkIndexers[entry.Routing]
+		if !ok {
+			bulkIndexer, err = esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+				Client:  client,
+				Routing: entry.Routing,
+				OnError: func(ctx context.Context, err error) {
+					log.Fatalf("bulkindexer OnError %#v", err)
+				},
+			})
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			bulkIndexers[entry.Routing] = bulkIndexer
+		}
+
+		// Documents with an id are upserted the way load-books writes
+		// them, so a copy indexed since keeps its timestamps
+		action, body := "index", []byte(entry.Document)
+		if entry.DocumentID != "" {
+			action = "update"
+			body, err = upsert.Body(entry.Document)
+			if err != nil {
+				return 0, nil, nil, fmt.Errorf("error marshalling json: %w", err)
+			}
+		}
+
+		err = bulkIndexer.Add(
+			context.Background(),
+			esutil.BulkIndexerItem{
+				Index:      entry.Index,
+				Action:     action,
+				DocumentID: entry.DocumentID,
+				Body:       bytes.NewReader(body),
+				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+					changes.Record(res.Index, res.DocumentID, changelog.Op(item.Action, res.Result))
+				},
+				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+					retry := deadletter.NewEntry(entry.Index, entry.Routing, entry.Document, item, res, err)
+					log.Printf("ERROR: %s", retry.Error)
+					mu.Lock()
+					failed = append(failed, retry)
+					mu.Unlock()
+				},
+			})
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("error adding item to bulk indexer: %w", err)
+		}
+	}
+
+	for _, bulkIndexer := range bulkIndexers {
+		if err := bulkIndexer.Close(context.Background()); err != nil {
+			return 0, nil, nil, err
+		}
+		stats := bulkIndexer.Stats()
+		// Documents with an id are upserted, which counts them as updates
+		indexed += stats.NumIndexed + stats.NumUpdated
+	}
+	return indexed, failed, skipped, nil
+}
@@ -0,0 +1,73 @@
+package retryfailed
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/deadletter"
+	"github.com/nickcanz/search-go/internal/faults"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// TestRetryWithFaults reruns retry on what failed again, as a user would,
+// against a cluster that keeps rejecting some documents, and checks that
+// it converges with every document indexed and none lost on the way.
+func TestRetryWithFaults(t *testing.T) {
+	cluster := faults.NewCluster()
+	defer cluster.Close()
+	transport := faults.New(nil, faults.Config{
+		TooManyRequests: 0.2,
+		Reset:           0.1,
+		BulkItem:        0.3,
+	})
+	client, err := esclient.NewClient(esclient.Config{
+		URL:          cluster.URL,
+		Transport:    transport,
+		MaxRetries:   20,
+		RetryBackoff: func(int) time.Duration { return time.Millisecond },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const documents = 100
+	var entries []deadletter.Entry
+	for i := 0; i < documents; i++ {
+		entries = append(entries, deadletter.Entry{
+			Index:      "books",
+			DocumentID: fmt.Sprint(i),
+			Status:     429,
+			Error:      "es_rejected_execution_exception: rejected execution",
+			Document:   json.RawMessage(fmt.Sprintf(`{"title":"Book %d"}`, i)),
+		})
+	}
+	untransformed := deadletter.Entry{Index: "books", Error: "transform timed out", Document: json.RawMessage(`{"title":"Slow"}`), Untransformed: true}
+	entries = append(entries, untransformed)
+
+	rounds := 0
+	for ; rounds < 50; rounds++ {
+		indexed, failed, skipped, err := retry(client, nil, entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(skipped) != 1 {
+			t.Fatalf("skipped %d entries, want only the untransformed one", len(skipped))
+		}
+		if int(indexed)+len(failed) != len(entries)-1 {
+			t.Fatalf("indexed %d and %d failed again out of %d retried", indexed, len(failed), len(entries)-1)
+		}
+		entries = append(skipped, failed...)
+		if len(failed) == 0 {
+			break
+		}
+	}
+
+	if stored := cluster.Documents("books"); len(stored) != documents {
+		t.Errorf("%d documents indexed after %d rounds, want %d", len(stored), rounds+1, documents)
+	}
+	if rounds == 0 {
+		t.Errorf("everything was indexed on the first round, so nothing was retried, injected %+v", transport.Counts())
+	}
+}
@@ -0,0 +1,131 @@
+// Package scripttest runs a painless script_score against the books
+// index, for iterating on ranking scripts.
+package scripttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/tombstone"
+	"github.com/nickcanz/search-go/pkg/esclient"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+type Book struct {
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type BookSearchResponse struct {
+	Took float64 `json:"took"`
+	Hits struct {
+		Hits []struct {
+			ID    string  `json:"_id"`
+			Book  Book    `json:"_source"`
+			Score float64 `json:"_score"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Main runs script-test with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("script-test", flag.ExitOnError)
+	scriptPtr := flags.String("script", "", "Painless script_score source, e.g. \"Math.log(2 + _score)\"")
+	scriptFilePtr := flags.String("script-file", "", "File containing the painless script, instead of -script")
+	paramsPtr := flags.String("params", "", "JSON object of parameters available to the script as params")
+	queryPtr := flags.String("query", "", "Only score books matching this query, all books when empty")
+	indexPtr := flags.String("index", "books", "Index to sample books from")
+	datasetPtr := flags.String("dataset", "", "Use the index of this dataset from the catalog instead of -index")
+	sizePtr := flags.Int("size", 10, "Number of books to score")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	if *datasetPtr != "" {
+		spec, err := indexspec.LoadDataset(*datasetPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*indexPtr = spec.SearchIndex()
+	}
+
+	script := *scriptPtr
+	if *scriptFilePtr != "" {
+		scriptBytes, err := os.ReadFile(*scriptFilePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		script = string(scriptBytes)
+	}
+	if script == "" {
+		log.Fatalf("No script provided for -script or -script-file parameter")
+	}
+
+	var params map[string]interface{}
+	if *paramsPtr != "" {
+		if err := json.Unmarshal([]byte(*paramsPtr), &params); err != nil {
+			log.Fatalf("Error parsing -params as a JSON object: %v", err)
+		}
+	}
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("script-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var matching query.Query = query.MatchAll()
+	if *queryPtr != "" {
+		matching = query.MultiMatch(*queryPtr, "title", "url", "description")
+	}
+	matching = tombstone.Live(matching)
+
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"query": query.ScriptScore(matching, script).Params(params),
+		"size":  *sizePtr,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := client.Search(
+		client.Search.WithIndex(*indexPtr),
+		client.Search.WithBody(bytes.NewReader(requestBody)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		log.Fatalf("Error running script, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var bookSearchResponse BookSearchResponse
+	err = json.NewDecoder(resp.Body).Decode(&bookSearchResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, bookHit := range bookSearchResponse.Hits.Hits {
+		fmt.Printf("%f  %s\n", bookHit.Score, bookHit.ID)
+		fmt.Printf("    title: %s\n", bookHit.Book.Title)
+		fmt.Printf("    url: %s\n", bookHit.Book.Url)
+		fmt.Printf("    description: %d characters\n", len(bookHit.Book.Description))
+	}
+}
@@ -0,0 +1,146 @@
+package searchbooks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/tombstone"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+// searchOptions are the flags that shape the search body, once they are
+// parsed and checked.
+type searchOptions struct {
+	query  string
+	intent classification
+	boosts []fieldBoost
+	// matchType is the -type, or phrase with -phrase
+	matchType          string
+	exact              bool
+	operator           string
+	minimumShouldMatch string
+	tieBreaker         float64
+	fuzzy              bool
+	showMatches        bool
+
+	// filterFile is the -filter-file filter, which is built beforehand
+	// since long lists are stored in the lookup index
+	filterFile query.Query
+	since      time.Duration
+	author     string
+	genre      string
+	minRating  float64
+	yearFrom   int
+	yearTo     int
+
+	from      int
+	size      int
+	sort      resultSort
+	cursor    bool
+	after     string
+	minScore  float64
+	facets    bool
+	facetSize int
+
+	fragments       int
+	fragmentSize    int
+	boundaryScanner string
+}
+
+// buildSearchBody returns the body of the search options describe. now is
+// the time -since counts back from.
+func buildSearchBody(options searchOptions, now time.Time) (SearchBody, error) {
+	multiMatch := query.MultiMatch(options.query, multiMatchFields(options.boosts)...).
+		Type(options.matchType).
+		Operator(options.operator)
+	if options.minimumShouldMatch != "" {
+		multiMatch.MinimumShouldMatch(options.minimumShouldMatch)
+	}
+	if options.tieBreaker > 0 {
+		multiMatch.TieBreaker(options.tieBreaker)
+	}
+	// AUTO allows no edits for terms of up to two characters, one for up
+	// to five, and two for longer ones
+	fuzziness := ""
+	if options.fuzzy {
+		fuzziness = "AUTO"
+		multiMatch.Fuzziness(fuzziness)
+	}
+
+	var searchQuery query.Query = multiMatch
+	if options.showMatches {
+		searchQuery = withFieldAttribution(multiMatch, options.query, options.matchType, fuzziness, boostedFields(options.boosts))
+	}
+	if options.exact {
+		// The keyword subfield holds the whole title, lowercased by its
+		// normalizer, which is applied to the term too
+		searchQuery = query.Term("title.keyword", strings.TrimSpace(options.query))
+	}
+	if options.intent.Intent != intentTopic {
+		searchQuery = intentQuery(options.intent, fuzziness)
+	}
+
+	// Filters narrow the results without changing their scores
+	var filters []query.Query
+	if options.filterFile != nil {
+		filters = append(filters, options.filterFile)
+	}
+	if options.since > 0 {
+		filters = append(filters, query.Range("indexed_at").Gte(now.Add(-options.since).UTC().Format(time.RFC3339)))
+	}
+	filters = append(filters, bookFilters(options.author, options.genre, options.minRating, options.yearFrom, options.yearTo)...)
+	if len(filters) > 0 {
+		searchQuery = query.Bool().Must(searchQuery).Filter(filters...)
+	}
+	searchQuery = tombstone.Live(searchQuery)
+
+	searchBody := SearchBody{
+		Query: searchQuery,
+		From:  options.from,
+		Size:  options.size,
+	}
+	if !options.sort.ByRelevance() {
+		searchBody.Sort = options.sort.clause
+		// Scores are only computed when sorting by them unless asked for
+		searchBody.TrackScores = true
+	}
+	if options.cursor {
+		searchBody.Sort = cursorSort(options.sort.clause)
+	}
+	if options.after != "" {
+		var err error
+		searchBody.SearchAfter, err = decodeCursor(options.after, len(searchBody.Sort))
+		if err != nil {
+			return SearchBody{}, err
+		}
+	}
+	// Identifier lookups are filters, which give every match a score of 0
+	if !options.intent.isLookup() {
+		searchBody.MinScore = options.minScore
+	}
+	if options.facets {
+		searchBody.Aggs = facetAggs(options.facetSize)
+	}
+	// Identifier lookups match exactly, so there are no terms to highlight
+	if options.fragments > 0 && !options.intent.isLookup() {
+		searchBody.Highlight = &Highlight{
+			Fields: map[string]HighlightField{
+				"description": {
+					Type:              "unified",
+					FragmentSize:      options.fragmentSize,
+					NumberOfFragments: options.fragments,
+					BoundaryScanner:   options.boundaryScanner,
+					// Show the start of the description when only the title or url matched
+					NoMatchSize: options.fragmentSize,
+				},
+				// The whole title, for coloring the terms that matched in it
+				"title": {
+					Type:              "unified",
+					NumberOfFragments: 0,
+					BoundaryScanner:   options.boundaryScanner,
+				},
+			},
+		}
+	}
+	return searchBody, nil
+}
@@ -0,0 +1,190 @@
+package searchbooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata with the current output")
+
+// testNow is the time -since counts back from in the golden files.
+var testNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// defaultOptions are the search options of the flags' defaults.
+func defaultOptions(t testing.TB, text string) searchOptions {
+	t.Helper()
+	boosts, err := parseBoost("title,url,description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := parseSort("_score")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return searchOptions{
+		query:           text,
+		intent:          classifyRules(text),
+		boosts:          boosts,
+		matchType:       "best_fields",
+		operator:        "or",
+		size:            10,
+		sort:            order,
+		facetSize:       10,
+		fragments:       2,
+		fragmentSize:    150,
+		boundaryScanner: "sentence",
+	}
+}
+
+func TestBuildSearchBody(t *testing.T) {
+	rating, err := parseSort("rating:desc,year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := encodeCursor([]interface{}{json.Number("4.25"), json.Number("1954"), "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*searchOptions)
+		text   string
+	}{
+		{name: "topic", text: "dragons"},
+		{name: "intent_isbn", text: "978-0-306-40615-7"},
+		{name: "intent_author", text: "books by Ursula Le Guin"},
+		{name: "intent_title", text: `"The Hobbit"`},
+		{name: "sort", text: "dragons", modify: func(o *searchOptions) {
+			o.sort = rating
+		}},
+		{name: "filters", text: "dragons", modify: func(o *searchOptions) {
+			o.filterFile = query.Terms("_id", "1", "2")
+			o.since = 24 * time.Hour
+			o.author = "tolkien"
+			o.genre = "Fantasy, romance"
+			o.minRating = 4
+			o.yearFrom = 1950
+			o.yearTo = 1960
+		}},
+		{name: "cursor", text: "dragons", modify: func(o *searchOptions) {
+			o.sort = rating
+			o.cursor = true
+			o.after = cursor
+		}},
+		{name: "min_score", text: "dragons", modify: func(o *searchOptions) {
+			o.minScore = 2.5
+			o.fragments = 0
+		}},
+		{name: "min_score_lookup", text: "B00ABCDEFG", modify: func(o *searchOptions) {
+			o.minScore = 2.5
+		}},
+		{name: "facets", text: "dragons", modify: func(o *searchOptions) {
+			o.facets = true
+			o.facetSize = 5
+		}},
+		{name: "fuzzy_matches", text: "dragns", modify: func(o *searchOptions) {
+			o.fuzzy = true
+			o.showMatches = true
+			o.operator = "and"
+			o.minimumShouldMatch = "75%"
+			o.tieBreaker = 0.3
+		}},
+		{name: "exact", text: " The Hobbit ", modify: func(o *searchOptions) {
+			o.intent = classification{Intent: intentTopic, Query: o.query}
+			o.exact = true
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := defaultOptions(t, test.text)
+			if test.modify != nil {
+				test.modify(&options)
+			}
+			body, err := buildSearchBody(options, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(body, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", test.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v, run go test -update to create it", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("search body differs from %s, run go test -update if the change is intended:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestBuildSearchBodyInvalidCursor(t *testing.T) {
+	options := defaultOptions(t, "dragons")
+	options.cursor = true
+	options.after = "not a cursor"
+	if _, err := buildSearchBody(options, testNow); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
+
+func FuzzBuildSearchBody(f *testing.F) {
+	f.Add("dragons", "_score", "")
+	f.Add("978-0-306-40615-7", "rating:desc,year", "fantasy")
+	f.Add(`"The Hobbit"`, "indexed:asc", "")
+	f.Add("author: le guin", "created,updated:desc", "sci-fi, romance")
+	f.Fuzz(func(t *testing.T, text, sort, genre string) {
+		order, err := parseSort(sort)
+		if err != nil {
+			return
+		}
+		options := defaultOptions(t, text)
+		options.sort = order
+		options.cursor = true
+		options.genre = genre
+		body, err := buildSearchBody(options, testNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshalling the body of %q: %v", text, err)
+		}
+		if !json.Valid(encoded) {
+			t.Fatalf("invalid JSON for %q: %s", text, encoded)
+		}
+	})
+}
+
+func BenchmarkBuildSearchBody(b *testing.B) {
+	options := defaultOptions(b, "the lord of the rings")
+	options.facets = true
+	options.author = "tolkien"
+	options.yearFrom = 1950
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body, err := buildSearchBody(options, testNow)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := json.Marshal(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package searchbooks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// searchFields are the fields a query is searched in, and the only ones
+// -boost accepts.
+var searchFields = []string{"title", "url", "description", "authors.name"}
+
+// fieldBoost is a field searched with a weight from -boost.
+type fieldBoost struct {
+	field  string
+	weight float64
+}
+
+// parseBoost parses comma separated field^weight pairs, e.g.
+// title^3,description^1,url^0.1. A field without a weight gets 1, and
+// fields that aren't listed aren't searched.
+func parseBoost(value string) ([]fieldBoost, error) {
+	var boosts []fieldBoost
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		field, weightText, hasWeight := strings.Cut(strings.TrimSpace(part), "^")
+		if !knownSearchField(field) {
+			return nil, fmt.Errorf("unknown -boost field %q, expected one of %s", field, strings.Join(searchFields, ", "))
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("-boost lists %s more than once", field)
+		}
+		seen[field] = true
+
+		weight := 1.0
+		if hasWeight {
+			var err error
+			weight, err = strconv.ParseFloat(weightText, 64)
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("-boost weight %q for %s must be a number of at least 0", weightText, field)
+			}
+		}
+		boosts = append(boosts, fieldBoost{field: field, weight: weight})
+	}
+	return boosts, nil
+}
+
+func knownSearchField(field string) bool {
+	for _, known := range searchFields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// multiMatchFields returns the fields of boosts the way multi_match takes
+// them, e.g. title^3. Weights of 1 are left off.
+func multiMatchFields(boosts []fieldBoost) []string {
+	fields := make([]string, len(boosts))
+	for i, boost := range boosts {
+		fields[i] = boost.field
+		if boost.weight != 1 {
+			fields[i] += "^" + strconv.FormatFloat(boost.weight, 'f', -1, 64)
+		}
+	}
+	return fields
+}
+
+// boostedFields returns the names of the fields in boosts, without weights.
+func boostedFields(boosts []fieldBoost) []string {
+	fields := make([]string, len(boosts))
+	for i, boost := range boosts {
+		fields[i] = boost.field
+	}
+	return fields
+}
@@ -0,0 +1,93 @@
+package searchbooks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/i18n"
+)
+
+// csvColumns are the columns -fields can pick for -output csv, by name.
+// Fields with several values are joined with "; ".
+var csvColumns = map[string]func(hit BookHit) string{
+	"id":          func(hit BookHit) string { return hit.ID },
+	"score":       func(hit BookHit) string { return strconv.FormatFloat(hit.Score, 'f', -1, 64) },
+	"title":       func(hit BookHit) string { return hit.Book.Title },
+	"url":         func(hit BookHit) string { return hit.Book.Url },
+	"description": func(hit BookHit) string { return hit.Book.Description },
+	"authors": func(hit BookHit) string {
+		var names []string
+		for _, author := range hit.Book.Authors {
+			if author.Name != "" {
+				names = append(names, author.Name)
+			}
+		}
+		return strings.Join(names, "; ")
+	},
+	"rating": func(hit BookHit) string {
+		if hit.Book.AverageRating == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*hit.Book.AverageRating, 'f', -1, 64)
+	},
+	"year": func(hit BookHit) string {
+		if hit.Book.PublicationYear == nil {
+			return ""
+		}
+		return strconv.Itoa(*hit.Book.PublicationYear)
+	},
+	"isbn":   func(hit BookHit) string { return hit.Book.ISBN },
+	"isbn13": func(hit BookHit) string { return hit.Book.ISBN13 },
+	"asin":   func(hit BookHit) string { return hit.Book.ASIN },
+	"genres": func(hit BookHit) string { return strings.Join(hit.Book.Genres, "; ") },
+}
+
+// parseFields checks the comma separated -fields columns.
+func parseFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := csvColumns[field]; !ok {
+			var names []string
+			for name := range csvColumns {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown -fields column %q, expected one of %s", field, strings.Join(names, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// writeCSV writes a header row with the field names, then a row per hit.
+// Numbers are written without digit grouping whatever the language, so
+// spreadsheets read them as numbers. The cursor for the next page goes to
+// stderr.
+func writeCSV(printer *i18n.Printer, hits []BookHit, fields []string, cursor string) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(fields); err != nil {
+		return err
+	}
+	for _, hit := range hits {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = csvColumns[field](hit)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if cursor != "" {
+		fmt.Fprintln(os.Stderr, printer.Sprintf("next page: -after %s", cursor))
+	}
+	return nil
+}
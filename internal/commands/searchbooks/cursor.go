@@ -0,0 +1,57 @@
+package searchbooks
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// sortValues are the sort values of a hit. Numbers are kept as
+// json.Number, since long and date sort values don't fit in a float64 and
+// a rounded value in a cursor would skip or repeat hits.
+type sortValues []interface{}
+
+func (v *sortValues) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values []interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+// cursorSort orders results for search_after paging by the -sort clause.
+// Ties are broken by _id so that every book has a unique position to
+// resume from.
+func cursorSort(clause []interface{}) []interface{} {
+	sort := append([]interface{}{}, clause...)
+	return append(sort, map[string]string{"_id": "asc"})
+}
+
+// encodeCursor turns the sort values of the last hit on a page into an
+// opaque cursor for -after.
+func encodeCursor(sort []interface{}) (string, error) {
+	sortBytes, err := json.Marshal(sort)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(sortBytes), nil
+}
+
+// decodeCursor returns the search_after values in a cursor from
+// encodeCursor, which has one for each of the sorts values, with numbers
+// kept exact like sortValues.
+func decodeCursor(cursor string, sorts int) ([]interface{}, error) {
+	sortBytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -after cursor: %w", err)
+	}
+	var sort sortValues
+	if err := json.Unmarshal(sortBytes, &sort); err != nil || len(sort) != sorts {
+		return nil, fmt.Errorf("invalid -after cursor %q", cursor)
+	}
+	return sort, nil
+}
@@ -0,0 +1,65 @@
+package searchbooks
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	// Dates and longs don't fit in a float64, so they must survive the
+	// round trip digit for digit
+	values := []interface{}{json.Number("1709294400123"), json.Number("9007199254740993"), json.Number("4.25"), "book-1"}
+	cursor, err := encodeCursor(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeCursor(cursor, len(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("decodeCursor returned %v, want %v", decoded, values)
+	}
+
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[1709294400123,9007199254740993,4.25,"book-1"]`; string(encoded) != want {
+		t.Errorf("search_after is %s, want %s", encoded, want)
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	valid := base64.RawURLEncoding.EncodeToString([]byte(`[1,"a"]`))
+	tests := []struct {
+		name   string
+		cursor string
+		sorts  int
+	}{
+		{name: "not base64", cursor: "!!!", sorts: 2},
+		{name: "not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("nope")), sorts: 1},
+		{name: "not an array", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"a":1}`)), sorts: 1},
+		{name: "too few values", cursor: valid, sorts: 3},
+		{name: "too many values", cursor: valid, sorts: 1},
+	}
+	for _, test := range tests {
+		if _, err := decodeCursor(test.cursor, test.sorts); err == nil {
+			t.Errorf("%s: decodeCursor succeeded, want an error", test.name)
+		}
+	}
+}
+
+func TestCursorSort(t *testing.T) {
+	clause := []interface{}{map[string]string{"average_rating": "desc"}}
+	got := cursorSort(clause)
+	want := []interface{}{map[string]string{"average_rating": "desc"}, map[string]string{"_id": "asc"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cursorSort = %v, want %v", got, want)
+	}
+	if len(clause) != 1 {
+		t.Errorf("cursorSort changed the -sort clause to %v", clause)
+	}
+}
@@ -0,0 +1,59 @@
+package searchbooks
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// dedupHits keeps only the highest scoring hit for each value of field,
+// in the position of the first hit seen with that value. Hits missing the
+// field are always kept.
+func dedupHits(hits []BookHit, field string) ([]BookHit, error) {
+	deduped := make([]BookHit, 0, len(hits))
+	positions := map[string]int{}
+
+	for _, hit := range hits {
+		value, ok, err := bookField(hit.Book, field)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			deduped = append(deduped, hit)
+			continue
+		}
+
+		position, seen := positions[value]
+		if !seen {
+			positions[value] = len(deduped)
+			deduped = append(deduped, hit)
+			continue
+		}
+		if hit.Score > deduped[position].Score {
+			deduped[position] = hit
+		}
+	}
+	return deduped, nil
+}
+
+// bookField returns the value of a book's field by its JSON name, and
+// whether the book has a non-empty value for it.
+func bookField(book Book, field string) (string, bool, error) {
+	documentBytes, err := json.Marshal(book)
+	if err != nil {
+		return "", false, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(documentBytes, &document); err != nil {
+		return "", false, err
+	}
+
+	value, ok := document[field]
+	if !ok {
+		return "", false, fmt.Errorf("unknown book field %q", field)
+	}
+	if value == nil || value == "" {
+		return "", false, nil
+	}
+	return fmt.Sprint(value), true, nil
+}
@@ -0,0 +1,55 @@
+package searchbooks
+
+import (
+	"encoding/json"
+	"sort"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+// enrichHits fetches the companion document with the same ID as each hit
+// from index in a single mget, and copies the requested fields onto the
+// hit. Hits without a companion document are left as they are.
+func enrichHits(client *elasticsearch7.Client, hits []BookHit, index string, fields []string) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	docs, err := esclient.MGet(client, index, ids, fields...)
+	if err != nil {
+		return err
+	}
+
+	// MGet returns documents in the order they were requested
+	for i, doc := range docs {
+		if !doc.Found {
+			continue
+		}
+		var source map[string]interface{}
+		if err := json.Unmarshal(doc.Source, &source); err != nil {
+			return err
+		}
+		hits[i].Enrichment = map[string]interface{}{}
+		for _, field := range fields {
+			if value, ok := source[field]; ok {
+				hits[i].Enrichment[field] = value
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
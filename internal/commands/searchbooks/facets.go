@@ -0,0 +1,67 @@
+package searchbooks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/i18n"
+)
+
+// facetBucket is one value of a facet and how many matching books have it.
+type facetBucket struct {
+	Key      interface{} `json:"key"`
+	DocCount int64       `json:"doc_count"`
+}
+
+type facetAggregation struct {
+	Buckets []facetBucket `json:"buckets"`
+}
+
+// facetNames are the facets -facets asks for, in the order they are
+// printed.
+var facetNames = []string{"genres", "authors", "decades"}
+
+// facetAggs returns the aggregations counting the size most common genres
+// and authors of the matching books, and the decades they were published
+// in.
+func facetAggs(size int) map[string]interface{} {
+	return map[string]interface{}{
+		"genres": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "genres", "size": size},
+		},
+		"authors": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "authors.name.keyword", "size": size},
+		},
+		"decades": map[string]interface{}{
+			"histogram": map[string]interface{}{"field": "publication_year", "interval": 10, "min_doc_count": 1},
+		},
+	}
+}
+
+// printFacets prints a line per facet with its buckets and their counts,
+// e.g. "genres: fantasy (120), fiction (95)".
+func printFacets(printer *i18n.Printer, aggregations map[string]facetAggregation) {
+	for _, name := range facetNames {
+		aggregation, ok := aggregations[name]
+		if !ok {
+			continue
+		}
+		buckets := make([]string, len(aggregation.Buckets))
+		for i, bucket := range aggregation.Buckets {
+			buckets[i] = fmt.Sprintf("%s (%s)", facetKey(name, bucket.Key), printer.Int(bucket.DocCount))
+		}
+		if len(buckets) == 0 {
+			buckets = []string{"-"}
+		}
+		fmt.Printf("%s: %s\n", name, strings.Join(buckets, ", "))
+	}
+}
+
+// facetKey formats a bucket key, turning decade histogram keys like 1990
+// into 1990s.
+func facetKey(name string, key interface{}) string {
+	if number, ok := key.(float64); ok && name == "decades" {
+		return fmt.Sprintf("%ds", int64(number))
+	}
+	return fmt.Sprint(key)
+}
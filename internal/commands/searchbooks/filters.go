@@ -0,0 +1,111 @@
+package searchbooks
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+// Lists longer than this are stored in the lookup index instead of being
+// sent inline with every search.
+const inlineTermsLimit = 1024
+
+// readFilterFile reads one value per line, skipping blank lines and lines
+// starting with #.
+func readFilterFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var values []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	return values, scanner.Err()
+}
+
+// termsFilter restricts results to books whose field is one of values.
+// Long lists are stored once in lookupIndex, keyed by a hash of their
+// contents, and referenced with a terms lookup.
+func termsFilter(client *elasticsearch7.Client, field string, values []string, lookupIndex string) (query.Query, error) {
+	if len(values) <= inlineTermsLimit {
+		terms := make([]interface{}, len(values))
+		for i, value := range values {
+			terms[i] = value
+		}
+		return query.Terms(field, terms...), nil
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(values, "\n")))
+	id := hex.EncodeToString(hash[:])
+
+	body, err := json.Marshal(map[string]interface{}{"values": values})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Index(
+		lookupIndex,
+		bytes.NewReader(body),
+		client.Index.WithDocumentID(id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("error storing filter list, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	return query.TermsLookup(field, lookupIndex, id, "values"), nil
+}
+
+// bookFilters returns the filters for -author, -genre, -min-rating,
+// -year-from and -year-to, leaving out the ones that aren't set.
+func bookFilters(author, genres string, minRating float64, yearFrom, yearTo int) []query.Query {
+	var filters []query.Query
+	if author != "" {
+		filters = append(filters, query.Match("authors.name", author).Operator("and"))
+	}
+	if genres != "" {
+		// Genres are indexed in lower case, as goodreads writes them
+		var values []interface{}
+		for _, genre := range strings.Split(genres, ",") {
+			if genre = strings.ToLower(strings.TrimSpace(genre)); genre != "" {
+				values = append(values, genre)
+			}
+		}
+		if len(values) > 0 {
+			filters = append(filters, query.Terms("genres", values...))
+		}
+	}
+	if minRating > 0 {
+		filters = append(filters, query.Range("average_rating").Gte(minRating))
+	}
+	if yearFrom != 0 || yearTo != 0 {
+		years := query.Range("publication_year")
+		if yearFrom != 0 {
+			years.Gte(yearFrom)
+		}
+		if yearTo != 0 {
+			years.Lte(yearTo)
+		}
+		filters = append(filters, years)
+	}
+	return filters
+}
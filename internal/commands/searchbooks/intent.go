@@ -0,0 +1,202 @@
+package searchbooks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/plugin"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+// The intents a query can be classified as. Each is searched with its own
+// query template.
+const (
+	intentTopic  = "topic"
+	intentTitle  = "title"
+	intentAuthor = "author"
+	intentISBN   = "isbn"
+	intentASIN   = "asin"
+)
+
+var intents = []string{intentTopic, intentTitle, intentAuthor, intentISBN, intentASIN}
+
+// classification is the intent of a query and the text left to search for
+// once the words that gave the intent away, like a leading "by", are
+// removed. It is also what classify-* plugins respond with.
+type classification struct {
+	Intent string `json:"intent"`
+	Query  string `json:"query"`
+}
+
+type classifyRequest struct {
+	Query string `json:"query"`
+}
+
+// isbnPattern matches ISBN-10 and ISBN-13 numbers, once dashes are
+// removed. Only those with a valid check digit are ISBNs, see validISBN.
+var isbnPattern = regexp.MustCompile(`^(\d{9}[\dXx]|\d{13})$`)
+
+// asinPattern matches Amazon ASINs that aren't ISBNs, like the ones of
+// Kindle editions.
+var asinPattern = regexp.MustCompile(`^[Bb]0[0-9A-Za-z]{8}$`)
+
+// classifyRules detects the intents that show in the shape of a query:
+//
+//   - an ISBN with a valid check digit, with or without dashes, looks up
+//     the book by ISBN
+//   - an ASIN looks up the book by ASIN
+//   - author: or a leading "books by" looks up an author
+//   - title: or a query in double quotes looks up a title
+//
+// Everything else is a topic, searched across every field. Queries that
+// merely start with "by", like "By the Shores of Silver Lake", and numbers
+// written with spaces, like phone numbers, are topics too.
+func classifyRules(text string) classification {
+	text = strings.TrimSpace(text)
+	compact := strings.ReplaceAll(text, "-", "")
+	if isbnPattern.MatchString(compact) && validISBN(compact) {
+		return classification{Intent: intentISBN, Query: strings.ToUpper(compact)}
+	}
+	if asinPattern.MatchString(text) {
+		return classification{Intent: intentASIN, Query: strings.ToUpper(text)}
+	}
+
+	lower := strings.ToLower(text)
+	for _, prefix := range []string{"author:", "books by "} {
+		if strings.HasPrefix(lower, prefix) && len(text) > len(prefix) {
+			return classification{Intent: intentAuthor, Query: strings.TrimSpace(text[len(prefix):])}
+		}
+	}
+	if strings.HasPrefix(lower, "title:") && len(text) > len("title:") {
+		return classification{Intent: intentTitle, Query: strings.TrimSpace(text[len("title:"):])}
+	}
+	if len(text) > 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		return classification{Intent: intentTitle, Query: text[1 : len(text)-1]}
+	}
+	return classification{Intent: intentTopic, Query: text}
+}
+
+// classify returns the intent of text for -intent mode: auto classifies it
+// by the rules, then asks the classify-* plugins in pluginsDir about
+// queries the rules see as a topic. Any other mode forces that intent.
+func classify(mode, pluginsDir, text string) (classification, error) {
+	if mode == intentISBN || mode == intentASIN {
+		return classification{Intent: mode, Query: strings.ToUpper(compactISBN(text))}, nil
+	}
+	if mode != "auto" {
+		return classification{Intent: mode, Query: text}, nil
+	}
+	result := classifyRules(text)
+	if result.Intent != intentTopic {
+		return result, nil
+	}
+
+	classifiers, err := plugin.StartAll(pluginsDir, "classify")
+	if err != nil {
+		return classification{}, err
+	}
+	defer plugin.CloseAll(classifiers)
+
+	// The first plugin with an opinion wins
+	for _, classifier := range classifiers {
+		var response classification
+		if err := classifier.Call(classifyRequest{Query: text}, &response); err != nil {
+			return classification{}, err
+		}
+		if response.Intent == "" || response.Intent == intentTopic {
+			continue
+		}
+		if !validIntent(response.Intent) {
+			return classification{}, fmt.Errorf("classify plugin returned unknown intent %q", response.Intent)
+		}
+		if response.Query == "" {
+			response.Query = text
+		}
+		return response, nil
+	}
+	return result, nil
+}
+
+// compactISBN removes the dashes and spaces ISBNs are often written with.
+func compactISBN(text string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(text))
+}
+
+// validISBN reports whether the last digit of an ISBN-10 or ISBN-13
+// without dashes is the right check digit.
+func validISBN(isbn string) bool {
+	sum := 0
+	switch len(isbn) {
+	case 10:
+		// Digits are weighted 10 down to 1, and X stands for 10
+		for i, digit := range strings.ToUpper(isbn) {
+			value := int(digit - '0')
+			if digit == 'X' {
+				if i != 9 {
+					return false
+				}
+				value = 10
+			}
+			sum += (10 - i) * value
+		}
+		return sum%11 == 0
+	case 13:
+		// Digits are weighted 1 and 3 in turn
+		for i, digit := range isbn {
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += weight * int(digit-'0')
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+func validIntent(intent string) bool {
+	for _, known := range intents {
+		if intent == known {
+			return true
+		}
+	}
+	return false
+}
+
+// isLookup reports whether the query is an identifier, which is looked up
+// directly instead of searched for.
+func (c classification) isLookup() bool {
+	return c.Intent == intentISBN || c.Intent == intentASIN
+}
+
+// intentQuery returns the query template for a title, author, ISBN or ASIN
+// lookup. Identifiers are matched exactly in filter context, so there is
+// nothing to score, and fuzziness only applies to titles and authors.
+func intentQuery(intent classification, fuzziness string) query.Query {
+	switch intent.Intent {
+	case intentTitle:
+		// Titles containing the words as a phrase rank above titles that
+		// only contain all of them, or close misspellings with fuzziness
+		return query.Bool().
+			Should(
+				query.MultiMatch(intent.Query, "title").Type("phrase").Boost(3),
+				query.MultiMatch(intent.Query, "title").Operator("and").Fuzziness(fuzziness)).
+			MinimumShouldMatch("1")
+	case intentAuthor:
+		return query.Match("authors.name", intent.Query).Operator("and").Fuzziness(fuzziness)
+	case intentISBN:
+		return identifierQuery(intent.Query, "isbn", "isbn13")
+	case intentASIN:
+		return identifierQuery(intent.Query, "asin", "kindle_asin")
+	}
+	return nil
+}
+
+func identifierQuery(id string, fields ...string) query.Query {
+	matches := query.Bool().MinimumShouldMatch("1")
+	for _, field := range fields {
+		matches.Should(query.Term(field, id))
+	}
+	return query.Bool().Filter(matches)
+}
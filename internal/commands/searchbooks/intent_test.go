@@ -0,0 +1,74 @@
+package searchbooks
+
+import "testing"
+
+func TestClassifyRules(t *testing.T) {
+	tests := []struct {
+		text string
+		want classification
+	}{
+		{text: "dragons", want: classification{Intent: intentTopic, Query: "dragons"}},
+		{text: "  dragons  ", want: classification{Intent: intentTopic, Query: "dragons"}},
+		{text: "978-0-306-40615-7", want: classification{Intent: intentISBN, Query: "9780306406157"}},
+		{text: "9780306406157", want: classification{Intent: intentISBN, Query: "9780306406157"}},
+		{text: "0-8044-2957-x", want: classification{Intent: intentISBN, Query: "080442957X"}},
+		{text: "080442957X", want: classification{Intent: intentISBN, Query: "080442957X"}},
+		// Wrong check digits
+		{text: "9780306406158", want: classification{Intent: intentTopic, Query: "9780306406158"}},
+		{text: "0804429579", want: classification{Intent: intentTopic, Query: "0804429579"}},
+		// Numbers written with spaces, like phone numbers
+		{text: "978 0 306 40615 7", want: classification{Intent: intentTopic, Query: "978 0 306 40615 7"}},
+		{text: "b00abcdefg", want: classification{Intent: intentASIN, Query: "B00ABCDEFG"}},
+		{text: "author: Ursula Le Guin", want: classification{Intent: intentAuthor, Query: "Ursula Le Guin"}},
+		{text: "Books by Ursula Le Guin", want: classification{Intent: intentAuthor, Query: "Ursula Le Guin"}},
+		{text: "By the Shores of Silver Lake", want: classification{Intent: intentTopic, Query: "By the Shores of Silver Lake"}},
+		{text: "author:", want: classification{Intent: intentTopic, Query: "author:"}},
+		{text: "title: Dune", want: classification{Intent: intentTitle, Query: "Dune"}},
+		{text: `"The Hobbit"`, want: classification{Intent: intentTitle, Query: "The Hobbit"}},
+		{text: `""`, want: classification{Intent: intentTopic, Query: `""`}},
+	}
+	for _, test := range tests {
+		if got := classifyRules(test.text); got != test.want {
+			t.Errorf("classifyRules(%q) = %+v, want %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestValidISBN(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{isbn: "9780306406157", want: true},
+		{isbn: "9780306406150", want: false},
+		{isbn: "0306406152", want: true},
+		{isbn: "0306406153", want: false},
+		{isbn: "080442957X", want: true},
+		{isbn: "080442957x", want: true},
+		{isbn: "X804429570", want: false},
+		{isbn: "12345", want: false},
+	}
+	for _, test := range tests {
+		if got := validISBN(test.isbn); got != test.want {
+			t.Errorf("validISBN(%q) = %t, want %t", test.isbn, got, test.want)
+		}
+	}
+}
+
+func FuzzClassifyRules(f *testing.F) {
+	for _, text := range []string{"dragons", "978-0-306-40615-7", "080442957X", "B00ABCDEFG", "author: le guin", "books by tolkien", `"Dune"`, "title:Dune"} {
+		f.Add(text)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		got := classifyRules(text)
+		if !validIntent(got.Intent) {
+			t.Fatalf("classifyRules(%q) returned unknown intent %q", text, got.Intent)
+		}
+		if got.isLookup() && !isbnPattern.MatchString(got.Query) && !asinPattern.MatchString(got.Query) {
+			t.Fatalf("classifyRules(%q) looks up %s %q, which isn't one", text, got.Intent, got.Query)
+		}
+		if got.Intent != intentTopic && got.Query == "" {
+			t.Fatalf("classifyRules(%q) returned %s with nothing to look up", text, got.Intent)
+		}
+	})
+}
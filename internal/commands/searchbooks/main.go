@@ -0,0 +1,393 @@
+// Package searchbooks searches the books index and prints the results.
+package searchbooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/i18n"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/telemetry"
+	"github.com/nickcanz/search-go/pkg/esclient"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+type Book struct {
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	Description string `json:"description"`
+
+	ISBN            string   `json:"isbn"`
+	ISBN13          string   `json:"isbn13"`
+	ASIN            string   `json:"asin"`
+	KindleASIN      string   `json:"kindle_asin"`
+	Authors         []Author `json:"authors"`
+	AverageRating   *float64 `json:"average_rating"`
+	PublicationYear *int     `json:"publication_year"`
+	Genres          []string `json:"genres"`
+}
+
+type Author struct {
+	AuthorID string `json:"author_id"`
+	Name     string `json:"name"`
+}
+
+type BookHit struct {
+	ID             string              `json:"_id"`
+	Book           Book                `json:"_source"`
+	Score          float64             `json:"_score"`
+	Highlight      map[string][]string `json:"highlight"`
+	MatchedQueries []string            `json:"matched_queries"`
+	Sort           sortValues          `json:"sort"`
+
+	// Enrichment holds fields merged in from -enrich-index
+	Enrichment map[string]interface{} `json:"-"`
+}
+
+type BookSearchResponse struct {
+	Took float64 `json:"took"`
+	Hits struct {
+		Total struct {
+			Value    int64  `json:"value"`
+			Relation string `json:"relation"`
+		} `json:"total"`
+		Hits []BookHit `json:"hits"`
+	} `json:"hits"`
+	// Aggregations holds the -facets counts
+	Aggregations map[string]facetAggregation `json:"aggregations"`
+}
+
+// SearchBody is the request body sent to the search API.
+type SearchBody struct {
+	Query       query.Query            `json:"query"`
+	From        int                    `json:"from,omitempty"`
+	Size        int                    `json:"size"`
+	Sort        []interface{}          `json:"sort,omitempty"`
+	TrackScores bool                   `json:"track_scores,omitempty"`
+	MinScore    float64                `json:"min_score,omitempty"`
+	SearchAfter []interface{}          `json:"search_after,omitempty"`
+	Highlight   *Highlight             `json:"highlight,omitempty"`
+	Aggs        map[string]interface{} `json:"aggs,omitempty"`
+}
+
+type Highlight struct {
+	Fields map[string]HighlightField `json:"fields"`
+}
+
+type HighlightField struct {
+	Type              string `json:"type"`
+	FragmentSize      int    `json:"fragment_size"`
+	NumberOfFragments int    `json:"number_of_fragments"`
+	BoundaryScanner   string `json:"boundary_scanner"`
+	NoMatchSize       int    `json:"no_match_size"`
+}
+
+// Main runs search-books with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("search-books", flag.ExitOnError)
+	queryPtr := flags.String("query", "", "Query to search for")
+	datasetPtr := flags.String("dataset", "", "Search this dataset from the catalog instead of the books index")
+	fromPtr := flags.Int("from", 0, "Number of results to skip")
+	sizePtr := flags.Int("size", 10, "Number of results to show")
+	cursorPtr := flags.Bool("cursor", false, "Page with search_after instead of -from and print a cursor for the next page")
+	sortPtr := flags.String("sort", "_score", "Comma separated fields to sort by, each with an optional :asc or :desc: _score, rating, year, indexed, created, or updated, e.g. rating:desc")
+	afterPtr := flags.String("after", "", "Show the page after this cursor from a previous -cursor search, implies -cursor")
+	fragmentSizePtr := flags.Int("fragment-size", 150, "Approximate length in characters of description highlight snippets")
+	fragmentsPtr := flags.Int("fragments", 2, "Number of description highlight snippets to show per book, 0 disables highlighting")
+	boundaryScannerPtr := flags.String("boundary-scanner", "sentence", "Where to break highlight snippets: sentence or word")
+	dedupFieldPtr := flags.String("dedup-field", "", "Only show the highest scoring book for each value of this field, e.g. url")
+	operatorPtr := flags.String("operator", "or", "Whether a book must match all query terms (and) or any of them (or)")
+	minimumShouldMatchPtr := flags.String("minimum-should-match", "", "How many query terms must match, e.g. 2 or 75%")
+	tieBreakerPtr := flags.Float64("tie-breaker", 0, "Between 0 and 1, how much matches in fields other than the best one add to the score")
+	typePtr := flags.String("type", "best_fields", "How matches across fields are scored: best_fields, most_fields, or cross_fields")
+	boostPtr := flags.String("boost", "title,url,description", "Comma separated fields to search, each with an optional ^weight, e.g. title^3,description,url^0.1; title, url, description, or authors.name")
+	minScorePtr := flags.Float64("min-score", 0, "Leave out books scoring below this, to drop weak matches (disabled when 0)")
+	fuzzyPtr := flags.Bool("fuzzy", false, "Tolerate typos by matching terms within one or two edits of the query terms, depending on their length")
+	phrasePtr := flags.Bool("phrase", false, "Only match books containing the query as a phrase, with its words in order")
+	exactPtr := flags.Bool("exact", false, "Only match books whose whole title is the query, ignoring case")
+	showMatchesPtr := flags.Bool("show-matches", false, "Show which fields each book matched in")
+	authorPtr := flags.String("author", "", "Only show books by an author whose name contains all of these words")
+	genrePtr := flags.String("genre", "", "Only show books in one of these comma separated genres, e.g. fantasy,romance")
+	minRatingPtr := flags.Float64("min-rating", 0, "Only show books with an average rating of at least this, from 0 to 5 (disabled when 0)")
+	yearFromPtr := flags.Int("year-from", 0, "Only show books published in or after this year (disabled when 0)")
+	yearToPtr := flags.Int("year-to", 0, "Only show books published in or before this year (disabled when 0)")
+	sincePtr := flags.Duration("since", 0, "Only show books indexed within this long, e.g. 24h (disabled when 0)")
+	intentPtr := flags.String("intent", "auto", "How to search the query: auto picks by its shape, or force topic, title, author, isbn, or asin")
+	facetsPtr := flags.Bool("facets", false, "Count the most common genres and authors of the matching books, and the decades they were published in")
+	facetSizePtr := flags.Int("facet-size", 10, "Number of genres and authors to count with -facets")
+	outputPtr := flags.String("output", "text", "How to print results: text, table, json with the full hits, ndjson with one hit per line, or csv")
+	fieldsPtr := flags.String("fields", "score,title,url", "Comma separated columns for -output csv: id, score, title, url, description, authors, rating, year, isbn, isbn13, asin, or genres")
+	colorPtr := flags.String("color", "auto", "Color matched terms: auto colors them when writing to a terminal, always, or never")
+	plainPtr := flags.Bool("plain", false, "Print each field of a result on its own line with no decoration, for screen readers and scripts")
+	filterFilePtr := flags.String("filter-file", "", "Only return books whose -filter-field is one of the values in this file, one per line")
+	filterFieldPtr := flags.String("filter-field", "_id", "Field matched against the values in -filter-file")
+	filterIndexPtr := flags.String("filter-index", "book-filters", "Index long -filter-file lists are stored in for a terms lookup")
+	enrichIndexPtr := flags.String("enrich-index", "", "Index to fetch companion documents with the same IDs as the results from")
+	enrichFieldsPtr := flags.String("enrich-fields", "", "Comma separated fields to merge into each result from -enrich-index")
+	preferencePtr := flags.String("preference", "", "Session or user ID that sends repeated searches to the same shard copies for stable ordering")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	pluginsDirPtr := flags.String("plugins-dir", "", "Directory to run classify-* plugins from on queries and rerank-* plugins on the results (none when empty)")
+	flags.Parse(args)
+	start := time.Now()
+
+	printer := i18n.FromEnv()
+
+	index := "books"
+	if *datasetPtr != "" {
+		spec, err := indexspec.LoadDataset(*datasetPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		index = spec.SearchIndex()
+	}
+	if *queryPtr == "" {
+		log.Fatal(printer.Sprintf("No query provided for -query parameter"))
+	}
+	if *operatorPtr != "and" && *operatorPtr != "or" {
+		log.Fatal(printer.Sprintf("Unknown -operator %q, expected and or or", *operatorPtr))
+	}
+	if *typePtr != "best_fields" && *typePtr != "most_fields" && *typePtr != "cross_fields" {
+		log.Fatal(printer.Sprintf("Unknown -type %q, expected best_fields, most_fields, or cross_fields", *typePtr))
+	}
+	if *fuzzyPtr && *typePtr == "cross_fields" {
+		log.Fatal(printer.Sprintf("-fuzzy cannot be combined with -type cross_fields"))
+	}
+	if *phrasePtr && *exactPtr {
+		log.Fatal(printer.Sprintf("-phrase cannot be combined with -exact"))
+	}
+	if (*phrasePtr || *exactPtr) && *fuzzyPtr {
+		log.Fatal(printer.Sprintf("-fuzzy cannot be combined with -phrase or -exact"))
+	}
+	if *phrasePtr && *typePtr != "best_fields" {
+		log.Fatal(printer.Sprintf("-phrase cannot be combined with -type %s", *typePtr))
+	}
+	if (*phrasePtr || *exactPtr) && *intentPtr != "auto" {
+		log.Fatal(printer.Sprintf("-intent cannot be combined with -phrase or -exact"))
+	}
+	if *tieBreakerPtr < 0 || *tieBreakerPtr > 1 {
+		log.Fatal(printer.Sprintf("-tie-breaker must be between 0 and 1, got %s", printer.Number(*tieBreakerPtr, -1)))
+	}
+	if *enrichIndexPtr != "" && *enrichFieldsPtr == "" {
+		log.Fatal(printer.Sprintf("No -enrich-fields provided to merge from -enrich-index"))
+	}
+	if *boundaryScannerPtr != "sentence" && *boundaryScannerPtr != "word" {
+		log.Fatal(printer.Sprintf("Unknown -boundary-scanner %q, expected sentence or word", *boundaryScannerPtr))
+	}
+	if *sizePtr < 1 {
+		log.Fatal(printer.Sprintf("-size must be at least 1, got %s", printer.Int(int64(*sizePtr))))
+	}
+	if *minScorePtr < 0 {
+		log.Fatal(printer.Sprintf("-min-score must be at least 0, got %s", printer.Number(*minScorePtr, -1)))
+	}
+	if *facetSizePtr < 1 {
+		log.Fatal(printer.Sprintf("-facet-size must be at least 1, got %s", printer.Int(int64(*facetSizePtr))))
+	}
+	if *minRatingPtr < 0 || *minRatingPtr > 5 {
+		log.Fatal(printer.Sprintf("-min-rating must be between 0 and 5, got %s", printer.Number(*minRatingPtr, -1)))
+	}
+	if *yearFromPtr != 0 && *yearToPtr != 0 && *yearFromPtr > *yearToPtr {
+		log.Fatal(printer.Sprintf("-year-from %d is after -year-to %d", *yearFromPtr, *yearToPtr))
+	}
+	if *outputPtr != "text" && *outputPtr != "table" && *outputPtr != "json" && *outputPtr != "ndjson" && *outputPtr != "csv" {
+		log.Fatal(printer.Sprintf("Unknown -output %q, expected text, table, json, ndjson, or csv", *outputPtr))
+	}
+	csvFields, err := parseFields(*fieldsPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *plainPtr && *outputPtr != "text" {
+		log.Fatal(printer.Sprintf("-plain can only be used with -output text"))
+	}
+	if *colorPtr != "auto" && *colorPtr != "always" && *colorPtr != "never" {
+		log.Fatal(printer.Sprintf("Unknown -color %q, expected auto, always, or never", *colorPtr))
+	}
+	boosts, err := parseBoost(*boostPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resultOrder, err := parseSort(*sortPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *afterPtr != "" {
+		*cursorPtr = true
+	}
+	if *cursorPtr && *fromPtr != 0 {
+		log.Fatal(printer.Sprintf("-from cannot be combined with -cursor or -after"))
+	}
+
+	if *intentPtr != "auto" && !validIntent(*intentPtr) {
+		log.Fatal(printer.Sprintf("Unknown -intent %q, expected auto, topic, title, author, isbn, or asin", *intentPtr))
+	}
+	// -phrase and -exact say how to search the query already
+	intent := classification{Intent: intentTopic, Query: *queryPtr}
+	if !*phrasePtr && !*exactPtr {
+		intent, err = classify(*intentPtr, *pluginsDirPtr, *queryPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// JSON output is only the results, so it can be piped straight to jq
+	if *outputPtr == "text" || *outputPtr == "table" {
+		fmt.Println(printer.Sprintf("Searching books for: %s", *queryPtr))
+		if intent.Intent != intentTopic {
+			fmt.Println(printer.Sprintf("Looking up %s: %s", intent.Intent, intent.Query))
+		}
+	}
+
+	err = godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("search-books")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	matchType := *typePtr
+	if *phrasePtr {
+		matchType = "phrase"
+	}
+	options := searchOptions{
+		query:              *queryPtr,
+		intent:             intent,
+		boosts:             boosts,
+		matchType:          matchType,
+		exact:              *exactPtr,
+		operator:           *operatorPtr,
+		minimumShouldMatch: *minimumShouldMatchPtr,
+		tieBreaker:         *tieBreakerPtr,
+		fuzzy:              *fuzzyPtr,
+		showMatches:        *showMatchesPtr,
+		since:              *sincePtr,
+		author:             *authorPtr,
+		genre:              *genrePtr,
+		minRating:          *minRatingPtr,
+		yearFrom:           *yearFromPtr,
+		yearTo:             *yearToPtr,
+		from:               *fromPtr,
+		size:               *sizePtr,
+		sort:               resultOrder,
+		cursor:             *cursorPtr,
+		after:              *afterPtr,
+		minScore:           *minScorePtr,
+		facets:             *facetsPtr,
+		facetSize:          *facetSizePtr,
+		fragments:          *fragmentsPtr,
+		fragmentSize:       *fragmentSizePtr,
+		boundaryScanner:    *boundaryScannerPtr,
+	}
+	if *filterFilePtr != "" {
+		values, err := readFilterFile(*filterFilePtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.filterFile, err = termsFilter(client, *filterFieldPtr, values, *filterIndexPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	searchBody, err := buildSearchBody(options, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	requestBody, err := json.Marshal(searchBody)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	requestOptions := []func(*esapi.SearchRequest){
+		client.Search.WithIndex(index),
+		client.Search.WithBody(bytes.NewReader(requestBody)),
+	}
+	if *preferencePtr != "" {
+		requestOptions = append(requestOptions, client.Search.WithPreference(*preferencePtr))
+	}
+
+	resp, err := client.Search(requestOptions...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		log.Fatal(printer.Sprintf("Error querying, status: %s, response body: %s", resp.Status(), resp.String()))
+	}
+
+	var bookSearchResponse BookSearchResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&bookSearchResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hits := bookSearchResponse.Hits.Hits
+	if *dedupFieldPtr != "" {
+		hits, err = dedupHits(hits, *dedupFieldPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	hits, err = rerankHits(*pluginsDirPtr, *queryPtr, hits)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *enrichIndexPtr != "" {
+		err = enrichHits(client, hits, *enrichIndexPtr, strings.Split(*enrichFieldsPtr, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// A short page is the last one, so there is nothing to continue from
+	var cursor string
+	pageHits := bookSearchResponse.Hits.Hits
+	if *cursorPtr && len(pageHits) == *sizePtr {
+		cursor, err = encodeCursor(pageHits[len(pageHits)-1].Sort)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	output := outputOptions{
+		format:      *outputPtr,
+		fields:      csvFields,
+		showMatches: *showMatchesPtr,
+		plain:       *plainPtr,
+		color:       useColor(*colorPtr),
+		sort:        resultOrder,
+	}
+	notFound := intent.isLookup() && len(hits) == 0
+	// A lookup that finds nothing has no results worth summarizing, but JSON
+	// and CSV output still get their empty results for the next tool
+	if !notFound || (*outputPtr != "text" && *outputPtr != "table") {
+		if err := printResults(printer, bookSearchResponse, hits, cursor, output); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	telemetry.Report("search-books", flags, 0, time.Since(start))
+	if notFound {
+		fmt.Fprintln(os.Stderr, printer.Sprintf("No book found with %s %s", strings.ToUpper(intent.Intent), intent.Query))
+		os.Exit(1)
+	}
+}
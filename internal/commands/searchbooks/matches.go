@@ -0,0 +1,17 @@
+package searchbooks
+
+import "github.com/nickcanz/search-go/pkg/query"
+
+// withFieldAttribution wraps the scoring query in a bool query with a
+// named, zero boost clause per field. The extra clauses don't change which
+// books match or how they score, but each hit's matched_queries lists the
+// fields the query text was found in. matchType and fuzziness should be
+// those of the scoring query, so a field is only listed when it matched
+// the same way.
+func withFieldAttribution(scoring query.Query, text, matchType, fuzziness string, fields []string) query.Query {
+	attributed := query.Bool().Must(scoring)
+	for _, field := range fields {
+		attributed.Should(query.MultiMatch(text, field).Type(matchType).Fuzziness(fuzziness).Boost(0).Name(field))
+	}
+	return attributed
+}
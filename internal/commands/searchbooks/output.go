@@ -0,0 +1,280 @@
+package searchbooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nickcanz/search-go/internal/i18n"
+)
+
+// outputOptions controls how results are printed.
+type outputOptions struct {
+	// format is the -output format: text, table, json, ndjson or csv
+	format string
+	// fields are the columns of csv output
+	fields      []string
+	showMatches bool
+	// plain prints one labelled field per line with no decoration, for
+	// screen readers and line based tools
+	plain bool
+	// color replaces the <em> tags around matched terms with ANSI colors
+	color bool
+	// sort is the order results were asked for in, whose values are
+	// printed with each result
+	sort resultSort
+}
+
+// printResults prints the results of a search in the -output format.
+// cursor is the -after value for the next page, if there is one.
+func printResults(printer *i18n.Printer, response BookSearchResponse, hits []BookHit, cursor string, options outputOptions) error {
+	switch options.format {
+	case "json":
+		return writeJSON(response, hits, cursor)
+	case "ndjson":
+		return writeNDJSON(printer, hits, cursor)
+	case "csv":
+		return writeCSV(printer, hits, options.fields, cursor)
+	}
+
+	total := response.Hits.Total
+	summary := "Showing %s of %s books, found in %s ms"
+	if total.Relation == "gte" {
+		summary = "Showing %s of more than %s books, found in %s ms"
+	}
+	fmt.Println(printer.Sprintf(summary, printer.Int(int64(len(hits))), printer.Int(total.Value), printer.Number(response.Took, -1)))
+
+	if options.format == "table" {
+		if err := printTable(printer, hits, options); err != nil {
+			return err
+		}
+	} else {
+		for _, hit := range hits {
+			printHit(printer, hit, options)
+		}
+	}
+	if len(response.Aggregations) > 0 {
+		fmt.Println()
+		printFacets(printer, response.Aggregations)
+	}
+	if cursor != "" {
+		fmt.Println(printer.Sprintf("next page: -after %s", cursor))
+	}
+	return nil
+}
+
+// printHit prints one search result.
+func printHit(printer *i18n.Printer, hit BookHit, options outputOptions) {
+	if options.plain {
+		printPlainHit(printer, hit, options)
+		return
+	}
+
+	title := hit.Book.Title
+	if options.color && len(hit.Highlight["title"]) > 0 {
+		title = hit.Highlight["title"][0]
+	}
+	fmt.Println(printer.Sprintf("%s, %s with score of %s", options.highlight(title), hit.Book.Url, printer.Number(hit.Score, 6)))
+	if sorted := options.sort.describe(printer, hit.Sort); len(sorted) > 0 {
+		fmt.Println("    " + printer.Sprintf("sorted by: %s", strings.Join(sorted, ", ")))
+	}
+	if details := bookDetails(printer, hit.Book); len(details) > 0 {
+		parts := make([]string, len(details))
+		for i, detail := range details {
+			parts[i] = detail.label + ": " + detail.value
+		}
+		fmt.Println("    " + strings.Join(parts, "; "))
+	}
+	for _, field := range sortedKeys(hit.Enrichment) {
+		fmt.Printf("    %s: %v\n", field, hit.Enrichment[field])
+	}
+	if options.showMatches {
+		fmt.Println("    " + printer.Sprintf("matched: %s", strings.Join(hit.MatchedQueries, ", ")))
+	}
+	for _, fragment := range hit.Highlight["description"] {
+		fmt.Printf("    ...%s...\n", options.highlight(collapseSpace(fragment)))
+	}
+}
+
+func printPlainHit(printer *i18n.Printer, hit BookHit, options outputOptions) {
+	fmt.Printf("title: %s\n", collapseSpace(hit.Book.Title))
+	fmt.Printf("url: %s\n", hit.Book.Url)
+	fmt.Printf("score: %s\n", printer.Number(hit.Score, 6))
+	if sorted := options.sort.describe(printer, hit.Sort); len(sorted) > 0 {
+		fmt.Printf("sorted by: %s\n", strings.Join(sorted, ", "))
+	}
+	for _, detail := range bookDetails(printer, hit.Book) {
+		fmt.Printf("%s: %s\n", detail.label, detail.value)
+	}
+	for _, field := range sortedKeys(hit.Enrichment) {
+		fmt.Printf("%s: %v\n", field, hit.Enrichment[field])
+	}
+	if options.showMatches {
+		fmt.Println(printer.Sprintf("matched: %s", strings.Join(hit.MatchedQueries, ", ")))
+	}
+	for _, fragment := range hit.Highlight["description"] {
+		fmt.Printf("description: %s\n", stripHighlightTags(collapseSpace(fragment)))
+	}
+	fmt.Println()
+}
+
+type bookDetail struct {
+	label string
+	value string
+}
+
+// bookDetails returns the authors, rating, year, genres and ISBN of book,
+// leaving out the ones it doesn't have.
+func bookDetails(printer *i18n.Printer, book Book) []bookDetail {
+	var details []bookDetail
+	var authors []string
+	for _, author := range book.Authors {
+		if author.Name != "" {
+			authors = append(authors, author.Name)
+		}
+	}
+	if len(authors) > 0 {
+		details = append(details, bookDetail{"authors", strings.Join(authors, ", ")})
+	}
+	if book.AverageRating != nil {
+		details = append(details, bookDetail{"rating", printer.Number(*book.AverageRating, 2)})
+	}
+	if book.PublicationYear != nil {
+		details = append(details, bookDetail{"year", fmt.Sprint(*book.PublicationYear)})
+	}
+	if len(book.Genres) > 0 {
+		details = append(details, bookDetail{"genres", strings.Join(book.Genres, ", ")})
+	}
+	if book.ISBN13 != "" {
+		details = append(details, bookDetail{"isbn", book.ISBN13})
+	} else if book.ISBN != "" {
+		details = append(details, bookDetail{"isbn", book.ISBN})
+	}
+	return details
+}
+
+// printTable prints one result per row, with the book fields in columns.
+func printTable(printer *i18n.Printer, hits []BookHit, options outputOptions) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCORE\tTITLE\tAUTHORS\tRATING\tYEAR\tURL")
+	for _, hit := range hits {
+		var authors []string
+		for _, author := range hit.Book.Authors {
+			if author.Name != "" {
+				authors = append(authors, author.Name)
+			}
+		}
+		rating, year := "-", "-"
+		if hit.Book.AverageRating != nil {
+			rating = printer.Number(*hit.Book.AverageRating, 2)
+		}
+		if hit.Book.PublicationYear != nil {
+			year = fmt.Sprint(*hit.Book.PublicationYear)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			printer.Number(hit.Score, 3), collapseSpace(hit.Book.Title), strings.Join(authors, ", "), rating, year, hit.Book.Url)
+	}
+	return w.Flush()
+}
+
+// jsonHit is a hit as written by -output json and ndjson, with the fields
+// merged in from -enrich-index.
+type jsonHit struct {
+	BookHit
+	Enrichment map[string]interface{} `json:"enrichment,omitempty"`
+}
+
+func jsonHits(hits []BookHit) []jsonHit {
+	converted := make([]jsonHit, len(hits))
+	for i, hit := range hits {
+		converted[i] = jsonHit{BookHit: hit, Enrichment: hit.Enrichment}
+	}
+	return converted
+}
+
+// writeJSON writes the results as a single JSON document, with matched
+// terms still wrapped in <em> tags in the highlights.
+func writeJSON(response BookSearchResponse, hits []BookHit, cursor string) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Took       float64     `json:"took"`
+		Total      interface{} `json:"total"`
+		Hits       []jsonHit   `json:"hits"`
+		Facets     interface{} `json:"facets,omitempty"`
+		NextCursor string      `json:"next_cursor,omitempty"`
+	}{response.Took, response.Hits.Total, jsonHits(hits), facets(response.Aggregations), cursor})
+}
+
+// facets returns the -facets buckets for JSON output, or nil without
+// -facets.
+func facets(aggregations map[string]facetAggregation) interface{} {
+	if len(aggregations) == 0 {
+		return nil
+	}
+	buckets := map[string][]facetBucket{}
+	for name, aggregation := range aggregations {
+		buckets[name] = aggregation.Buckets
+	}
+	return buckets
+}
+
+// writeNDJSON writes one hit per line. The cursor for the next page goes
+// to stderr, so every line of stdout is a hit.
+func writeNDJSON(printer *i18n.Printer, hits []BookHit, cursor string) error {
+	encoder := json.NewEncoder(os.Stdout)
+	for _, hit := range jsonHits(hits) {
+		if err := encoder.Encode(hit); err != nil {
+			return err
+		}
+	}
+	if cursor != "" {
+		fmt.Fprintln(os.Stderr, printer.Sprintf("next page: -after %s", cursor))
+	}
+	return nil
+}
+
+// ANSI escape codes for matched terms: bold yellow, then back to normal.
+const (
+	colorMatch = "\x1b[1;33m"
+	colorReset = "\x1b[0m"
+)
+
+// highlight colors the matched terms of a highlighted value with color,
+// and otherwise leaves their <em> tags in place.
+func (o outputOptions) highlight(text string) string {
+	if !o.color {
+		return text
+	}
+	return strings.NewReplacer("<em>", colorMatch, "</em>", colorReset).Replace(text)
+}
+
+// useColor resolves -color. auto colors only when stdout is a terminal,
+// and NO_COLOR or TERM=dumb turn it off, see https://no-color.org.
+func useColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// collapseSpace replaces runs of whitespace, including newlines, with a
+// single space so a value stays on one line.
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// stripHighlightTags removes the <em> tags Elasticsearch wraps around
+// highlighted terms.
+func stripHighlightTags(text string) string {
+	return strings.NewReplacer("<em>", "", "</em>", "").Replace(text)
+}
@@ -0,0 +1,34 @@
+package searchbooks
+
+import "github.com/nickcanz/search-go/internal/plugin"
+
+type rerankRequest struct {
+	Query string    `json:"query"`
+	Hits  []BookHit `json:"hits"`
+}
+
+type rerankResponse struct {
+	Hits []BookHit `json:"hits"`
+}
+
+// rerankHits passes the hits through each rerank plugin in pluginsDir in
+// turn. A plugin receives the query and hits as one JSON line and responds
+// with the hits in its preferred order, optionally with new scores or with
+// some hits removed.
+func rerankHits(pluginsDir, text string, hits []BookHit) ([]BookHit, error) {
+	rerankers, err := plugin.StartAll(pluginsDir, "rerank")
+	if err != nil {
+		return nil, err
+	}
+	defer plugin.CloseAll(rerankers)
+
+	for _, reranker := range rerankers {
+		var response rerankResponse
+		err := reranker.Call(rerankRequest{Query: text, Hits: hits}, &response)
+		if err != nil {
+			return nil, err
+		}
+		hits = response.Hits
+	}
+	return hits, nil
+}
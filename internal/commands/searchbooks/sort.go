@@ -0,0 +1,113 @@
+package searchbooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/i18n"
+)
+
+// sortKey is a field results can be sorted by with -sort.
+type sortKey struct {
+	field string
+	// format prints a sort value Elasticsearch returned for the field
+	format func(printer *i18n.Printer, value float64) string
+}
+
+func formatRating(printer *i18n.Printer, value float64) string {
+	return printer.Number(value, 2)
+}
+
+func formatYear(printer *i18n.Printer, value float64) string {
+	return fmt.Sprint(int64(value))
+}
+
+// Dates are returned as milliseconds since the epoch
+func formatDate(printer *i18n.Printer, value float64) string {
+	return time.UnixMilli(int64(value)).Local().Format(time.RFC3339)
+}
+
+// sortKeys maps the names -sort accepts to the fields they sort by.
+var sortKeys = map[string]sortKey{
+	"_score":  {field: "_score"},
+	"rating":  {field: "average_rating", format: formatRating},
+	"year":    {field: "publication_year", format: formatYear},
+	"indexed": {field: "indexed_at", format: formatDate},
+	"created": {field: "created_at", format: formatDate},
+	"updated": {field: "updated_at", format: formatDate},
+}
+
+// resultSort is a parsed -sort flag.
+type resultSort struct {
+	names  []string
+	clause []interface{}
+}
+
+// parseSort parses comma separated name:direction pairs, e.g.
+// rating:desc,year:asc. The direction defaults to desc for _score and asc
+// for everything else.
+func parseSort(value string) (resultSort, error) {
+	var parsed resultSort
+	for _, part := range strings.Split(value, ",") {
+		name, direction, _ := strings.Cut(strings.TrimSpace(part), ":")
+		key, ok := sortKeys[name]
+		if !ok {
+			return resultSort{}, fmt.Errorf("unknown -sort field %q, expected one of %s", name, strings.Join(sortKeyNames(), ", "))
+		}
+		if direction == "" {
+			direction = "asc"
+			if name == "_score" {
+				direction = "desc"
+			}
+		}
+		if direction != "asc" && direction != "desc" {
+			return resultSort{}, fmt.Errorf("unknown -sort direction %q for %s, expected asc or desc", direction, name)
+		}
+		parsed.names = append(parsed.names, name)
+		parsed.clause = append(parsed.clause, map[string]string{key.field: direction})
+	}
+	return parsed, nil
+}
+
+func sortKeyNames() []string {
+	var names []string
+	for name := range sortKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ByRelevance reports whether results are sorted by score alone, which is
+// what Elasticsearch does without a sort clause.
+func (s resultSort) ByRelevance() bool {
+	if len(s.clause) != 1 {
+		return false
+	}
+	direction, ok := s.clause[0].(map[string]string)["_score"]
+	return ok && direction == "desc"
+}
+
+// describe returns each sorted field of a hit with its value, e.g.
+// "rating 4.25", for the fields other than _score, which is printed
+// anyway. Books without a value for a field get "none", since Elasticsearch
+// returns a placeholder that sorts them last.
+func (s resultSort) describe(printer *i18n.Printer, values sortValues) []string {
+	var described []string
+	for i, name := range s.names {
+		if name == "_score" || i >= len(values) {
+			continue
+		}
+		text := "none"
+		if number, ok := values[i].(json.Number); ok {
+			if value, err := number.Float64(); err == nil && value > -1e18 && value < 1e18 {
+				text = sortKeys[name].format(printer, value)
+			}
+		}
+		described = append(described, name+" "+text)
+	}
+	return described
+}
@@ -0,0 +1,58 @@
+package searchbooks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		value     string
+		names     []string
+		clause    []interface{}
+		relevance bool
+	}{
+		{
+			value:     "_score",
+			names:     []string{"_score"},
+			clause:    []interface{}{map[string]string{"_score": "desc"}},
+			relevance: true,
+		},
+		{
+			value:  "_score:asc",
+			names:  []string{"_score"},
+			clause: []interface{}{map[string]string{"_score": "asc"}},
+		},
+		{
+			value:  "rating:desc, year",
+			names:  []string{"rating", "year"},
+			clause: []interface{}{map[string]string{"average_rating": "desc"}, map[string]string{"publication_year": "asc"}},
+		},
+		{
+			value:  "indexed,created:desc,updated",
+			names:  []string{"indexed", "created", "updated"},
+			clause: []interface{}{map[string]string{"indexed_at": "asc"}, map[string]string{"created_at": "desc"}, map[string]string{"updated_at": "asc"}},
+		},
+	}
+	for _, test := range tests {
+		parsed, err := parseSort(test.value)
+		if err != nil {
+			t.Errorf("parseSort(%q): %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(parsed.names, test.names) || !reflect.DeepEqual(parsed.clause, test.clause) {
+			t.Errorf("parseSort(%q) = %v %v, want %v %v", test.value, parsed.names, parsed.clause, test.names, test.clause)
+		}
+		if parsed.ByRelevance() != test.relevance {
+			t.Errorf("parseSort(%q).ByRelevance() = %t, want %t", test.value, parsed.ByRelevance(), test.relevance)
+		}
+	}
+}
+
+func TestParseSortErrors(t *testing.T) {
+	for _, value := range []string{"", "title", "rating:up", "rating,", "year:ASC"} {
+		if _, err := parseSort(value); err == nil {
+			t.Errorf("parseSort(%q) succeeded, want an error", value)
+		}
+	}
+}
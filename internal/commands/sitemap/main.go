@@ -0,0 +1,220 @@
+// Package sitemap writes sitemap.xml shards listing the detail page of
+// every book in an index.
+package sitemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/internal/tombstone"
+	"github.com/nickcanz/search-go/pkg/esclient"
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+// maxURLsPerSitemap is the most URLs the sitemap protocol allows in one
+// file.
+const maxURLsPerSitemap = 50000
+
+type ScrollResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			ID     string `json:"_id"`
+			Source struct {
+				IndexedAt time.Time `json:"indexed_at"`
+			} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+type URLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type SitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []SitemapURL `xml:"sitemap"`
+}
+
+// Main runs sitemap with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("sitemap", flag.ExitOnError)
+	indexPtr := flags.String("index", "books", "Index to list books from")
+	baseURLPtr := flags.String("base-url", "", "URL of the book detail page, which each book's ID is appended to, e.g. https://books.example.com/books/")
+	sitemapsURLPtr := flags.String("sitemaps-url", "", "URL the sitemap files are served from, defaults to the root of -base-url")
+	outputDirPtr := flags.String("output-dir", "sitemap", "Directory to write sitemap.xml and its sitemap-N.xml shards to")
+	shardSizePtr := flags.Int("shard-size", maxURLsPerSitemap, "Most URLs to put in each shard")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	if *baseURLPtr == "" {
+		log.Fatalf("No -base-url provided for book detail pages")
+	}
+	if *shardSizePtr < 1 || *shardSizePtr > maxURLsPerSitemap {
+		log.Fatalf("-shard-size must be between 1 and %d, got %d", maxURLsPerSitemap, *shardSizePtr)
+	}
+	sitemapsURL := *sitemapsURLPtr
+	if sitemapsURL == "" {
+		baseURL, err := url.Parse(*baseURLPtr)
+		if err != nil {
+			log.Fatalf("Invalid -base-url: %v", err)
+		}
+		sitemapsURL = (&url.URL{Scheme: baseURL.Scheme, Host: baseURL.Host, Path: "/"}).String()
+	}
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("sitemap")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(*outputDirPtr, 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	var sitemapIndex SitemapIndex
+	var shard URLSet
+	writeShard := func() {
+		name := fmt.Sprintf("sitemap-%d.xml", len(sitemapIndex.Sitemaps)+1)
+		if err := writeXML(filepath.Join(*outputDirPtr, name), shard); err != nil {
+			log.Fatal(err)
+		}
+		sitemapIndex.Sitemaps = append(sitemapIndex.Sitemaps, SitemapURL{
+			Loc:     joinURL(sitemapsURL, name),
+			LastMod: time.Now().UTC().Format(time.RFC3339),
+		})
+		shard.URLs = nil
+	}
+
+	total := 0
+	err = scrollBooks(client, *indexPtr, func(id string, indexedAt time.Time) {
+		bookURL := SitemapURL{Loc: *baseURLPtr + url.PathEscape(id)}
+		if !indexedAt.IsZero() {
+			bookURL.LastMod = indexedAt.Format(time.RFC3339)
+		}
+		shard.URLs = append(shard.URLs, bookURL)
+		total++
+		if len(shard.URLs) == *shardSizePtr {
+			writeShard()
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(shard.URLs) > 0 {
+		writeShard()
+	}
+
+	if err := writeXML(filepath.Join(*outputDirPtr, "sitemap.xml"), sitemapIndex); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote %d book URLs to %d sitemaps in %s\n", total, len(sitemapIndex.Sitemaps), *outputDirPtr)
+}
+
+// scrollBooks calls add with the ID and load time of every book in index
+// that hasn't been deleted.
+func scrollBooks(client *elasticsearch7.Client, index string, add func(id string, indexedAt time.Time)) error {
+	body, err := json.Marshal(map[string]interface{}{"query": tombstone.Live(query.MatchAll())})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Search(
+		client.Search.WithIndex(index),
+		client.Search.WithBody(bytes.NewReader(body)),
+		client.Search.WithSort("_doc"),
+		client.Search.WithSize(1000),
+		client.Search.WithSourceIncludes("indexed_at"),
+		client.Search.WithScroll(time.Minute))
+	if err != nil {
+		return err
+	}
+
+	var scrollID string
+	for {
+		if resp.IsError() {
+			resp.Body.Close()
+			return fmt.Errorf("error scrolling, status: %s, response body: %s", resp.Status(), resp.String())
+		}
+
+		var scrollResponse ScrollResponse
+		err = json.NewDecoder(resp.Body).Decode(&scrollResponse)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		scrollID = scrollResponse.ScrollID
+
+		if len(scrollResponse.Hits.Hits) == 0 {
+			break
+		}
+		for _, hit := range scrollResponse.Hits.Hits {
+			add(hit.ID, hit.Source.IndexedAt)
+		}
+
+		resp, err = client.Scroll(
+			client.Scroll.WithScrollID(scrollID),
+			client.Scroll.WithScroll(time.Minute))
+		if err != nil {
+			return err
+		}
+	}
+
+	if scrollID != "" {
+		resp, err := client.ClearScroll(client.ClearScroll.WithScrollID(scrollID))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	return nil
+}
+
+func joinURL(base, name string) string {
+	if base[len(base)-1] != '/' {
+		base += "/"
+	}
+	return base + name
+}
+
+func writeXML(path string, document interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	io.WriteString(file, xml.Header)
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err = io.WriteString(file, "\n")
+	return err
+}
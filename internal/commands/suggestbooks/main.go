@@ -0,0 +1,161 @@
+// Package suggestbooks completes book titles from a prefix.
+package suggestbooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/indexspec"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+type Book struct {
+	Title   string `json:"title"`
+	Url     string `json:"url"`
+	Deleted bool   `json:"deleted"`
+}
+
+type SuggestResponse struct {
+	Took    int `json:"took"`
+	Suggest struct {
+		Titles []struct {
+			Options []struct {
+				Text  string  `json:"text"`
+				ID    string  `json:"_id"`
+				Score float64 `json:"_score"`
+				Book  Book    `json:"_source"`
+			} `json:"options"`
+		} `json:"titles"`
+	} `json:"suggest"`
+}
+
+// Completion is a title suggested for the prefix.
+type Completion struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Url   string  `json:"url"`
+	Score float64 `json:"score"`
+}
+
+// Main runs suggest-books with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("suggest-books", flag.ExitOnError)
+	prefixPtr := flags.String("prefix", "", "Start of a title to complete, e.g. \"lord of the r\"")
+	indexPtr := flags.String("index", "books", "Index to suggest titles from")
+	datasetPtr := flags.String("dataset", "", "Suggest from the index of this dataset from the catalog instead of -index")
+	sizePtr := flags.Int("size", 5, "Number of titles to suggest")
+	fuzzyPtr := flags.Bool("fuzzy", false, "Also complete prefixes with a typo or two")
+	outputPtr := flags.String("output", "text", "How to print suggestions: text, one title per line, or json")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	if *datasetPtr != "" {
+		spec, err := indexspec.LoadDataset(*datasetPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*indexPtr = spec.SearchIndex()
+	}
+	if strings.TrimSpace(*prefixPtr) == "" {
+		log.Fatalf("No prefix provided for -prefix parameter")
+	}
+	if *sizePtr < 1 {
+		log.Fatalf("-size must be at least 1, got %d", *sizePtr)
+	}
+	if *outputPtr != "text" && *outputPtr != "json" {
+		log.Fatalf("Unknown -output %q, expected text or json", *outputPtr)
+	}
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("suggest-books")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	completion := map[string]interface{}{
+		"field": "title_suggest",
+		// Soft deleted books can't be left out by the suggester, so ask
+		// for extra completions to make up for the ones dropped below
+		"size":            *sizePtr * 2,
+		"skip_duplicates": true,
+	}
+	if *fuzzyPtr {
+		completion["fuzzy"] = map[string]interface{}{"fuzziness": "AUTO"}
+	}
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"_source": []string{"title", "url", "deleted"},
+		"suggest": map[string]interface{}{
+			"titles": map[string]interface{}{
+				"prefix":     *prefixPtr,
+				"completion": completion,
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := client.Search(
+		client.Search.WithIndex(*indexPtr),
+		client.Search.WithBody(bytes.NewReader(requestBody)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		log.Fatalf("Error getting suggestions, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var suggestResponse SuggestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&suggestResponse); err != nil {
+		log.Fatal(err)
+	}
+
+	completions := []Completion{}
+	for _, titles := range suggestResponse.Suggest.Titles {
+		for _, option := range titles.Options {
+			if option.Book.Deleted || len(completions) == *sizePtr {
+				continue
+			}
+			completions = append(completions, Completion{
+				ID:    option.ID,
+				Title: option.Book.Title,
+				Url:   option.Book.Url,
+				Score: option.Score,
+			})
+		}
+	}
+
+	if *outputPtr == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(map[string]interface{}{
+			"took":        suggestResponse.Took,
+			"completions": completions,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, completion := range completions {
+		fmt.Println(completion.Title)
+	}
+}
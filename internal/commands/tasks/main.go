@@ -0,0 +1,133 @@
+// Package tasks lists, and cancels, the long running requests made by
+// these tools.
+package tasks
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/joho/godotenv"
+	"github.com/nickcanz/search-go/internal/profile"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+type Task struct {
+	Node               string            `json:"node"`
+	ID                 int64             `json:"id"`
+	Action             string            `json:"action"`
+	Description        string            `json:"description"`
+	StartTimeInMillis  int64             `json:"start_time_in_millis"`
+	RunningTimeInNanos int64             `json:"running_time_in_nanos"`
+	Cancellable        bool              `json:"cancellable"`
+	Headers            map[string]string `json:"headers"`
+}
+
+func (t Task) TaskID() string {
+	return fmt.Sprintf("%s:%d", t.Node, t.ID)
+}
+
+type TaskListResponse struct {
+	Tasks []Task `json:"tasks"`
+}
+
+// Main runs tasks with the command line arguments after its name.
+func Main(args []string) {
+	flags := flag.NewFlagSet("tasks", flag.ExitOnError)
+	actionsPtr := flags.String("actions", "*search*,*reindex*,*byquery*", "Comma separated task actions to list")
+	opaqueIDPtr := flags.String("opaque-id", "search-go/", "Only show tasks whose X-Opaque-Id starts with this, all tasks when empty")
+	cancelPtr := flags.String("cancel", "", "ID of a task to cancel, as node:id")
+	cancelAllPtr := flags.Bool("cancel-all", false, "Cancel every listed task that can be cancelled")
+	profilePtr := flags.String("profile", "", "Connect using this named profile instead of .env")
+	flags.Parse(args)
+
+	err := godotenv.Load()
+	if err != nil && *profilePtr == "" && os.Getenv("ES_URL") == "" {
+		log.Fatal("Error loading .env file")
+	}
+	if *profilePtr != "" {
+		if _, err := profile.Activate(*profilePtr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client, err := esclient.NewClientFromEnv("tasks")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *cancelPtr != "" {
+		if err := cancelTask(client, *cancelPtr); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Cancelled %s\n", *cancelPtr)
+		return
+	}
+
+	resp, err := client.Tasks.List(
+		client.Tasks.List.WithActions(strings.Split(*actionsPtr, ",")...),
+		client.Tasks.List.WithDetailed(true),
+		client.Tasks.List.WithGroupBy("none"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		log.Fatalf("Error listing tasks, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var taskListResponse TaskListResponse
+	err = json.NewDecoder(resp.Body).Decode(&taskListResponse)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tasks []Task
+	for _, task := range taskListResponse.Tasks {
+		if strings.HasPrefix(task.Headers["X-Opaque-Id"], *opaqueIDPtr) {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].StartTimeInMillis < tasks[j].StartTimeInMillis })
+
+	if len(tasks) == 0 {
+		fmt.Println("No matching tasks running")
+		return
+	}
+
+	for _, task := range tasks {
+		running := time.Duration(task.RunningTimeInNanos).Round(time.Millisecond)
+		fmt.Printf("%s %s running for %s, opaque id %q\n", task.TaskID(), task.Action, running, task.Headers["X-Opaque-Id"])
+		if task.Description != "" {
+			fmt.Printf("    %s\n", task.Description)
+		}
+
+		if *cancelAllPtr && task.Cancellable {
+			if err := cancelTask(client, task.TaskID()); err != nil {
+				log.Printf("error cancelling %s: %v", task.TaskID(), err)
+				continue
+			}
+			fmt.Printf("    cancelled\n")
+		}
+	}
+}
+
+func cancelTask(client *elasticsearch7.Client, taskID string) error {
+	resp, err := client.Tasks.Cancel(client.Tasks.Cancel.WithTaskID(taskID))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("error cancelling task, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package contenthash fingerprints documents by their content, so a load
+// can tell which documents have changed since they were last indexed and
+// skip the rest.
+package contenthash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Field is the document field the hash is stored in.
+const Field = "_content_hash"
+
+// Add computes the hash of document, leaving out Field and the ignored
+// fields, which change between loads without the content changing, like
+// a load timestamp. It returns the document with the hash stored in Field,
+// and the hash.
+func Add(document []byte, ignore ...string) ([]byte, string, error) {
+	// Numbers are kept as written, rather than rounded through float64
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, "", err
+	}
+
+	hashed := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		hashed[name] = value
+	}
+	delete(hashed, Field)
+	for _, name := range ignore {
+		delete(hashed, name)
+	}
+	// Maps are marshalled with sorted keys, so the same content always
+	// hashes the same whatever order its fields came in
+	canonical, err := json.Marshal(hashed)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(canonical)
+	hash := hex.EncodeToString(sum[:])
+
+	fields[Field] = hash
+	document, err = json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+	return document, hash, nil
+}
+
+// Stored returns the hash stored in a document's source, or "" if it has
+// none.
+func Stored(source []byte) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(source, &fields); err != nil {
+		return ""
+	}
+	hash, _ := fields[Field].(string)
+	return hash
+}
@@ -0,0 +1,81 @@
+package contenthash
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	document, hash, err := Add([]byte(`{"title":"Dune","ratings_count":12345678901234567890,"indexed_at":"2024-03-01T00:00:00Z"}`), "indexed_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != 64 {
+		t.Errorf("hash %q is not a hex SHA-256", hash)
+	}
+	if stored := Stored(document); stored != hash {
+		t.Errorf("stored hash is %q, want %q", stored, hash)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(document, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// Numbers are kept as written
+	if got := string(fields["ratings_count"]); got != "12345678901234567890" {
+		t.Errorf("ratings_count became %s", got)
+	}
+	if got := string(fields["indexed_at"]); got != `"2024-03-01T00:00:00Z"` {
+		t.Errorf("ignored field indexed_at became %s, want it kept in the document", got)
+	}
+}
+
+func TestAddHashesContent(t *testing.T) {
+	hashOf := func(document string, ignore ...string) string {
+		t.Helper()
+		_, hash, err := Add([]byte(document), ignore...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	base := hashOf(`{"title":"Dune","year":1965}`)
+	tests := []struct {
+		name     string
+		document string
+		ignore   []string
+		same     bool
+	}{
+		{name: "field order", document: `{"year":1965,"title":"Dune"}`, same: true},
+		{name: "whitespace", document: "{ \"title\" : \"Dune\",\n \"year\" : 1965 }", same: true},
+		{name: "ignored field", document: `{"title":"Dune","year":1965,"indexed_at":"now"}`, ignore: []string{"indexed_at"}, same: true},
+		{name: "stored hash", document: `{"title":"Dune","year":1965,"_content_hash":"old"}`, same: true},
+		{name: "changed value", document: `{"title":"Dune","year":1966}`},
+		{name: "number written differently", document: `{"title":"Dune","year":1965.0}`},
+		{name: "field not ignored", document: `{"title":"Dune","year":1965,"indexed_at":"now"}`},
+	}
+	for _, test := range tests {
+		if got := hashOf(test.document, test.ignore...); (got == base) != test.same {
+			t.Errorf("%s: same hash is %t, want %t", test.name, got == base, test.same)
+		}
+	}
+}
+
+func TestAddRejectsNonObjects(t *testing.T) {
+	for _, document := range []string{``, `[1,2]`, `"text"`, `{"title":`} {
+		if _, _, err := Add([]byte(document)); err == nil {
+			t.Errorf("Add(%q) succeeded, want an error", document)
+		}
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	document := []byte(`{"title":"The Lord of the Rings","url":"https://www.goodreads.com/book/show/33","description":"One Ring to rule them all","average_rating":"4.50","ratings_count":"2000000","authors":[{"author_id":"656983"}],"indexed_at":"2024-03-01T00:00:00Z"}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := Add(document, "indexed_at"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package credentials stores Elasticsearch connection details per named
+// profile in files encrypted with an age passphrase, as an alternative to
+// keeping them in plaintext in .env.
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"golang.org/x/term"
+)
+
+// Credentials are the connection details saved for a profile.
+type Credentials struct {
+	URL      string `json:"url"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// Path returns the file the credentials for profile are stored in.
+func Path(profile string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "search-go", "profiles", profile+".age"), nil
+}
+
+// Exists reports whether credentials have been saved for profile.
+func Exists(profile string) (bool, error) {
+	path, err := Path(profile)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Save encrypts creds with passphrase and writes them to the profile's
+// file, readable only by the current user.
+func Save(profile string, creds Credentials, passphrase string) error {
+	path, err := Path(profile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	var encrypted bytes.Buffer
+	writer, err := age.Encrypt(&encrypted, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, encrypted.Bytes(), 0o600)
+}
+
+// Load decrypts the credentials saved for profile.
+func Load(profile, passphrase string) (Credentials, error) {
+	var creds Credentials
+
+	path, err := Path(profile)
+	if err != nil {
+		return creds, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return creds, fmt.Errorf("no credentials saved for profile %q, run login -profile %s", profile, profile)
+	}
+	if err != nil {
+		return creds, err
+	}
+	defer file.Close()
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return creds, err
+	}
+
+	reader, err := age.Decrypt(file, identity)
+	if err != nil {
+		return creds, fmt.Errorf("error decrypting profile %q (wrong passphrase?): %w", profile, err)
+	}
+	plaintext, err := io.ReadAll(reader)
+	if err != nil {
+		return creds, err
+	}
+
+	err = json.Unmarshal(plaintext, &creds)
+	return creds, err
+}
+
+// Passphrase returns SEARCH_GO_PASSPHRASE when it is set, for scripts, and
+// otherwise prompts for the passphrase on the terminal without echoing it.
+func Passphrase(prompt string) (string, error) {
+	if passphrase := os.Getenv("SEARCH_GO_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	return ReadSecret(prompt)
+}
+
+// ReadSecret prompts on stderr and reads a line from the terminal without
+// echoing it.
+func ReadSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// Activate decrypts the credentials for profile and exports them as
+// ES_URL, ES_USER, and ES_PASSWORD, overriding any values from .env.
+func Activate(profile string) error {
+	passphrase, err := Passphrase(fmt.Sprintf("Passphrase for profile %s: ", profile))
+	if err != nil {
+		return err
+	}
+
+	creds, err := Load(profile, passphrase)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range map[string]string{
+		"ES_URL":      creds.URL,
+		"ES_USER":     creds.User,
+		"ES_PASSWORD": creds.Password,
+	} {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Package deadletter records documents Elasticsearch refused during a
+// bulk load in an NDJSON file, one entry per line with the error, so they
+// can be fixed and resubmitted with retry-failed instead of rerunning the
+// whole load.
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/nickcanz/search-go/internal/filelock"
+)
+
+// Entry is one failed document.
+type Entry struct {
+	Index      string          `json:"index"`
+	DocumentID string          `json:"id,omitempty"`
+	Routing    string          `json:"routing,omitempty"`
+	Status     int             `json:"status,omitempty"`
+	Error      string          `json:"error"`
+	Document   json.RawMessage `json:"document"`
+	// Untransformed is set when a transform failed on the document, so
+	// Document is what the transforms were given rather than what would
+	// have been indexed, and resubmitting it would skip them.
+	Untransformed bool `json:"untransformed,omitempty"`
+}
+
+// NewEntry describes a failed bulk item, given the document it indexed
+// and the error from OnFailure.
+func NewEntry(index, routing string, document []byte, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) Entry {
+	entry := Entry{
+		Index:      index,
+		DocumentID: item.DocumentID,
+		Routing:    routing,
+		Status:     res.Status,
+		Document:   json.RawMessage(document),
+	}
+	if item.Index != "" {
+		entry.Index = item.Index
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Error = fmt.Sprintf("%s: %s", res.Error.Type, res.Error.Reason)
+	}
+	return entry
+}
+
+// NewTransformEntry describes a document a transform failed on, given the
+// document before any transform ran.
+func NewTransformEntry(index string, document []byte, err error) Entry {
+	return Entry{
+		Index:         index,
+		Error:         err.Error(),
+		Document:      json.RawMessage(document),
+		Untransformed: true,
+	}
+}
+
+// Writer appends entries to a dead-letter file. It is safe for concurrent
+// use, since bulk indexer workers report failures in parallel.
+type Writer struct {
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	count int64
+}
+
+// Create returns a Writer for the dead-letter file at path. The file is
+// only opened, and locked, when the first entry is written, so a load
+// where nothing fails leaves it alone. Entries are appended, so failures
+// from an earlier load that weren't retried yet are kept.
+func Create(path string) (*Writer, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	return &Writer{path: path}, nil
+}
+
+// Write appends entry to the file.
+func (w *Writer) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		w.file, err = filelock.OpenAppend(w.path)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := w.file.Write(line); err != nil {
+		return fmt.Errorf("error writing to %s: %w", w.path, err)
+	}
+	w.count++
+	return nil
+}
+
+// Count returns how many entries have been written.
+func (w *Writer) Count() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Close closes the file if anything was written to it.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Pending returns how many entries the dead-letter file at path already
+// holds, or 0 if there is no such file.
+func Pending(path string) (int, error) {
+	entries, err := Read(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return len(entries), err
+}
+
+// Read returns every entry in the dead-letter file at path.
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readEntries(file, path)
+}
+
+func readEntries(reader io.Reader, path string) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(reader)
+	// Documents can be far longer than the default 64KB line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if entry.Index == "" || len(entry.Document) == 0 {
+			return nil, fmt.Errorf("%s line %d: entry has no index or document", path, line)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// File is a dead-letter file locked with the same lock a Writer takes, so
+// no load can add entries to it while they are retried and rewritten.
+type File struct {
+	path string
+	file *os.File
+}
+
+// Lock opens and locks the existing dead-letter file at path. It fails if
+// a load is writing to the file.
+func Lock(path string) (*File, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	file, err := filelock.OpenAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, file: file}, nil
+}
+
+// Entries returns every entry in the file.
+func (f *File) Entries() ([]Entry, error) {
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return readEntries(f.file, f.path)
+}
+
+// Rewrite replaces the file's entries with entries. They are written in
+// a single write, once the file is emptied, to keep the time the file is
+// incomplete short.
+func (f *File) Rewrite(entries []Entry) error {
+	var lines []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if err := f.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.file.Write(lines); err != nil {
+		return fmt.Errorf("error writing to %s: %w", f.path, err)
+	}
+	return f.file.Sync()
+}
+
+// Remove deletes the file and unlocks it. It is removed while still
+// locked, so no load can add an entry in between, except on Windows,
+// which can't remove an open file.
+func (f *File) Remove() error {
+	if err := os.Remove(f.path); err == nil {
+		return f.file.Close()
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f.path)
+}
+
+// Close unlocks the file.
+func (f *File) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,165 @@
+package deadletter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/nickcanz/search-go/internal/filelock"
+)
+
+func TestWriterOnlyCreatesFileOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.json")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("a load without failures left %s behind: %v", path, err)
+	}
+}
+
+func TestWriterAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.json")
+	for _, id := range []string{"1", "2"} {
+		w, err := Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(Entry{Index: "books", DocumentID: id, Error: "mapper_parsing_exception", Document: []byte(`{"title":"Dune"}`)}); err != nil {
+			t.Fatal(err)
+		}
+		if w.Count() != 1 {
+			t.Errorf("Count() = %d, want 1", w.Count())
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A second load with nothing failing leaves the earlier failures alone
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].DocumentID != "1" || entries[1].DocumentID != "2" {
+		t.Errorf("read %+v, want the failures of both loads in order", entries)
+	}
+	if pending, err := Pending(path); err != nil || pending != 2 {
+		t.Errorf("Pending() = %d, %v, want 2", pending, err)
+	}
+}
+
+func TestPendingWithoutFile(t *testing.T) {
+	pending, err := Pending(filepath.Join(t.TempDir(), "failed.json"))
+	if err != nil || pending != 0 {
+		t.Errorf("Pending() = %d, %v, want 0", pending, err)
+	}
+}
+
+func TestNewTransformEntry(t *testing.T) {
+	entry := NewTransformEntry("books", []byte(`{"title":"Dune"}`), errors.New("transform-upper.wasm: transform took longer than 1s"))
+	if !entry.Untransformed || entry.Index != "books" || entry.Error != "transform-upper.wasm: transform took longer than 1s" || string(entry.Document) != `{"title":"Dune"}` {
+		t.Errorf("NewTransformEntry returned %+v", entry)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, content := range []string{"not json\n", `{"index":"books"}` + "\n", `{"document":{}}` + "\n"} {
+		path := filepath.Join(t.TempDir(), "failed.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Read(path); err == nil {
+			t.Errorf("Read(%q) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestLockKeepsLoadsOut(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("no file locking on this platform")
+	}
+	path := filepath.Join(t.TempDir(), "failed.json")
+	dune := Entry{Index: "books", DocumentID: "1", Error: "mapper_parsing_exception", Document: []byte(`{"title":"Dune"}`)}
+	emma := Entry{Index: "books", DocumentID: "2", Error: "es_rejected_execution_exception", Document: []byte(`{"title":"Emma"}`)}
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []Entry{dune, emma} {
+		if err := w.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A running load keeps the file locked
+	if _, err := Lock(path); !errors.Is(err, filelock.ErrLocked) {
+		t.Errorf("Lock() during a load = %v, want ErrLocked", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := f.Entries()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Entries() = %+v, %v, want both entries", entries, err)
+	}
+
+	// A load failing during the retry can't add to the file
+	load, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := load.Write(dune); !errors.Is(err, filelock.ErrLocked) {
+		t.Errorf("Write() during a retry = %v, want ErrLocked", err)
+	}
+
+	if err := f.Rewrite([]Entry{emma}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = Read(path)
+	if err != nil || len(entries) != 1 || entries[0].DocumentID != "2" {
+		t.Errorf("read %+v, %v after the rewrite, want only the entry that failed again", entries, err)
+	}
+
+	f, err = Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s is still there after Remove: %v", path, err)
+	}
+}
+
+func TestLockWithoutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.json")
+	if _, err := Lock(path); !os.IsNotExist(err) {
+		t.Errorf("Lock() = %v, want a not exist error", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Lock() created %s", path)
+	}
+}
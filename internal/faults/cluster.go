@@ -0,0 +1,127 @@
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Cluster is a fake cluster that only serves bulk requests, keeping the
+// documents they index, so tests can check what made it through a
+// Transport without running Elasticsearch. Updates store the upsert
+// document rather than running their script.
+type Cluster struct {
+	URL string
+
+	server *httptest.Server
+	mu     sync.Mutex
+	// documents holds each index's documents by id
+	documents map[string]map[string]json.RawMessage
+	nextID    int
+}
+
+// NewCluster starts a Cluster. Close stops it.
+func NewCluster() *Cluster {
+	c := &Cluster{documents: map[string]map[string]json.RawMessage{}}
+	c.server = httptest.NewServer(http.HandlerFunc(c.serve))
+	c.URL = c.server.URL
+	return c
+}
+
+// Close stops the cluster.
+func (c *Cluster) Close() {
+	c.server.Close()
+}
+
+// Documents returns the documents of index by id.
+func (c *Cluster) Documents(index string) map[string]json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	documents := map[string]json.RawMessage{}
+	for id, document := range c.documents[index] {
+		documents[id] = document
+	}
+	return documents
+}
+
+func (c *Cluster) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost && r.Method != http.MethodPut || !strings.HasSuffix(r.URL.Path, "/_bulk") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":{"type":"unsupported_operation","reason":"the fake cluster only serves bulk requests, not %s %s"},"status":404}`, r.Method, r.URL.Path)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	items, err := parseBulk(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":{"type":"parse_exception","reason":%q},"status":400}`, err.Error())
+		return
+	}
+
+	pathIndex := strings.Trim(strings.TrimSuffix(r.URL.Path, "_bulk"), "/")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var results []interface{}
+	for _, item := range items {
+		index, _ := item.meta["_index"].(string)
+		if index == "" {
+			index = pathIndex
+		}
+		id, _ := item.meta["_id"].(string)
+		if id == "" {
+			c.nextID++
+			id = fmt.Sprintf("generated-%d", c.nextID)
+		}
+		if c.documents[index] == nil {
+			c.documents[index] = map[string]json.RawMessage{}
+		}
+		_, exists := c.documents[index][id]
+
+		document := item.lines[bytes.IndexByte(item.lines, '\n')+1:]
+		if item.action == "update" {
+			var update struct {
+				Upsert json.RawMessage `json:"upsert"`
+				Doc    json.RawMessage `json:"doc"`
+			}
+			if err := json.Unmarshal(document, &update); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			document = update.Upsert
+			if document == nil {
+				document = update.Doc
+			}
+		}
+
+		result, status := "created", http.StatusCreated
+		switch {
+		case item.action == "delete" && exists:
+			delete(c.documents[index], id)
+			result, status = "deleted", http.StatusOK
+		case item.action == "delete":
+			result, status = "not_found", http.StatusNotFound
+		default:
+			c.documents[index][id] = append(json.RawMessage(nil), bytes.TrimSpace(document)...)
+			if exists {
+				result, status = "updated", http.StatusOK
+			}
+		}
+		results = append(results, map[string]interface{}{item.action: map[string]interface{}{
+			"_index": index,
+			"_id":    id,
+			"result": result,
+			"status": status,
+		}})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "errors": false, "items": results})
+}
@@ -0,0 +1,343 @@
+// Package faults injects the failures of a busy or flaky cluster into the
+// requests a client sends: slow responses, requests rejected with 429,
+// connections reset, and bulk requests that only index some of their
+// documents. It checks that the commands retry, back off and dead-letter
+// documents instead of losing them, in tests and, with SEARCH_GO_FAULTS,
+// against a real cluster.
+package faults
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Config sets how often each fault is injected, as a rate between 0 and 1.
+type Config struct {
+	// Latency is added before a request is sent, at LatencyRate
+	Latency     time.Duration
+	LatencyRate float64
+	// TooManyRequests answers a request with a 429 without sending it
+	TooManyRequests float64
+	// Reset fails a request with a connection reset without sending it
+	Reset float64
+	// BulkItem rejects each document of a bulk request with a 429, as a
+	// node with a full write queue does, and sends the rest
+	BulkItem float64
+	// Seed makes the faults repeatable for requests sent in the same
+	// order. Zero picks one from the time.
+	Seed int64
+}
+
+// Counts are the faults a Transport injected.
+type Counts struct {
+	Latency         int64
+	TooManyRequests int64
+	Reset           int64
+	BulkItems       int64
+}
+
+// Transport is an http.RoundTripper that injects faults into the requests
+// it sends with another one.
+type Transport struct {
+	next   http.RoundTripper
+	config Config
+
+	mu     sync.Mutex
+	random *rand.Rand
+	counts Counts
+}
+
+// New returns a Transport that sends requests with next, or
+// http.DefaultTransport when next is nil.
+func New(next http.RoundTripper, config Config) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Transport{next: next, config: config, random: rand.New(rand.NewSource(seed))}
+}
+
+// Counts returns the faults injected so far.
+func (t *Transport) Counts() Counts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts
+}
+
+// hit reports whether a fault with the given rate happens, counting it in
+// count when it does.
+func (t *Transport) hit(rate float64, count *int64) bool {
+	if rate <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.random.Float64() >= rate {
+		return false
+	}
+	*count++
+	return true
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.hit(t.config.LatencyRate, &t.counts.Latency) {
+		timer := time.NewTimer(t.config.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if t.hit(t.config.Reset, &t.counts.Reset) {
+		closeBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	}
+	if t.hit(t.config.TooManyRequests, &t.counts.TooManyRequests) {
+		closeBody(req)
+		return jsonResponse(req, http.StatusTooManyRequests, map[string]interface{}{
+			"error":  rejection(),
+			"status": http.StatusTooManyRequests,
+		})
+	}
+	if t.config.BulkItem > 0 && req.Body != nil && strings.HasSuffix(req.URL.Path, "/_bulk") {
+		return t.bulk(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// bulkItem is one action of a bulk request, with its document unless it
+// is a delete.
+type bulkItem struct {
+	action string
+	meta   map[string]interface{}
+	lines  []byte
+}
+
+// bulk rejects some of the documents of a bulk request, sends the rest
+// and puts the rejections back in the response where they were in the
+// request.
+func (t *Transport) bulk(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseBulk(body)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := make([]bool, len(items))
+	anyRejected := false
+	var kept bytes.Buffer
+	for i, item := range items {
+		if t.hit(t.config.BulkItem, &t.counts.BulkItems) {
+			rejected[i], anyRejected = true, true
+			continue
+		}
+		kept.Write(item.lines)
+	}
+
+	var sent []json.RawMessage
+	errors := anyRejected
+	if kept.Len() > 0 {
+		forward := req.Clone(req.Context())
+		forward.Body = io.NopCloser(bytes.NewReader(kept.Bytes()))
+		forward.ContentLength = int64(kept.Len())
+		forward.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(kept.Bytes())), nil
+		}
+		res, err := t.next.RoundTrip(forward)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			return res, nil
+		}
+		var response struct {
+			Errors bool              `json:"errors"`
+			Items  []json.RawMessage `json:"items"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading bulk response: %w", err)
+		}
+		sent = response.Items
+		errors = errors || response.Errors
+	}
+
+	index := strings.Trim(strings.TrimSuffix(req.URL.Path, "_bulk"), "/")
+	responseItems := make([]interface{}, 0, len(items))
+	for i, item := range items {
+		if !rejected[i] {
+			if len(sent) == 0 {
+				return nil, fmt.Errorf("bulk response has fewer items than were sent")
+			}
+			responseItems = append(responseItems, sent[0])
+			sent = sent[1:]
+			continue
+		}
+		result := map[string]interface{}{
+			"_index": index,
+			"status": http.StatusTooManyRequests,
+			"error":  rejection(),
+		}
+		for _, field := range []string{"_index", "_id"} {
+			if value, ok := item.meta[field]; ok {
+				result[field] = value
+			}
+		}
+		responseItems = append(responseItems, map[string]interface{}{item.action: result})
+	}
+	return jsonResponse(req, http.StatusOK, map[string]interface{}{
+		"took":   0,
+		"errors": errors,
+		"items":  responseItems,
+	})
+}
+
+// parseBulk splits an NDJSON bulk body into its actions.
+func parseBulk(body []byte) ([]bulkItem, error) {
+	var items []bulkItem
+	reader := bufio.NewReader(bytes.NewReader(body))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var action map[string]map[string]interface{}
+			if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+				return nil, fmt.Errorf("invalid bulk action %q", line)
+			}
+			item := bulkItem{lines: line}
+			for name, meta := range action {
+				item.action, item.meta = name, meta
+			}
+			if item.action != "delete" {
+				document, err := reader.ReadBytes('\n')
+				if err != nil && err != io.EOF {
+					return nil, err
+				}
+				if len(document) == 0 {
+					return nil, fmt.Errorf("bulk %s action without a document", item.action)
+				}
+				item.lines = append(item.lines, document...)
+			}
+			items = append(items, item)
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+	}
+}
+
+// rejection is the error a node returns when its write queue is full.
+func rejection() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   "es_rejected_execution_exception",
+		"reason": "rejected execution of coordinating operation (injected fault)",
+	}
+}
+
+func jsonResponse(req *http.Request, status int, body interface{}) (*http.Response, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(encoded)),
+		ContentLength: int64(len(encoded)),
+		Request:       req,
+	}, nil
+}
+
+// closeBody closes the body of a request that is never sent, as a
+// RoundTripper must.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// Parse reads a Config from a comma separated list of fault=rate pairs,
+// e.g. "latency=0.2:250ms,429=0.05,reset=0.01,bulk=0.1,seed=42". Latency
+// takes the delay after its rate.
+func Parse(spec string) (Config, error) {
+	var config Config
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			return Config{}, fmt.Errorf("invalid fault %q, expected name=rate", pair)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		if name == "seed" {
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid fault seed %q", value)
+			}
+			config.Seed = seed
+			continue
+		}
+
+		if name == "latency" {
+			rateValue, delay, found := strings.Cut(value, ":")
+			if !found {
+				return Config{}, fmt.Errorf("invalid latency %q, expected rate:delay like 0.2:250ms", value)
+			}
+			latency, err := time.ParseDuration(delay)
+			if err != nil || latency < 0 {
+				return Config{}, fmt.Errorf("invalid latency delay %q", delay)
+			}
+			config.Latency = latency
+			value = rateValue
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return Config{}, fmt.Errorf("invalid rate %q for %s, expected a number from 0 to 1", value, name)
+		}
+
+		switch name {
+		case "latency":
+			config.LatencyRate = rate
+		case "429":
+			config.TooManyRequests = rate
+		case "reset":
+			config.Reset = rate
+		case "bulk":
+			config.BulkItem = rate
+		default:
+			return Config{}, fmt.Errorf("unknown fault %q, expected latency, 429, reset, bulk or seed", name)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,225 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testBulk = `{"index":{"_index":"books","_id":"1"}}
+{"title":"One"}
+{"delete":{"_index":"books","_id":"2"}}
+{"update":{"_id":"3"}}
+{"doc":{"title":"Three"},"upsert":{"title":"Three"}}
+`
+
+func sendBulk(t *testing.T, transport http.RoundTripper, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"/books/_bulk", strings.NewReader(testBulk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestTooManyRequests(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	transport := New(nil, Config{TooManyRequests: 1})
+
+	res := sendBulk(t, transport, cluster.URL)
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status %d, want 429", res.StatusCode)
+	}
+	if documents := cluster.Documents("books"); len(documents) != 0 {
+		t.Errorf("a rejected request indexed %v", documents)
+	}
+	if counts := transport.Counts(); counts.TooManyRequests != 1 {
+		t.Errorf("counted %+v, want one 429", counts)
+	}
+}
+
+func TestReset(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	transport := New(nil, Config{Reset: 1})
+
+	req, err := http.NewRequest(http.MethodPost, cluster.URL+"/books/_bulk", strings.NewReader(testBulk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = transport.RoundTrip(req)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("error %v, want a net.Error for a connection reset, which the client retries", err)
+	}
+	if documents := cluster.Documents("books"); len(documents) != 0 {
+		t.Errorf("a reset request indexed %v", documents)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	transport := New(nil, Config{Latency: 50 * time.Millisecond, LatencyRate: 1})
+
+	start := time.Now()
+	res := sendBulk(t, transport, cluster.URL)
+	res.Body.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("request took %v, want at least the 50ms added", elapsed)
+	}
+
+	// A request given up on doesn't wait out the delay
+	transport = New(nil, Config{Latency: time.Hour, LatencyRate: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cluster.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want the context's", err)
+	}
+}
+
+func TestBulkItems(t *testing.T) {
+	tests := []struct {
+		name   string
+		rate   float64
+		status []int
+		stored []string
+	}{
+		{name: "none rejected", rate: 0, status: []int{201, 404, 201}, stored: []string{"1", "3"}},
+		{name: "all rejected", rate: 1, status: []int{429, 429, 429}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := NewCluster()
+			defer cluster.Close()
+			transport := New(nil, Config{BulkItem: test.rate})
+
+			res := sendBulk(t, transport, cluster.URL)
+			defer res.Body.Close()
+			var response struct {
+				Errors bool                                `json:"errors"`
+				Items  []map[string]map[string]interface{} `json:"items"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Items) != len(test.status) {
+				t.Fatalf("%d items in the response, want %d", len(response.Items), len(test.status))
+			}
+			for i, item := range response.Items {
+				for action, result := range item {
+					if status := int(result["status"].(float64)); status != test.status[i] {
+						t.Errorf("item %d %s status %d, want %d", i, action, status, test.status[i])
+					}
+				}
+			}
+			if response.Errors != (test.rate > 0) {
+				t.Errorf("errors is %t", response.Errors)
+			}
+			if documents := cluster.Documents("books"); len(documents) != len(test.stored) {
+				t.Errorf("stored %v, want %v", documents, test.stored)
+			}
+		})
+	}
+}
+
+func TestBulkItemsKeepOrder(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	transport := New(nil, Config{BulkItem: 0.5, Seed: 1})
+
+	var bulk strings.Builder
+	for i := 0; i < 100; i++ {
+		bulk.WriteString(`{"index":{"_id":"` + strconv.Itoa(i) + `"}}` + "\n{}\n")
+	}
+	req, err := http.NewRequest(http.MethodPost, cluster.URL+"/books/_bulk", strings.NewReader(bulk.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Items []struct {
+			Index struct {
+				ID     string `json:"_id"`
+				Status int    `json:"status"`
+			} `json:"index"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := cluster.Documents("books")
+	rejected := 0
+	for i, item := range response.Items {
+		want := strconv.Itoa(i)
+		if item.Index.ID != want {
+			t.Fatalf("item %d is for %s, want %s", i, item.Index.ID, want)
+		}
+		_, ok := stored[want]
+		if ok != (item.Index.Status == http.StatusCreated) {
+			t.Errorf("%s answered %d but stored is %t", want, item.Index.Status, ok)
+		}
+		if item.Index.Status == http.StatusTooManyRequests {
+			rejected++
+		}
+	}
+	if counts := transport.Counts(); rejected == 0 || counts.BulkItems != int64(rejected) {
+		t.Errorf("rejected %d items and counted %d", rejected, counts.BulkItems)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Config
+		wantErr bool
+	}{
+		{spec: "", want: Config{}},
+		{spec: "latency=0.2:250ms, 429=0.05,reset=0.01,bulk=0.1,seed=42", want: Config{Latency: 250 * time.Millisecond, LatencyRate: 0.2, TooManyRequests: 0.05, Reset: 0.01, BulkItem: 0.1, Seed: 42}},
+		{spec: "bulk=1", want: Config{BulkItem: 1}},
+		{spec: "bulk", wantErr: true},
+		{spec: "bulk=1.5", wantErr: true},
+		{spec: "bulk=-0.1", wantErr: true},
+		{spec: "latency=0.2", wantErr: true},
+		{spec: "latency=0.2:soon", wantErr: true},
+		{spec: "timeout=0.1", wantErr: true},
+		{spec: "seed=x", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.spec)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %t", test.spec, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.spec, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package filelock opens files that only one process may write at a time,
+// such as a loader's rejects file, so two loads started by accident in
+// the same directory fail fast instead of interleaving their output. Locks
+// are advisory and released when the file is closed or the process exits.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned when another process holds the lock.
+var ErrLocked = errors.New("locked by another process")
+
+// Create opens path for writing, creating it if needed, locks it, and then
+// truncates it. The file is not truncated if it is locked, so the other
+// process's output is left alone.
+func Create(path string) (*os.File, error) {
+	file, err := OpenAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenAppend opens path for appending, creating it if needed, and locks
+// it.
+func OpenAppend(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%s is %w, is another load running?", path, err)
+		}
+		return nil, fmt.Errorf("error locking %s: %w", path, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package filelock
+
+import "os"
+
+// lock does nothing on platforms without file locking.
+func lock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package i18n
+
+type catalog struct {
+	decimal  string
+	group    string
+	messages map[string]string
+}
+
+// catalogs holds the translations of each language other than English,
+// keyed by the English message.
+var catalogs = map[string]catalog{
+	"de": {
+		decimal: ",",
+		group:   ".",
+		messages: map[string]string{
+			"No query provided for -query parameter":                                 "Keine Suchanfrage für den Parameter -query angegeben",
+			"Unknown -operator %q, expected and or or":                               "Unbekannter -operator %q, erwartet and oder or",
+			"Unknown -type %q, expected best_fields, most_fields, or cross_fields":   "Unbekannter -type %q, erwartet best_fields, most_fields oder cross_fields",
+			"-tie-breaker must be between 0 and 1, got %s":                           "-tie-breaker muss zwischen 0 und 1 liegen, angegeben war %s",
+			"-fuzzy cannot be combined with -type cross_fields":                      "-fuzzy kann nicht mit -type cross_fields kombiniert werden",
+			"-phrase cannot be combined with -exact":                                 "-phrase kann nicht mit -exact kombiniert werden",
+			"-fuzzy cannot be combined with -phrase or -exact":                       "-fuzzy kann nicht mit -phrase oder -exact kombiniert werden",
+			"-phrase cannot be combined with -type %s":                               "-phrase kann nicht mit -type %s kombiniert werden",
+			"-intent cannot be combined with -phrase or -exact":                      "-intent kann nicht mit -phrase oder -exact kombiniert werden",
+			"No -enrich-fields provided to merge from -enrich-index":                 "Keine -enrich-fields angegeben, die aus -enrich-index übernommen werden sollen",
+			"Unknown -boundary-scanner %q, expected sentence or word":                "Unbekannter -boundary-scanner %q, erwartet sentence oder word",
+			"Unknown -color %q, expected auto, always, or never":                     "Unbekannte -color %q, erwartet auto, always oder never",
+			"Unknown -output %q, expected text, table, json, ndjson, or csv":         "Unbekannte -output %q, erwartet text, table, json, ndjson oder csv",
+			"-plain can only be used with -output text":                              "-plain kann nur mit -output text verwendet werden",
+			"-size must be at least 1, got %s":                                       "-size muss mindestens 1 sein, angegeben war %s",
+			"-facet-size must be at least 1, got %s":                                 "-facet-size muss mindestens 1 sein, angegeben war %s",
+			"-min-score must be at least 0, got %s":                                  "-min-score muss mindestens 0 sein, angegeben war %s",
+			"-min-rating must be between 0 and 5, got %s":                            "-min-rating muss zwischen 0 und 5 liegen, angegeben war %s",
+			"-year-from %d is after -year-to %d":                                     "-year-from %d liegt nach -year-to %d",
+			"-from cannot be combined with -cursor or -after":                        "-from kann nicht mit -cursor oder -after kombiniert werden",
+			"Searching books for: %s":                                                "Suche Bücher nach: %s",
+			"Unknown -intent %q, expected auto, topic, title, author, isbn, or asin": "Unbekannter -intent %q, erwartet auto, topic, title, author, isbn oder asin",
+			"Looking up %s: %s":                                                      "Suche nach %s: %s",
+			"No book found with %s %s":                                               "Kein Buch mit %s %s gefunden",
+			"Error querying, status: %s, response body: %s":                          "Fehler bei der Suche, Status: %s, Antwort: %s",
+			"Showing %s of %s books, found in %s ms":                                 "%s von %s Büchern, gefunden in %s ms",
+			"Showing %s of more than %s books, found in %s ms":                       "%s von mehr als %s Büchern, gefunden in %s ms",
+			"%s, %s with score of %s":                                                "%s, %s mit einer Bewertung von %s",
+			"matched: %s":                                                            "Treffer in: %s",
+			"sorted by: %s":                                                          "sortiert nach: %s",
+			"next page: -after %s":                                                   "nächste Seite: -after %s",
+		},
+	},
+	"es": {
+		decimal: ",",
+		group:   ".",
+		messages: map[string]string{
+			"No query provided for -query parameter":                                 "No se indicó ninguna consulta en el parámetro -query",
+			"Unknown -operator %q, expected and or or":                               "-operator %q desconocido, se esperaba and u or",
+			"Unknown -type %q, expected best_fields, most_fields, or cross_fields":   "-type %q desconocido, se esperaba best_fields, most_fields o cross_fields",
+			"-tie-breaker must be between 0 and 1, got %s":                           "-tie-breaker debe estar entre 0 y 1, se indicó %s",
+			"-fuzzy cannot be combined with -type cross_fields":                      "-fuzzy no se puede combinar con -type cross_fields",
+			"-phrase cannot be combined with -exact":                                 "-phrase no se puede combinar con -exact",
+			"-fuzzy cannot be combined with -phrase or -exact":                       "-fuzzy no se puede combinar con -phrase ni con -exact",
+			"-phrase cannot be combined with -type %s":                               "-phrase no se puede combinar con -type %s",
+			"-intent cannot be combined with -phrase or -exact":                      "-intent no se puede combinar con -phrase ni con -exact",
+			"No -enrich-fields provided to merge from -enrich-index":                 "No se indicaron -enrich-fields para combinar desde -enrich-index",
+			"Unknown -boundary-scanner %q, expected sentence or word":                "-boundary-scanner %q desconocido, se esperaba sentence o word",
+			"Unknown -color %q, expected auto, always, or never":                     "-color %q desconocido, se esperaba auto, always o never",
+			"Unknown -output %q, expected text, table, json, ndjson, or csv":         "-output %q desconocido, se esperaba text, table, json, ndjson o csv",
+			"-plain can only be used with -output text":                              "-plain solo se puede usar con -output text",
+			"-size must be at least 1, got %s":                                       "-size debe ser al menos 1, se indicó %s",
+			"-facet-size must be at least 1, got %s":                                 "-facet-size debe ser al menos 1, se indicó %s",
+			"-min-score must be at least 0, got %s":                                  "-min-score debe ser al menos 0, se indicó %s",
+			"-min-rating must be between 0 and 5, got %s":                            "-min-rating debe estar entre 0 y 5, se indicó %s",
+			"-year-from %d is after -year-to %d":                                     "-year-from %d es posterior a -year-to %d",
+			"-from cannot be combined with -cursor or -after":                        "-from no se puede combinar con -cursor ni -after",
+			"Searching books for: %s":                                                "Buscando libros: %s",
+			"Unknown -intent %q, expected auto, topic, title, author, isbn, or asin": "-intent %q desconocido, se esperaba auto, topic, title, author, isbn o asin",
+			"Looking up %s: %s":                                                      "Buscando por %s: %s",
+			"No book found with %s %s":                                               "No se encontró ningún libro con %s %s",
+			"Error querying, status: %s, response body: %s":                          "Error en la consulta, estado: %s, respuesta: %s",
+			"Showing %s of %s books, found in %s ms":                                 "Mostrando %s de %s libros, encontrados en %s ms",
+			"Showing %s of more than %s books, found in %s ms":                       "Mostrando %s de más de %s libros, encontrados en %s ms",
+			"%s, %s with score of %s":                                                "%s, %s con una puntuación de %s",
+			"matched: %s":                                                            "coincide en: %s",
+			"sorted by: %s":                                                          "ordenado por: %s",
+			"next page: -after %s":                                                   "página siguiente: -after %s",
+		},
+	},
+	"fr": {
+		decimal: ",",
+		group:   " ",
+		messages: map[string]string{
+			"No query provided for -query parameter":                                 "Aucune requête fournie pour le paramètre -query",
+			"Unknown -operator %q, expected and or or":                               "-operator %q inconnu, and ou or attendu",
+			"Unknown -type %q, expected best_fields, most_fields, or cross_fields":   "-type %q inconnu, best_fields, most_fields ou cross_fields attendu",
+			"-tie-breaker must be between 0 and 1, got %s":                           "-tie-breaker doit être compris entre 0 et 1, reçu %s",
+			"-fuzzy cannot be combined with -type cross_fields":                      "-fuzzy ne peut pas être combiné avec -type cross_fields",
+			"-phrase cannot be combined with -exact":                                 "-phrase ne peut pas être combiné avec -exact",
+			"-fuzzy cannot be combined with -phrase or -exact":                       "-fuzzy ne peut pas être combiné avec -phrase ou -exact",
+			"-phrase cannot be combined with -type %s":                               "-phrase ne peut pas être combiné avec -type %s",
+			"-intent cannot be combined with -phrase or -exact":                      "-intent ne peut pas être combiné avec -phrase ou -exact",
+			"No -enrich-fields provided to merge from -enrich-index":                 "Aucun -enrich-fields fourni à reprendre de -enrich-index",
+			"Unknown -boundary-scanner %q, expected sentence or word":                "-boundary-scanner %q inconnu, sentence ou word attendu",
+			"Unknown -color %q, expected auto, always, or never":                     "-color %q inconnu, auto, always ou never attendu",
+			"Unknown -output %q, expected text, table, json, ndjson, or csv":         "-output %q inconnu, text, table, json, ndjson ou csv attendu",
+			"-plain can only be used with -output text":                              "-plain ne peut être utilisé qu'avec -output text",
+			"-size must be at least 1, got %s":                                       "-size doit valoir au moins 1, reçu %s",
+			"-facet-size must be at least 1, got %s":                                 "-facet-size doit valoir au moins 1, reçu %s",
+			"-min-score must be at least 0, got %s":                                  "-min-score doit valoir au moins 0, reçu %s",
+			"-min-rating must be between 0 and 5, got %s":                            "-min-rating doit être compris entre 0 et 5, reçu %s",
+			"-year-from %d is after -year-to %d":                                     "-year-from %d est postérieur à -year-to %d",
+			"-from cannot be combined with -cursor or -after":                        "-from ne peut pas être combiné avec -cursor ou -after",
+			"Searching books for: %s":                                                "Recherche de livres : %s",
+			"Unknown -intent %q, expected auto, topic, title, author, isbn, or asin": "-intent %q inconnu, auto, topic, title, author, isbn ou asin attendu",
+			"Looking up %s: %s":                                                      "Recherche par %s : %s",
+			"No book found with %s %s":                                               "Aucun livre trouvé avec %s %s",
+			"Error querying, status: %s, response body: %s":                          "Erreur lors de la recherche, statut : %s, réponse : %s",
+			"Showing %s of %s books, found in %s ms":                                 "%s livres affichés sur %s, trouvés en %s ms",
+			"Showing %s of more than %s books, found in %s ms":                       "%s livres affichés sur plus de %s, trouvés en %s ms",
+			"%s, %s with score of %s":                                                "%s, %s avec un score de %s",
+			"matched: %s":                                                            "correspond dans : %s",
+			"sorted by: %s":                                                          "trié par : %s",
+			"next page: -after %s":                                                   "page suivante : -after %s",
+		},
+	},
+}
@@ -0,0 +1,100 @@
+// Package i18n translates command output and formats numbers for the
+// user's locale. Messages are looked up by their English text, so a
+// missing translation falls back to English.
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Printer formats messages for one language.
+type Printer struct {
+	lang     string
+	messages map[string]string
+	decimal  string
+	group    string
+}
+
+// New returns a printer for locale, e.g. "de_DE.UTF-8" or "fr". Locales
+// without a catalog print English.
+func New(locale string) *Printer {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	p := &Printer{lang: "en", decimal: ".", group: ","}
+	if c, ok := catalogs[lang]; ok {
+		p.lang = lang
+		p.messages = c.messages
+		p.decimal = c.decimal
+		p.group = c.group
+	}
+	return p
+}
+
+// FromEnv returns a printer for the locale in SEARCH_GO_LANG, or else the
+// usual LC_ALL, LC_MESSAGES and LANG variables.
+func FromEnv() *Printer {
+	for _, env := range []string{"SEARCH_GO_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(env); locale != "" {
+			return New(locale)
+		}
+	}
+	return New("en")
+}
+
+// Lang returns the language messages are printed in.
+func (p *Printer) Lang() string {
+	return p.lang
+}
+
+// Sprintf translates format and formats args into it.
+func (p *Printer) Sprintf(format string, args ...interface{}) string {
+	if translated, ok := p.messages[format]; ok {
+		format = translated
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Int formats n with the locale's digit grouping, e.g. 12,345 or 12.345.
+func (p *Printer) Int(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteString(p.group)
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String()
+}
+
+// Number formats f with decimals digits after the locale's decimal
+// separator, or as many as needed when decimals is -1.
+func (p *Printer) Number(f float64, decimals int) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	formatted := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(formatted, ".")
+
+	wholeNumber, _ := strconv.ParseInt(whole, 10, 64)
+	result := p.Int(wholeNumber)
+	if fraction != "" {
+		result += p.decimal + fraction
+	}
+	if f < 0 && strings.Trim(formatted, "0.") != "" {
+		result = "-" + result
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := map[string]string{
+		"de_DE.UTF-8": "de",
+		"fr":          "fr",
+		"es-MX":       "es",
+		"FR_ca":       "fr",
+		"de@euro":     "de",
+		"en_US.UTF-8": "en",
+		"ja_JP":       "en",
+		"C":           "en",
+		"":            "en",
+	}
+	for locale, want := range tests {
+		if got := New(locale).Lang(); got != want {
+			t.Errorf("New(%q).Lang() = %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("SEARCH_GO_LANG", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "fr_FR.UTF-8")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	if got := FromEnv().Lang(); got != "fr" {
+		t.Errorf("FromEnv().Lang() = %q, want LC_MESSAGES's fr over LANG", got)
+	}
+	t.Setenv("SEARCH_GO_LANG", "es")
+	if got := FromEnv().Lang(); got != "es" {
+		t.Errorf("FromEnv().Lang() = %q, want SEARCH_GO_LANG's es", got)
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	if got := New("de").Sprintf("Searching books for: %s", "dune"); got != "Suche Bücher nach: dune" {
+		t.Errorf("German lookup = %q", got)
+	}
+	if got := New("en").Sprintf("Searching books for: %s", "dune"); got != "Searching books for: dune" {
+		t.Errorf("English lookup = %q", got)
+	}
+	// Messages without a translation fall back to English
+	if got := New("fr").Sprintf("No translation for %d", 3); got != "No translation for 3" {
+		t.Errorf("untranslated lookup = %q", got)
+	}
+}
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int64
+		want   string
+	}{
+		{locale: "en", n: 0, want: "0"},
+		{locale: "en", n: 999, want: "999"},
+		{locale: "en", n: 1234567, want: "1,234,567"},
+		{locale: "en", n: -1234, want: "-1,234"},
+		{locale: "de", n: 1234567, want: "1.234.567"},
+		{locale: "fr", n: 12345, want: "12\u202f345"},
+	}
+	for _, test := range tests {
+		if got := New(test.locale).Int(test.n); got != test.want {
+			t.Errorf("%s Int(%d) = %q, want %q", test.locale, test.n, got, test.want)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		locale   string
+		f        float64
+		decimals int
+		want     string
+	}{
+		{locale: "en", f: 4.256, decimals: 2, want: "4.26"},
+		{locale: "de", f: 4.256, decimals: 2, want: "4,26"},
+		{locale: "en", f: 12345.5, decimals: -1, want: "12,345.5"},
+		{locale: "fr", f: -1234.25, decimals: 1, want: "-1\u202f234,2"},
+		{locale: "en", f: -0.001, decimals: 2, want: "0.00"},
+		{locale: "en", f: 0.3, decimals: -1, want: "0.3"},
+	}
+	for _, test := range tests {
+		if got := New(test.locale).Number(test.f, test.decimals); got != test.want {
+			t.Errorf("%s Number(%g, %d) = %q, want %q", test.locale, test.f, test.decimals, got, test.want)
+		}
+	}
+}
+
+var verb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// TestCatalogs checks that every language translates the same messages,
+// with the same formatting verbs in the same order, so no translation
+// prints a %!s(MISSING).
+func TestCatalogs(t *testing.T) {
+	var english []string
+	for message := range catalogs["de"].messages {
+		english = append(english, message)
+	}
+	sort.Strings(english)
+
+	for lang, c := range catalogs {
+		if len(c.messages) != len(english) {
+			t.Errorf("%s translates %d messages, de translates %d", lang, len(c.messages), len(english))
+		}
+		for _, message := range english {
+			translated, ok := c.messages[message]
+			if !ok {
+				t.Errorf("%s has no translation of %q", lang, message)
+				continue
+			}
+			want := strings.Join(verb.FindAllString(message, -1), " ")
+			if got := strings.Join(verb.FindAllString(translated, -1), " "); got != want {
+				t.Errorf("%s translation of %q has verbs %q, want %q", lang, message, got, want)
+			}
+		}
+	}
+}
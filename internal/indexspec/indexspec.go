@@ -0,0 +1,259 @@
+// Package indexspec reads index spec files, which declare everything
+// needed to build an index: where its documents come from, how they are
+// transformed and identified, and the index's mappings, settings and
+// aliases.
+package indexspec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the contents of an index spec file.
+type Spec struct {
+	Index      string                 `yaml:"index"`
+	Source     Source                 `yaml:"source"`
+	Transforms []string               `yaml:"transforms"`
+	Settings   map[string]interface{} `yaml:"settings"`
+	Mappings   map[string]interface{} `yaml:"mappings"`
+	Aliases    []string               `yaml:"aliases"`
+	ID         IDStrategy             `yaml:"id"`
+}
+
+// Source is where the documents are read from.
+type Source struct {
+	File string `yaml:"file"`
+	// Format is the file format. Only ndjson, one document per line, is
+	// supported.
+	Format string `yaml:"format"`
+}
+
+// IDStrategy decides each document's _id.
+type IDStrategy struct {
+	// Strategy is auto to let Elasticsearch pick ids, field to use the
+	// value of Field, or hash to use a hash of the value of Field, or of
+	// the whole document when there is no Field. Only field and hash make
+	// reloading overwrite documents instead of duplicating them.
+	Strategy string `yaml:"strategy"`
+	Field    string `yaml:"field"`
+}
+
+// Load reads and validates the spec at path, filling in defaults.
+func Load(path string) (*Spec, error) {
+	specBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal(specBytes, &spec); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	if spec.Source.Format == "" {
+		spec.Source.Format = "ndjson"
+	}
+	if spec.ID.Strategy == "" {
+		spec.ID.Strategy = "auto"
+	}
+
+	if spec.Index == "" {
+		return nil, fmt.Errorf("%s: no index given", path)
+	}
+	if spec.Source.File == "" {
+		return nil, fmt.Errorf("%s: no source file given", path)
+	}
+	if spec.Source.Format != "ndjson" {
+		return nil, fmt.Errorf("%s: unknown source format %q, expected ndjson", path, spec.Source.Format)
+	}
+	if err := spec.ID.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// IndexBody returns the create index request body for the spec.
+func (s *Spec) IndexBody() ([]byte, error) {
+	body := map[string]interface{}{}
+	if len(s.Settings) > 0 {
+		body["settings"] = s.Settings
+	}
+	if len(s.Mappings) > 0 {
+		body["mappings"] = s.Mappings
+	}
+	return json.Marshal(body)
+}
+
+// Validate checks that the strategy is known and has the field it needs.
+func (s IDStrategy) Validate() error {
+	switch s.Strategy {
+	case "auto", "hash":
+	case "field":
+		if s.Field == "" {
+			return fmt.Errorf("the field id strategy needs an id field")
+		}
+	default:
+		return fmt.Errorf("unknown id strategy %q, expected auto, field or hash", s.Strategy)
+	}
+	return nil
+}
+
+// DocumentID returns the _id for a document, or "" to let Elasticsearch
+// pick one.
+func (s IDStrategy) DocumentID(document []byte) (string, error) {
+	switch s.Strategy {
+	case "field":
+		return s.fieldValue(document)
+	case "hash":
+		if s.Field != "" {
+			value, err := s.fieldValue(document)
+			if err != nil {
+				return "", err
+			}
+			document = []byte(value)
+		}
+		sum := sha256.Sum256(document)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return "", nil
+}
+
+func (s IDStrategy) fieldValue(document []byte) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(document, &fields); err != nil {
+		return "", err
+	}
+	value, ok := fields[s.Field]
+	if !ok || value == nil || value == "" {
+		return "", fmt.Errorf("document has no %s to use as its id", s.Field)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// FlatSettings returns the spec's settings flattened to the dotted,
+// index. prefixed keys Elasticsearch reports with flat_settings, and with
+// values formatted the same way.
+func (s *Spec) FlatSettings() map[string]string {
+	flat := map[string]string{}
+	flatten("", s.Settings, flat)
+	prefixed := map[string]string{}
+	for key, value := range flat {
+		if !strings.HasPrefix(key, "index.") {
+			key = "index." + key
+		}
+		prefixed[key] = value
+	}
+	return prefixed
+}
+
+// FlatSettingValues formats flat settings returned by Elasticsearch for
+// comparison with FlatSettings.
+func FlatSettingValues(settings map[string]interface{}) map[string]string {
+	flat := map[string]string{}
+	for key, value := range settings {
+		flat[key] = fmt.Sprint(value)
+	}
+	return flat
+}
+
+// Fields returns each field in a mappings object by its dotted path, with
+// its definition, minus any subfields, encoded as JSON.
+func Fields(mappings map[string]interface{}) map[string]string {
+	fields := map[string]string{}
+	addFields("", mappings, fields)
+	return fields
+}
+
+func addFields(prefix string, mapping map[string]interface{}, fields map[string]string) {
+	properties, _ := mapping["properties"].(map[string]interface{})
+	for name, value := range properties {
+		definition, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := prefix + name
+
+		own := map[string]interface{}{}
+		for key, value := range definition {
+			if key != "properties" {
+				own[key] = value
+			}
+		}
+		if len(own) > 0 {
+			encoded, _ := json.Marshal(own)
+			fields[path] = string(encoded)
+		}
+		addFields(path+".", definition, fields)
+	}
+}
+
+func flatten(prefix string, value interface{}, flat map[string]string) {
+	if nested, ok := value.(map[string]interface{}); ok {
+		for key, value := range nested {
+			flatten(prefix+key+".", value, flat)
+		}
+		return
+	}
+	if prefix != "" {
+		flat[strings.TrimSuffix(prefix, ".")] = fmt.Sprint(value)
+	}
+}
+
+// CatalogPath returns the dataset catalog file, SEARCH_GO_CATALOG or
+// datasets.yaml in the working directory.
+func CatalogPath() string {
+	if path := os.Getenv("SEARCH_GO_CATALOG"); path != "" {
+		return path
+	}
+	return "datasets.yaml"
+}
+
+type catalog struct {
+	Datasets map[string]string `yaml:"datasets"`
+}
+
+// LoadDataset loads the spec the catalog lists for the named dataset.
+// Spec paths in the catalog are relative to the catalog file.
+func LoadDataset(name string) (*Spec, error) {
+	path := CatalogPath()
+	catalogBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c catalog
+	if err := yaml.Unmarshal(catalogBytes, &c); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	specPath, ok := c.Datasets[name]
+	if !ok {
+		var names []string
+		for name := range c.Datasets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no dataset %q in %s, expected one of %s", name, path, strings.Join(names, ", "))
+	}
+	if !filepath.IsAbs(specPath) {
+		specPath = filepath.Join(filepath.Dir(path), specPath)
+	}
+	return Load(specPath)
+}
+
+// SearchIndex returns the name to read the dataset through: its first
+// alias, so reads follow the alias to new versions of the index, or the
+// index itself.
+func (s *Spec) SearchIndex() string {
+	if len(s.Aliases) > 0 {
+		return s.Aliases[0]
+	}
+	return s.Index
+}
@@ -0,0 +1,209 @@
+// Package plugin runs user supplied executables that extend the tools
+// without changing them, such as ingest transforms and search rerankers.
+//
+// Plugins are discovered by name in a plugins directory the user opts into
+// with -plugins-dir: transform-* for load-books, and rerank-* and
+// classify-* for search-books. Each plugin is started once and
+// exchanges newline delimited JSON over stdio: for every request line
+// written to its stdin, it must write exactly one response line to stdout.
+// Anything written to stderr is passed through to the user.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Discover returns the executables in dir whose names start with kind
+// followed by a dash, in name order, skipping .wasm modules. An empty or
+// missing dir has no plugins.
+func Discover(dir, kind string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), kind+"-") || strings.HasSuffix(entry.Name(), ".wasm") {
+			continue
+		}
+		executable, err := isExecutable(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !executable {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// isExecutable reports whether entry can be run as a plugin. Windows has
+// no executable bit, so there it goes by the file extension instead.
+func isExecutable(entry os.DirEntry) (bool, error) {
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".exe", ".bat", ".cmd", ".com":
+			return true, nil
+		}
+		return false, nil
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return false, err
+	}
+	return info.Mode()&0o111 != 0, nil
+}
+
+// CheckPermissions returns an error if users other than the owner can
+// change the plugin at path, since whoever can write a plugin can run code
+// as the user running the tools. Windows has no such mode bits.
+func CheckPermissions(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("refusing to run plugin %s, which is group or world writable, fix it with chmod go-w", path)
+	}
+	return nil
+}
+
+// ErrExited is wrapped by the errors of calls to a plugin that has exited
+// or closed its stdin or stdout, which every later call would fail on too.
+var ErrExited = errors.New("plugin exited")
+
+// Process is a running plugin.
+type Process struct {
+	Name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// Start runs the plugin at path.
+func Start(path string) (*Process, error) {
+	if err := CheckPermissions(path); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting plugin %s: %w", path, err)
+	}
+
+	return &Process{
+		Name:   filepath.Base(path),
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+// StartAll discovers and starts every plugin of kind in dir.
+func StartAll(dir, kind string) ([]*Process, error) {
+	paths, err := Discover(dir, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []*Process
+	for _, path := range paths {
+		process, err := Start(path)
+		if err != nil {
+			CloseAll(processes)
+			return nil, err
+		}
+		processes = append(processes, process)
+	}
+	return processes, nil
+}
+
+// CallRaw sends one request line and returns the plugin's response line.
+func (p *Process) CallRaw(request []byte) ([]byte, error) {
+	if _, err := p.stdin.Write(append(request, '\n')); err != nil {
+		return nil, fmt.Errorf("error writing to plugin %s, %w: %w", p.Name, ErrExited, err)
+	}
+	response, err := p.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error reading from plugin %s, %w: %w", p.Name, ErrExited, err)
+	}
+	return response, nil
+}
+
+// Call encodes request as JSON, sends it, and decodes the response into
+// response.
+func (p *Process) Call(request, response interface{}) error {
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	responseBytes, err := p.CallRaw(requestBytes)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(responseBytes, response); err != nil {
+		return fmt.Errorf("invalid response from plugin %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// Transform sends a document to a transform plugin and returns the
+// document to index instead, or nil if the plugin responded with null to
+// drop it.
+func (p *Process) Transform(document []byte) ([]byte, error) {
+	transformed, err := p.CallRaw(document)
+	if err != nil {
+		return nil, err
+	}
+	transformed = bytes.TrimSpace(transformed)
+	if string(transformed) == "null" {
+		return nil, nil
+	}
+	return transformed, nil
+}
+
+// Close ends the plugin's input and waits for it to exit.
+func (p *Process) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// CloseAll closes every process, ignoring errors.
+func CloseAll(processes []*Process) {
+	for _, process := range processes {
+		process.Close()
+	}
+}
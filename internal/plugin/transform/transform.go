@@ -0,0 +1,128 @@
+// Package transform chains the executable plugins and WASM modules that
+// rewrite documents before they are indexed, so load-books and apply run
+// them the same way.
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/plugin"
+	"github.com/nickcanz/search-go/internal/plugin/wasm"
+)
+
+// Transform rewrites a document's JSON before it is indexed, returning nil
+// to drop the document.
+type Transform interface {
+	Transform(document []byte) ([]byte, error)
+}
+
+// Chain is the transforms every document passes through, in order. The
+// zero Chain passes documents through unchanged.
+type Chain struct {
+	names      []string
+	transforms []Transform
+	processes  []*plugin.Process
+	modules    []*wasm.Transform
+}
+
+// Discover starts the executable transform-* plugins and then loads the
+// transform-*.wasm modules in dir. Executables run first, each group in
+// name order. An empty dir has no transforms.
+func Discover(dir string, limits wasm.Limits) (*Chain, error) {
+	processes, err := plugin.StartAll(dir, "transform")
+	if err != nil {
+		return nil, err
+	}
+	modules, err := wasm.LoadAll(dir, limits)
+	if err != nil {
+		plugin.CloseAll(processes)
+		return nil, err
+	}
+
+	c := &Chain{processes: processes, modules: modules}
+	for _, process := range processes {
+		c.names = append(c.names, process.Name)
+		c.transforms = append(c.transforms, process)
+	}
+	for _, module := range modules {
+		c.names = append(c.names, module.Name)
+		c.transforms = append(c.transforms, module)
+	}
+	return c, nil
+}
+
+// Named starts the transforms listed by name, in the order listed. Names
+// ending in .wasm are WASM modules and anything else is an executable
+// plugin, both found in dir.
+func Named(dir string, names []string, limits wasm.Limits) (*Chain, error) {
+	if len(names) > 0 && dir == "" {
+		return nil, fmt.Errorf("transforms %s need -plugins-dir to be set", strings.Join(names, ", "))
+	}
+
+	c := &Chain{}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, ".wasm") {
+			module, err := wasm.Load(path, limits)
+			if err != nil {
+				c.Close()
+				return nil, err
+			}
+			c.modules = append(c.modules, module)
+			c.transforms = append(c.transforms, module)
+		} else {
+			process, err := plugin.Start(path)
+			if err != nil {
+				c.Close()
+				return nil, err
+			}
+			c.processes = append(c.processes, process)
+			c.transforms = append(c.transforms, process)
+		}
+		c.names = append(c.names, name)
+	}
+	return c, nil
+}
+
+// Names returns the names of the transforms, in the order they run.
+func (c *Chain) Names() []string {
+	return c.names
+}
+
+// Apply passes a document through each transform in turn, returning nil
+// if any of them drops it.
+func (c *Chain) Apply(document []byte) ([]byte, error) {
+	for i, t := range c.transforms {
+		transformed, err := t.Transform(document)
+		if err != nil {
+			return nil, err
+		}
+		if transformed == nil {
+			return nil, nil
+		}
+		if !json.Valid(transformed) {
+			return nil, fmt.Errorf("transform %s returned invalid JSON: %s", c.names[i], transformed)
+		}
+		document = transformed
+	}
+	return document, nil
+}
+
+// Fatal reports whether err, from Apply, means the chain can't transform
+// any more documents, because a plugin exited or a module couldn't be
+// restarted. Other errors, such as a module trapping or running out of
+// time, or a plugin returning invalid JSON, are about one document.
+func Fatal(err error) bool {
+	return errors.Is(err, plugin.ErrExited) || errors.Is(err, wasm.ErrUnusable)
+}
+
+// Close stops every plugin and module. It is safe to call more than once.
+func (c *Chain) Close() {
+	plugin.CloseAll(c.processes)
+	wasm.CloseAll(c.modules)
+	c.processes, c.modules = nil, nil
+}
@@ -0,0 +1,231 @@
+// Package wasm runs per-document transforms compiled to WebAssembly. They
+// run in a sandbox with bounded memory and time per document, which makes
+// them a safer alternative to executable plugins.
+//
+// A transform module is found in the plugins directory as
+// transform-*.wasm. It must export its memory as "memory" and two
+// functions:
+//
+//	alloc(size i32) i32
+//	transform(ptr i32, len i32) i64
+//
+// The host calls alloc to get a buffer for the input document, writes the
+// document's JSON there, and calls transform with its location. transform
+// returns the location of the output JSON packed as ptr<<32 | len, or 0 to
+// drop the document. WASI is available, so modules built with TinyGo or
+// Rust's wasm32-wasi target work; a reactor's _initialize is run on load.
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/plugin"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Limits bound the resources a module may use.
+type Limits struct {
+	// MemoryMB caps the module's linear memory.
+	MemoryMB int
+	// Timeout caps the time spent transforming a single document.
+	Timeout time.Duration
+}
+
+// Discover returns the transform-*.wasm files in dir, in name order. An
+// empty or missing dir has no modules.
+func Discover(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, "transform-") && strings.HasSuffix(name, ".wasm") {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Transform is a loaded transform module.
+type Transform struct {
+	Name      string
+	runtime   wazero.Runtime
+	compiled  wazero.CompiledModule
+	module    api.Module
+	alloc     api.Function
+	transform api.Function
+	timeout   time.Duration
+}
+
+// Load compiles and instantiates the module at path.
+func Load(path string, limits Limits) (*Transform, error) {
+	if err := plugin.CheckPermissions(path); err != nil {
+		return nil, err
+	}
+	wasmBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	// Wasm pages are 64KiB
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(limits.MemoryMB * 16)).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	compiled, err := runtime.CompileModule(ctx, wasmBytes)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("error loading %s: %w", name, err)
+	}
+
+	t := &Transform{
+		Name:     name,
+		runtime:  runtime,
+		compiled: compiled,
+		timeout:  limits.Timeout,
+	}
+	if err := t.instantiate(ctx); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	return t, nil
+}
+
+// instantiate starts a fresh instance of the compiled module, replacing
+// the current one.
+func (t *Transform) instantiate(ctx context.Context) error {
+	if t.module != nil {
+		t.module.Close(ctx)
+		t.module = nil
+	}
+	module, err := t.runtime.InstantiateModule(ctx, t.compiled, wazero.NewModuleConfig().
+		WithName(t.Name).
+		WithStderr(os.Stderr).
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return fmt.Errorf("error loading %s: %w", t.Name, err)
+	}
+	t.module = module
+	t.alloc = module.ExportedFunction("alloc")
+	t.transform = module.ExportedFunction("transform")
+	if t.alloc == nil || t.transform == nil || module.Memory() == nil {
+		return fmt.Errorf("%s must export memory, alloc, and transform", t.Name)
+	}
+	return nil
+}
+
+// LoadAll discovers and loads every transform module in dir.
+func LoadAll(dir string, limits Limits) ([]*Transform, error) {
+	paths, err := Discover(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var transforms []*Transform
+	for _, path := range paths {
+		t, err := Load(path, limits)
+		if err != nil {
+			CloseAll(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	return transforms, nil
+}
+
+// ErrUnusable is wrapped by the errors of a module that failed and could
+// not be restarted, which every later document would fail on too.
+var ErrUnusable = errors.New("module could not be restarted")
+
+// Transform runs the module on one document. It returns nil when the
+// module drops the document.
+func (t *Transform) Transform(document []byte) ([]byte, error) {
+	if t.module == nil {
+		return nil, fmt.Errorf("%s: %w after an earlier failure", t.Name, ErrUnusable)
+	}
+	ctx := context.Background()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	results, err := t.alloc.Call(ctx, uint64(len(document)))
+	if err != nil {
+		return nil, t.callError(ctx, "alloc", err)
+	}
+	inputPtr := uint32(results[0])
+	if !t.module.Memory().Write(inputPtr, document) {
+		return nil, fmt.Errorf("%s: alloc returned a buffer outside of memory", t.Name)
+	}
+
+	results, err = t.transform.Call(ctx, uint64(inputPtr), uint64(len(document)))
+	if err != nil {
+		return nil, t.callError(ctx, "transform", err)
+	}
+	if results[0] == 0 {
+		return nil, nil
+	}
+
+	outputPtr, outputLen := uint32(results[0]>>32), uint32(results[0])
+	output, ok := t.module.Memory().Read(outputPtr, outputLen)
+	if !ok {
+		return nil, fmt.Errorf("%s: transform returned output outside of memory", t.Name)
+	}
+	// The module may reuse its memory, so copy the output out
+	return append([]byte(nil), output...), nil
+}
+
+// callError describes a failed call and replaces the instance, which a
+// timeout closes and a trap can leave in any state, so the next document
+// starts from a fresh one.
+func (t *Transform) callError(ctx context.Context, function string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%s: %s took longer than %s", t.Name, function, t.timeout)
+	} else {
+		err = fmt.Errorf("%s: %s failed: %w", t.Name, function, err)
+	}
+	if restartErr := t.instantiate(context.Background()); restartErr != nil {
+		return fmt.Errorf("%w, and the %w: %v", err, ErrUnusable, restartErr)
+	}
+	return err
+}
+
+// Close frees the module and its runtime.
+func (t *Transform) Close() error {
+	return t.runtime.Close(context.Background())
+}
+
+// CloseAll closes every transform, ignoring errors.
+func CloseAll(transforms []*Transform) {
+	for _, t := range transforms {
+		t.Close()
+	}
+}
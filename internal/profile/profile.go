@@ -0,0 +1,141 @@
+// Package profile selects named connection profiles. A profile combines
+// optional settings from the profiles.json config file with credentials
+// saved by the login command, and tags like "production" that make
+// destructive commands ask for confirmation.
+package profile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcanz/search-go/internal/credentials"
+	"golang.org/x/term"
+)
+
+// Profile holds the non-secret settings for a named environment.
+type Profile struct {
+	Name string   `json:"-"`
+	URL  string   `json:"url"`
+	User string   `json:"user"`
+	Tags []string `json:"tags"`
+}
+
+type config struct {
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// ConfigPath returns the location of profiles.json.
+func ConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "search-go", "profiles.json"), nil
+}
+
+// Get returns the settings for name from profiles.json, and whether the
+// profile is defined there.
+func Get(name string) (*Profile, bool, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, false, err
+	}
+
+	configBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Profile{Name: name}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var cfg config
+	if err := json.Unmarshal(configBytes, &cfg); err != nil {
+		return nil, false, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return &Profile{Name: name}, false, nil
+	}
+	p.Name = name
+	return p, true, nil
+}
+
+// Activate exports the connection details of profile name as ES_URL,
+// ES_USER, and ES_PASSWORD, taking saved credentials first and then any
+// settings from profiles.json on top. A profile that points at its own URL
+// without saved credentials connects without a user or password, so the
+// ones from .env are never sent to another cluster.
+func Activate(name string) (*Profile, error) {
+	p, configured, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := credentials.Exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if !configured && !saved {
+		return nil, fmt.Errorf("unknown profile %q, add it to profiles.json or run login -profile %s", name, name)
+	}
+
+	if saved {
+		if err := credentials.Activate(name); err != nil {
+			return nil, err
+		}
+	} else if p.URL != "" {
+		os.Unsetenv("ES_USER")
+		os.Unsetenv("ES_PASSWORD")
+	}
+	if p.URL != "" {
+		os.Setenv("ES_URL", p.URL)
+	}
+	if p.User != "" {
+		os.Setenv("ES_USER", p.User)
+	}
+	return p, nil
+}
+
+// IsProduction reports whether the profile is tagged production.
+func (p *Profile) IsProduction() bool {
+	if p == nil {
+		return false
+	}
+	for _, tag := range p.Tags {
+		if tag == "production" || tag == "prod" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfirmDestructive returns an error unless it is safe to run action
+// against p. Profiles that aren't tagged production are always safe. For
+// production profiles, yes (the -yes-i-mean-prod flag) confirms up front.
+// Otherwise the user has to type the profile name at a terminal.
+func ConfirmDestructive(p *Profile, action string, yes bool) error {
+	if !p.IsProduction() || yes {
+		return nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("refusing to %s on production profile %s without -yes-i-mean-prod", action, p.Name)
+	}
+
+	fmt.Fprintf(os.Stderr, "About to %s on production profile %s. Type the profile name to continue: ", action, p.Name)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(answer) != p.Name {
+		return fmt.Errorf("confirmation did not match, not running %s", action)
+	}
+	return nil
+}
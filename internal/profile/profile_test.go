@@ -0,0 +1,69 @@
+package profile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+func TestActivateURLOnlyProfile(t *testing.T) {
+	var authorization string
+	requests := 0
+	cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"count":0}`))
+	}))
+	defer cluster.Close()
+
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+	if err := os.MkdirAll(filepath.Join(configDir, "search-go"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"profiles": {"local": {"url": "` + cluster.URL + `"}}}`
+	if err := os.WriteFile(filepath.Join(configDir, "search-go", "profiles.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Credentials from .env are for another cluster
+	t.Setenv("ES_URL", "https://elsewhere.example.com")
+	t.Setenv("ES_USER", "elsewhere")
+	t.Setenv("ES_PASSWORD", "secret")
+
+	if _, err := Activate("local"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := esclient.NewClientFromEnv("test")
+	if err != nil {
+		t.Fatalf("connecting with a URL-only profile: %v", err)
+	}
+	resp, err := client.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if requests != 1 {
+		t.Fatalf("expected 1 request to the profile's URL, got %d", requests)
+	}
+	if authorization != "" {
+		t.Errorf("expected no credentials to be sent, got Authorization: %s", authorization)
+	}
+}
+
+func TestActivateUnknownProfile(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+
+	if _, err := Activate("missing"); err == nil {
+		t.Error("expected an error for a profile that isn't configured or saved")
+	}
+}
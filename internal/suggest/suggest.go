@@ -0,0 +1,76 @@
+// Package suggest builds the title_suggest completion field that
+// suggest-books completes titles from, so load-books and apply build it
+// the same way.
+package suggest
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Field is the completion field suggestions are stored in.
+const Field = "title_suggest"
+
+// Suggestion is the value of the title_suggest completion field: the
+// texts a typed prefix is matched against, and how highly the book ranks
+// among the completions.
+type Suggestion struct {
+	Input  []string `json:"input"`
+	Weight int      `json:"weight"`
+}
+
+// seriesSuffix matches the series goodreads appends to titles, e.g. the
+// " (Harry Potter, #1)" of "Harry Potter and the Sorcerer's Stone (Harry
+// Potter, #1)".
+var seriesSuffix = regexp.MustCompile(`\s*\([^()]*#[^()]*\)\s*$`)
+
+// Title returns the completion input for a book, or nil when it has no
+// title. Completions only match from the start of an input, so the title
+// is also given without its series and without a leading article, letting
+// "lord of the r" complete "The Lord of the Rings". Books with more
+// ratings are suggested first.
+func Title(title string, ratingsCount int) *Suggestion {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil
+	}
+
+	var inputs []string
+	seen := map[string]bool{}
+	add := func(input string) {
+		input = strings.TrimSpace(input)
+		if input != "" && !seen[strings.ToLower(input)] {
+			seen[strings.ToLower(input)] = true
+			inputs = append(inputs, input)
+		}
+	}
+	for _, variant := range []string{title, seriesSuffix.ReplaceAllString(title, "")} {
+		add(variant)
+		add(withoutArticle(variant))
+	}
+
+	weight := 0
+	if ratingsCount > 0 {
+		weight = ratingsCount
+		// Elasticsearch stores weights as 32 bit integers
+		if weight > math.MaxInt32 {
+			weight = math.MaxInt32
+		}
+	}
+	return &Suggestion{Input: inputs, Weight: weight}
+}
+
+// withoutArticle removes a leading "the", "a" or "an" from title, or
+// returns "" when it doesn't start with one.
+func withoutArticle(title string) string {
+	first, rest, ok := strings.Cut(title, " ")
+	if !ok {
+		return ""
+	}
+	switch strings.ToLower(first) {
+	case "the", "a", "an":
+		return rest
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+// Package targets resolves the index expressions destructive commands are
+// given, which may be aliases or wildcard patterns, into the concrete
+// indices they would change, so those can be shown and confirmed before
+// anything is deleted.
+package targets
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"golang.org/x/term"
+)
+
+// Index is a concrete index an expression resolved to.
+type Index struct {
+	Name    string
+	Status  string
+	Docs    int64
+	Created time.Time
+	Aliases []string
+}
+
+// Resolve returns the open and closed indices expression names, matches or
+// is an alias of, sorted by name. It returns no indices when nothing
+// matches.
+func Resolve(client *elasticsearch7.Client, expression string) ([]Index, error) {
+	resp, err := client.Cat.Indices(
+		client.Cat.Indices.WithIndex(expression),
+		client.Cat.Indices.WithExpandWildcards("open,closed"),
+		client.Cat.Indices.WithH("index", "status", "docs.count", "creation.date"),
+		client.Cat.Indices.WithFormat("json"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("error resolving %s, status: %s, response body: %s", expression, resp.Status(), resp.String())
+	}
+
+	var rows []struct {
+		Index        string `json:"index"`
+		Status       string `json:"status"`
+		DocsCount    string `json:"docs.count"`
+		CreationDate string `json:"creation.date"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, err
+	}
+	indices := make([]Index, len(rows))
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		// Closed indices have no doc count
+		docs, _ := strconv.ParseInt(row.DocsCount, 10, 64)
+		indices[i] = Index{Name: row.Index, Status: row.Status, Docs: docs}
+		if millis, err := strconv.ParseInt(row.CreationDate, 10, 64); err == nil {
+			indices[i].Created = time.UnixMilli(millis)
+		}
+		names[i] = row.Index
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Name < indices[j].Name })
+
+	aliases, err := Aliases(client, names)
+	if err != nil {
+		return nil, err
+	}
+	for i := range indices {
+		indices[i].Aliases = aliases[indices[i].Name]
+	}
+	return indices, nil
+}
+
+// Aliases returns the aliases pointing to each of indices, sorted by name.
+// Indices without aliases are left out.
+func Aliases(client *elasticsearch7.Client, indices []string) (map[string][]string, error) {
+	aliases := map[string][]string{}
+	if len(indices) == 0 {
+		return aliases, nil
+	}
+	resp, err := client.Indices.GetAlias(
+		client.Indices.GetAlias.WithIndex(indices...),
+		client.Indices.GetAlias.WithExpandWildcards("open,closed"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("error getting aliases, status: %s, response body: %s", resp.Status(), resp.String())
+	}
+
+	var response map[string]struct {
+		Aliases map[string]json.RawMessage `json:"aliases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	for index, entry := range response {
+		for alias := range entry.Aliases {
+			aliases[index] = append(aliases[index], alias)
+		}
+		sort.Strings(aliases[index])
+	}
+	return aliases, nil
+}
+
+// MatchesEverything reports whether expression names every index, which no
+// destructive command should be given.
+func MatchesEverything(expression string) bool {
+	for _, part := range strings.Split(expression, ",") {
+		if part = strings.TrimSpace(part); part == "_all" || strings.Trim(part, "*") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Expanded reports whether expression stands for something other than the
+// one index of the same name: an alias, a pattern, or a list.
+func Expanded(expression string, indices []Index) bool {
+	return len(indices) != 1 || indices[0].Name != expression
+}
+
+// Print writes a line per index with its doc count and aliases.
+func Print(w io.Writer, indices []Index) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, index := range indices {
+		line := fmt.Sprintf("  %s\t%d docs", index.Name, index.Docs)
+		if index.Status == "close" {
+			line = fmt.Sprintf("  %s\tclosed", index.Name)
+		}
+		if len(index.Aliases) > 0 {
+			line += "\taliases: " + strings.Join(index.Aliases, ", ")
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
+
+// Confirm lists the indices expression resolved to and, when it is an
+// alias, pattern or list, has the user confirm them by typing how many
+// there are. yes (the -yes flag) confirms up front, for scripts. The
+// production confirmation of profile.ConfirmDestructive is separate.
+func Confirm(action, expression string, indices []Index, yes bool) error {
+	if len(indices) == 0 {
+		return fmt.Errorf("%s matches no indices", expression)
+	}
+	fmt.Fprintf(os.Stderr, "%s resolves to:\n", expression)
+	if err := Print(os.Stderr, indices); err != nil {
+		return err
+	}
+	if !Expanded(expression, indices) || yes {
+		return nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("refusing to %s the %d indices %s resolves to without -yes", action, len(indices), expression)
+	}
+	fmt.Fprintf(os.Stderr, "Type %d to %s these indices: ", len(indices), action)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(answer) != strconv.Itoa(len(indices)) {
+		return fmt.Errorf("confirmation did not match, not running %s", action)
+	}
+	return nil
+}
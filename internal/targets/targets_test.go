@@ -0,0 +1,69 @@
+package targets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchesEverything(t *testing.T) {
+	tests := map[string]bool{
+		"_all":           true,
+		"*":              true,
+		"**":             true,
+		"":               true,
+		"books-*,*":      true,
+		"books, _all":    true,
+		"books":          false,
+		"books-*":        false,
+		"*-old":          false,
+		"books,authors":  false,
+		"_all-the-books": false,
+	}
+	for expression, want := range tests {
+		if got := MatchesEverything(expression); got != want {
+			t.Errorf("MatchesEverything(%q) = %t, want %t", expression, got, want)
+		}
+	}
+}
+
+func TestExpanded(t *testing.T) {
+	one := []Index{{Name: "books-v1"}}
+	two := []Index{{Name: "books-v1"}, {Name: "books-v2"}}
+	tests := []struct {
+		expression string
+		indices    []Index
+		want       bool
+	}{
+		{expression: "books-v1", indices: one, want: false},
+		{expression: "books", indices: one, want: true},
+		{expression: "books-*", indices: two, want: true},
+		{expression: "books-v1,books-v2", indices: two, want: true},
+		{expression: "books-v1", indices: nil, want: true},
+	}
+	for _, test := range tests {
+		if got := Expanded(test.expression, test.indices); got != test.want {
+			t.Errorf("Expanded(%q, %d indices) = %t, want %t", test.expression, len(test.indices), got, test.want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var out strings.Builder
+	err := Print(&out, []Index{
+		{Name: "books-v1", Docs: 1000, Aliases: []string{"books", "books-read"}},
+		{Name: "books-v0", Status: "close"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "  books-v1  1000 docs  aliases: books, books-read\n  books-v0  closed\n"
+	if out.String() != want {
+		t.Errorf("Print wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestConfirmWithoutIndices(t *testing.T) {
+	if err := Confirm("delete", "books-*", nil, true); err == nil {
+		t.Error("Confirm succeeded with no indices, want an error")
+	}
+}
@@ -0,0 +1,107 @@
+// Package telemetry reports which commands and flags are used, and
+// roughly how much data they handle, so maintainers know which features
+// matter. It is off unless SEARCH_GO_TELEMETRY is set to on and
+// SEARCH_GO_TELEMETRY_URL names an endpoint, and DO_NOT_TRACK turns it
+// off again. Only the fields of Event are sent: never flag values,
+// queries, index names, documents, hosts or users.
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/version"
+)
+
+// Event is one run of a command.
+type Event struct {
+	Command string `json:"command"`
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	// Flags are the names of the flags that were set, without their values
+	Flags []string `json:"flags,omitempty"`
+	// Docs is the number of documents handled, rounded down to a power of
+	// ten so it gives the scale without the exact size
+	Docs            int64 `json:"docs,omitempty"`
+	DurationSeconds int64 `json:"duration_seconds"`
+}
+
+// mode returns on to send events, print to only write them to stderr, or
+// "" when telemetry is off.
+func mode() string {
+	if os.Getenv("DO_NOT_TRACK") != "" && os.Getenv("DO_NOT_TRACK") != "0" {
+		return ""
+	}
+	switch os.Getenv("SEARCH_GO_TELEMETRY") {
+	case "on":
+		if os.Getenv("SEARCH_GO_TELEMETRY_URL") == "" {
+			return ""
+		}
+		return "on"
+	case "print":
+		return "print"
+	}
+	return ""
+}
+
+// Report sends an event for a run of command that set flags and handled
+// docs documents over duration. It does nothing when telemetry is off,
+// and failures are ignored so telemetry can never break a command.
+func Report(command string, flags *flag.FlagSet, docs int64, duration time.Duration) {
+	m := mode()
+	if m == "" {
+		return
+	}
+
+	event := Event{
+		Command:         command,
+		Version:         version.Version,
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+		Docs:            scale(docs),
+		DurationSeconds: int64(duration.Seconds()),
+	}
+	if flags != nil {
+		flags.Visit(func(f *flag.Flag) {
+			event.Flags = append(event.Flags, f.Name)
+		})
+		sort.Strings(event.Flags)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if m == "print" {
+		fmt.Fprintf(os.Stderr, "telemetry: %s\n", body)
+		return
+	}
+
+	httpClient := &http.Client{Timeout: 2 * time.Second}
+	resp, err := httpClient.Post(os.Getenv("SEARCH_GO_TELEMETRY_URL"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// scale rounds n down to a power of ten, e.g. 1234 to 1000.
+func scale(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	power := int64(1)
+	for n >= 10 {
+		n /= 10
+		power *= 10
+	}
+	return power
+}
@@ -0,0 +1,39 @@
+// Package tombstone soft deletes documents: instead of being removed, a
+// document is marked deleted with the time, searches leave it out, and it
+// is only removed for good when purged after a grace period. Until then a
+// deletion can be undone by clearing the mark.
+package tombstone
+
+import (
+	"time"
+
+	"github.com/nickcanz/search-go/pkg/query"
+)
+
+const (
+	// Field is true on deleted documents.
+	Field = "deleted"
+	// AtField is when the document was deleted.
+	AtField = "deleted_at"
+)
+
+// Live restricts q to documents that haven't been deleted, keeping its
+// scores.
+func Live(q query.Query) query.Query {
+	return query.Bool().Must(q).MustNot(query.Term(Field, true))
+}
+
+// Mark returns the partial document that marks a document deleted at now.
+func Mark(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		Field:   true,
+		AtField: now.UTC().Format(time.RFC3339),
+	}
+}
+
+// Expired matches documents deleted before cutoff.
+func Expired(cutoff time.Time) query.Query {
+	return query.Bool().Filter(
+		query.Term(Field, true),
+		query.Range(AtField).Lt(cutoff.UTC().Format(time.RFC3339)))
+}
@@ -0,0 +1,53 @@
+// Package upsert builds the bulk update bodies that write a document under
+// its id while keeping the timestamps and soft deletion of the copy
+// already indexed, so load-books, retry-failed and apply write a book the
+// same way.
+package upsert
+
+import (
+	"encoding/json"
+
+	"github.com/nickcanz/search-go/internal/tombstone"
+)
+
+// Kept are the fields of the indexed copy that a new copy never replaces:
+// when the book was first loaded, and whether it was soft deleted, so a
+// reload doesn't bring deleted books back. They are only set on the new
+// copy when the indexed one has them.
+var Kept = []string{"created_at", tombstone.Field, tombstone.AtField}
+
+// script replaces a book that is already indexed with the new copy,
+// keeping the fields in params.keep, and its updated_at unless the content
+// hash shows the content changed.
+const script = `
+Map kept = new HashMap();
+for (String field : params.keep) {
+  if (ctx._source.get(field) != null) {
+    kept.put(field, ctx._source.get(field));
+  }
+}
+def updatedAt = ctx._source.updated_at;
+boolean changed = ctx._source._content_hash != params.doc._content_hash;
+ctx._source.clear();
+ctx._source.putAll(params.doc);
+ctx._source.putAll(kept);
+if (!changed && updatedAt != null) {
+  ctx._source.updated_at = updatedAt;
+}
+`
+
+// Body returns the bulk update body that indexes document as is if its id
+// is new, or replaces the indexed copy with it if not.
+func Body(document []byte) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": script,
+			"lang":   "painless",
+			"params": map[string]interface{}{
+				"doc":  json.RawMessage(document),
+				"keep": Kept,
+			},
+		},
+		"upsert": json.RawMessage(document),
+	})
+}
@@ -0,0 +1,125 @@
+package upsert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickcanz/search-go/internal/tombstone"
+	"github.com/nickcanz/search-go/pkg/esclient"
+)
+
+func TestBodyKeepsTombstone(t *testing.T) {
+	document := `{"title":"The Hobbit","_content_hash":"abc"}`
+	body, err := Body([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Script struct {
+			Source string `json:"source"`
+			Params struct {
+				Doc  json.RawMessage `json:"doc"`
+				Keep []string        `json:"keep"`
+			} `json:"params"`
+		} `json:"script"`
+		Upsert json.RawMessage `json:"upsert"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded.Script.Params.Doc) != document || string(decoded.Upsert) != document {
+		t.Errorf("body doesn't carry the document as is: %s", body)
+	}
+	for _, field := range []string{"created_at", tombstone.Field, tombstone.AtField} {
+		found := false
+		for _, kept := range decoded.Script.Params.Keep {
+			found = found || kept == field
+		}
+		if !found {
+			t.Errorf("%s isn't kept from the indexed copy, keep is %v", field, decoded.Script.Params.Keep)
+		}
+	}
+	if !strings.Contains(decoded.Script.Source, "params.keep") {
+		t.Error("the script doesn't read params.keep")
+	}
+}
+
+// TestReloadTombstonedBook runs the script on a cluster, since only
+// Elasticsearch runs painless. It is skipped unless SEARCH_GO_TEST_ES_URL
+// names a cluster it can create and delete a scratch index on, with
+// ES_USER and ES_PASSWORD when the cluster needs them.
+func TestReloadTombstonedBook(t *testing.T) {
+	url := os.Getenv("SEARCH_GO_TEST_ES_URL")
+	if url == "" {
+		t.Skip("SEARCH_GO_TEST_ES_URL is not set")
+	}
+	client, err := esclient.NewClient(esclient.Config{
+		URL:      url,
+		User:     os.Getenv("ES_USER"),
+		Password: os.Getenv("ES_PASSWORD"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := fmt.Sprintf("search-go-test-upsert-%d", time.Now().UnixNano())
+	defer client.Indices.Delete([]string{index})
+
+	update := func(document string) {
+		t.Helper()
+		body, err := Body([]byte(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Update(index, "1", bytes.NewReader(body), client.Update.WithRefresh("true"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.IsError() {
+			t.Fatalf("error updating, status: %s, response body: %s", resp.Status(), resp.String())
+		}
+	}
+
+	update(`{"title":"The Hobbit","created_at":"2024-01-01T00:00:00Z","_content_hash":"abc"}`)
+	mark, err := json.Marshal(map[string]interface{}{"doc": tombstone.Mark(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Update(index, "1", bytes.NewReader(mark), client.Update.WithRefresh("true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// A reload with new content and a new created_at
+	update(`{"title":"The Hobbit, or There and Back Again","created_at":"2024-03-01T00:00:00Z","_content_hash":"def"}`)
+
+	resp, err = client.Get(index, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Source map[string]interface{} `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"title":           "The Hobbit, or There and Back Again",
+		"created_at":      "2024-01-01T00:00:00Z",
+		tombstone.Field:   true,
+		tombstone.AtField: "2024-02-01T00:00:00Z",
+	}
+	for field, value := range want {
+		if got.Source[field] != value {
+			t.Errorf("%s is %v after the reload, want %v", field, got.Source[field], value)
+		}
+	}
+}
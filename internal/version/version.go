@@ -0,0 +1,91 @@
+// Package version reports which build of the tools is running. Release
+// builds set Version with
+//
+//	go build -ldflags "-X github.com/nickcanz/search-go/internal/version.Version=v1.2.3" ./cmd/...
+//
+// and other builds report "dev" along with the commit they were built
+// from, when Go recorded one.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Version is the release tag the binary was built from.
+var Version = "dev"
+
+// String describes the build, e.g. "v1.2.3 (abc1234, go1.20.2 linux/amd64)".
+func String() string {
+	details := []string{}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var revision string
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if len(revision) > 7 {
+			revision = revision[:7]
+		}
+		if revision != "" && modified {
+			revision += "-dirty"
+		}
+		if revision != "" {
+			details = append(details, revision)
+		}
+	}
+	details = append(details, fmt.Sprintf("%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH))
+	return fmt.Sprintf("%s (%s)", Version, strings.Join(details, ", "))
+}
+
+// Newer reports whether release tag a is newer than b. Tags are compared
+// as dotted numbers with an optional leading v, and anything that isn't a
+// release, like "dev", is older than every release.
+func Newer(a, b string) bool {
+	aParts, aOK := parse(a)
+	bParts, bOK := parse(b)
+	if !aOK {
+		return false
+	}
+	if !bOK {
+		return true
+	}
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart != bPart {
+			return aPart > bPart
+		}
+	}
+	return false
+}
+
+func parse(tag string) ([]int, bool) {
+	tag = strings.TrimPrefix(tag, "v")
+	// Ignore pre-release and build suffixes like -rc1 or +meta
+	if i := strings.IndexAny(tag, "-+"); i >= 0 {
+		tag = tag[:i]
+	}
+	var parts []int
+	for _, field := range strings.Split(tag, ".") {
+		part, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, part)
+	}
+	return parts, true
+}
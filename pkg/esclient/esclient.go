@@ -0,0 +1,144 @@
+// Package esclient builds Elasticsearch clients the way every command in
+// this repository connects, so library users can share the same
+// connection settings and checks.
+package esclient
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/nickcanz/search-go/internal/transport"
+)
+
+// Config describes a connection to a cluster.
+type Config struct {
+	URL string
+	// User and Password are sent with basic auth. Both are empty for a
+	// cluster without security.
+	User     string
+	Password string
+
+	// Command identifies the caller in the X-Opaque-Id header of every
+	// request, as search-go/<Command>, so its requests can be found in
+	// the tasks API and slow logs.
+	Command string
+
+	// Header is sent with every request.
+	Header http.Header
+
+	// Transport replaces the default HTTP transport when set, e.g. to
+	// connect through a proxy.
+	Transport http.RoundTripper
+
+	// MaxRetries is how many times a request is sent before giving up
+	// when the cluster is overloaded (429), unavailable (502, 503, 504)
+	// or the connection fails. Zero means 3.
+	MaxRetries int
+	// RetryBackoff is how long to wait before sending a request again
+	// after attempt tries. Nil means an exponential backoff from 100ms up
+	// to 10s, with jitter so clients that failed together don't retry
+	// together.
+	RetryBackoff func(attempt int) time.Duration
+}
+
+// retryStatuses are the responses worth sending a request again for.
+// Bulk requests the cluster rejected as a whole with 429 are retried
+// too, since none of their documents were indexed.
+var retryStatuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// ConfigFromEnv reads a Config from ES_URL, ES_USER and ES_PASSWORD, plus
+// the headers in ES_HEADERS and proxy in ES_PROXY.
+func ConfigFromEnv(command string) (Config, error) {
+	cfg := Config{
+		URL:      os.Getenv("ES_URL"),
+		User:     os.Getenv("ES_USER"),
+		Password: os.Getenv("ES_PASSWORD"),
+		Command:  command,
+	}
+
+	var transportConfig elasticsearch7.Config
+	if err := transport.Apply(&transportConfig); err != nil {
+		return Config{}, err
+	}
+	cfg.Header = transportConfig.Header
+	cfg.Transport = transportConfig.Transport
+	return cfg, nil
+}
+
+// NewClientFromEnv returns a client configured by ConfigFromEnv.
+func NewClientFromEnv(command string) (*elasticsearch7.Client, error) {
+	cfg, err := ConfigFromEnv(command)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(cfg)
+}
+
+// NewClient checks cfg and returns a client for it.
+func NewClient(cfg Config) (*elasticsearch7.Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for name, values := range cfg.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	if cfg.Command != "" {
+		header.Set("X-Opaque-Id", "search-go/"+cfg.Command)
+	}
+
+	retryBackoff := cfg.RetryBackoff
+	if retryBackoff == nil {
+		retryBackoff = backoff
+	}
+
+	return elasticsearch7.NewClient(elasticsearch7.Config{
+		Addresses:     []string{cfg.URL},
+		Username:      cfg.User,
+		Password:      cfg.Password,
+		Header:        header,
+		Transport:     cfg.Transport,
+		RetryOnStatus: retryStatuses,
+		MaxRetries:    cfg.MaxRetries,
+		RetryBackoff:  retryBackoff,
+	})
+}
+
+// backoff waits between half and all of 100ms doubled for every attempt,
+// up to 10s.
+func backoff(attempt int) time.Duration {
+	wait := 10 * time.Second
+	if attempt < 8 {
+		wait = 100 * time.Millisecond << (attempt - 1)
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)))
+}
+
+func (cfg Config) validate() error {
+	if cfg.URL == "" {
+		return fmt.Errorf("no ES_URL set, add it to .env or log in and use -profile")
+	}
+	// A cluster without security takes neither, so only half of them is
+	// a mistake
+	if cfg.User == "" && cfg.Password != "" {
+		return fmt.Errorf("ES_PASSWORD is set without ES_USER")
+	}
+	if cfg.User != "" && cfg.Password == "" {
+		return fmt.Errorf("ES_USER is set without ES_PASSWORD")
+	}
+
+	clusterURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid ES_URL: %w", err)
+	}
+	if clusterURL.Scheme != "http" && clusterURL.Scheme != "https" || clusterURL.Host == "" {
+		return fmt.Errorf("invalid ES_URL %q, expected a URL like https://host:9200", cfg.URL)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package esclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewClientCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "user and password", cfg: Config{URL: "https://localhost:9200", User: "elastic", Password: "secret"}},
+		{name: "neither", cfg: Config{URL: "http://localhost:9200"}},
+		{name: "user only", cfg: Config{URL: "https://localhost:9200", User: "elastic"}, wantErr: true},
+		{name: "password only", cfg: Config{URL: "https://localhost:9200", Password: "secret"}, wantErr: true},
+		{name: "no url", cfg: Config{User: "elastic", Password: "secret"}, wantErr: true},
+		{name: "no scheme", cfg: Config{URL: "localhost:9200"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewClient(test.cfg)
+			if (err != nil) != test.wantErr {
+				t.Errorf("NewClient() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClientRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		maxRetries int
+		wantStatus int
+		wantTries  int32
+	}{
+		{name: "overloaded", status: http.StatusTooManyRequests, wantStatus: http.StatusOK, wantTries: 3},
+		{name: "unavailable", status: http.StatusServiceUnavailable, wantStatus: http.StatusOK, wantTries: 3},
+		{name: "out of retries", status: http.StatusTooManyRequests, maxRetries: 2, wantStatus: http.StatusTooManyRequests, wantTries: 2},
+		{name: "bad request", status: http.StatusBadRequest, wantStatus: http.StatusBadRequest, wantTries: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Fails the first two tries
+			var tries int32
+			cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if atomic.AddInt32(&tries, 1) <= 2 {
+					w.WriteHeader(test.status)
+				}
+				w.Write([]byte(`{}`))
+			}))
+			defer cluster.Close()
+
+			var backoffs []int
+			client, err := NewClient(Config{
+				URL:        cluster.URL,
+				MaxRetries: test.maxRetries,
+				RetryBackoff: func(attempt int) time.Duration {
+					backoffs = append(backoffs, attempt)
+					return 0
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Info()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.wantStatus || tries != test.wantTries {
+				t.Errorf("status %d after %d tries, want %d after %d", resp.StatusCode, tries, test.wantStatus, test.wantTries)
+			}
+			if test.wantTries > 1 && len(backoffs) == 0 {
+				t.Error("retried without backing off")
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 7, max: 6400 * time.Millisecond},
+		{attempt: 8, max: 10 * time.Second},
+		{attempt: 60, max: 10 * time.Second},
+	}
+	for _, test := range tests {
+		if wait := backoff(test.attempt); wait < test.max/2 || wait > test.max {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", test.attempt, wait, test.max/2, test.max)
+		}
+	}
+}
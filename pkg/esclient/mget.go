@@ -0,0 +1,54 @@
+package esclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// Document is one document fetched by MGet.
+type Document struct {
+	ID     string          `json:"_id"`
+	Found  bool            `json:"found"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// MGet fetches the documents with ids from index in one request and
+// returns them in the same order as ids. Documents that do not exist have
+// Found set to false. When fields are given, only those fields of each
+// document are returned.
+func MGet(client *elasticsearch7.Client, index string, ids []string, fields ...string) ([]Document, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	options := []func(*esapi.MgetRequest){client.Mget.WithIndex(index)}
+	if len(fields) > 0 {
+		options = append(options, client.Mget.WithSourceIncludes(fields...))
+	}
+	resp, err := client.Mget(bytes.NewReader(body), options...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("error fetching from %s, status: %s, response body: %s", index, resp.Status(), resp.String())
+	}
+
+	var mgetResponse struct {
+		Docs []Document `json:"docs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&mgetResponse); err != nil {
+		return nil, err
+	}
+	return mgetResponse.Docs, nil
+}
@@ -0,0 +1,101 @@
+package query
+
+// BoolQuery combines other queries with must, should, filter, and must_not
+// clauses. Build one with Bool and chain the clause methods:
+//
+//	query.Bool().
+//		Must(query.MultiMatch("dogs", "title", "description")).
+//		MustNot(query.MultiMatch("cats", "title"))
+type BoolQuery struct {
+	must               []Query
+	should             []Query
+	filter             []Query
+	mustNot            []Query
+	minimumShouldMatch string
+	boost              float64
+	name               string
+}
+
+// Bool returns an empty bool query.
+func Bool() *BoolQuery {
+	return &BoolQuery{}
+}
+
+// Must adds queries that matching documents must match, contributing to
+// the score.
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.must = append(q.must, queries...)
+	return q
+}
+
+// Should adds queries that matching documents should match. Without a
+// must or filter clause, at least one should clause has to match.
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.should = append(q.should, queries...)
+	return q
+}
+
+// Filter adds queries that matching documents must match, without
+// contributing to the score.
+func (q *BoolQuery) Filter(queries ...Query) *BoolQuery {
+	q.filter = append(q.filter, queries...)
+	return q
+}
+
+// MustNot adds queries that matching documents must not match.
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, queries...)
+	return q
+}
+
+// MinimumShouldMatch sets how many should clauses must match, e.g. "2" or
+// "75%".
+func (q *BoolQuery) MinimumShouldMatch(value string) *BoolQuery {
+	q.minimumShouldMatch = value
+	return q
+}
+
+// Boost multiplies the score of the whole bool query.
+func (q *BoolQuery) Boost(boost float64) *BoolQuery {
+	q.boost = boost
+	return q
+}
+
+// Name sets the name reported in a hit's matched_queries when this query
+// matches it.
+func (q *BoolQuery) Name(name string) *BoolQuery {
+	q.name = name
+	return q
+}
+
+// Source implements Query.
+func (q *BoolQuery) Source() map[string]interface{} {
+	clauses := map[string]interface{}{}
+	if len(q.must) > 0 {
+		clauses["must"] = sources(q.must)
+	}
+	if len(q.should) > 0 {
+		clauses["should"] = sources(q.should)
+	}
+	if len(q.filter) > 0 {
+		clauses["filter"] = sources(q.filter)
+	}
+	if len(q.mustNot) > 0 {
+		clauses["must_not"] = sources(q.mustNot)
+	}
+	if q.minimumShouldMatch != "" {
+		clauses["minimum_should_match"] = q.minimumShouldMatch
+	}
+	if q.boost != 0 {
+		clauses["boost"] = q.boost
+	}
+	if q.name != "" {
+		clauses["_name"] = q.name
+	}
+	return map[string]interface{}{"bool": clauses}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *BoolQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
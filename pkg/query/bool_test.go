@@ -0,0 +1,39 @@
+package query
+
+import "testing"
+
+func TestBool(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "empty", query: Bool(), want: `{"bool":{}}`},
+		{
+			name: "every clause",
+			query: Bool().
+				Must(Match("title", "hobbit")).
+				Should(Term("genres", "fantasy"), Term("genres", "adventure")).
+				Filter(Range("average_rating").Gte(4)).
+				MustNot(Exists("deleted")).
+				MinimumShouldMatch("1").
+				Boost(2).
+				Name("books"),
+			want: `{"bool":{
+				"must":[{"match":{"title":{"query":"hobbit"}}}],
+				"should":[{"term":{"genres":{"value":"fantasy"}}},{"term":{"genres":{"value":"adventure"}}}],
+				"filter":[{"range":{"average_rating":{"gte":4}}}],
+				"must_not":[{"exists":{"field":"deleted"}}],
+				"minimum_should_match":"1",
+				"boost":2,
+				"_name":"books"
+			}}`,
+		},
+		{
+			name:  "clauses add up",
+			query: Bool().Filter(Term("language_code", "eng")).Filter(Exists("isbn")),
+			want:  `{"bool":{"filter":[{"term":{"language_code":{"value":"eng"}}},{"exists":{"field":"isbn"}}]}}`,
+		},
+		{
+			name:  "nested",
+			query: Bool().Should(Bool().Must(MatchAll())),
+			want:  `{"bool":{"should":[{"bool":{"must":[{"match_all":{}}]}}]}}`,
+		},
+	})
+}
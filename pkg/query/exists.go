@@ -0,0 +1,21 @@
+package query
+
+// ExistsQuery matches documents that have a value for a field.
+type ExistsQuery struct {
+	field string
+}
+
+// Exists returns a query matching documents where field has a value.
+func Exists(field string) *ExistsQuery {
+	return &ExistsQuery{field: field}
+}
+
+// Source implements Query.
+func (q *ExistsQuery) Source() map[string]interface{} {
+	return map[string]interface{}{"exists": map[string]interface{}{"field": q.field}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *ExistsQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
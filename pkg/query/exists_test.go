@@ -0,0 +1,10 @@
+package query
+
+import "testing"
+
+func TestExists(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "field", query: Exists("isbn13"), want: `{"exists":{"field":"isbn13"}}`},
+		{name: "subfield", query: Exists("title.keyword"), want: `{"exists":{"field":"title.keyword"}}`},
+	})
+}
@@ -0,0 +1,46 @@
+package query
+
+// MatchQuery runs a full text query against a single field.
+type MatchQuery struct {
+	field     string
+	text      string
+	operator  string
+	fuzziness string
+}
+
+// Match returns a query for text in field.
+func Match(field, text string) *MatchQuery {
+	return &MatchQuery{field: field, text: text}
+}
+
+// Operator sets whether all terms ("and") or any term ("or") must match.
+func (q *MatchQuery) Operator(operator string) *MatchQuery {
+	q.operator = operator
+	return q
+}
+
+// Fuzziness sets how many edits a term may be from a matching term, e.g.
+// "AUTO" or "1".
+func (q *MatchQuery) Fuzziness(fuzziness string) *MatchQuery {
+	q.fuzziness = fuzziness
+	return q
+}
+
+// Source implements Query.
+func (q *MatchQuery) Source() map[string]interface{} {
+	params := map[string]interface{}{
+		"query": q.text,
+	}
+	if q.operator != "" {
+		params["operator"] = q.operator
+	}
+	if q.fuzziness != "" {
+		params["fuzziness"] = q.fuzziness
+	}
+	return map[string]interface{}{"match": map[string]interface{}{q.field: params}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *MatchQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
@@ -0,0 +1,19 @@
+package query
+
+// MatchAllQuery matches every document with a score of 1.
+type MatchAllQuery struct{}
+
+// MatchAll returns a query matching every document.
+func MatchAll() *MatchAllQuery {
+	return &MatchAllQuery{}
+}
+
+// Source implements Query.
+func (q *MatchAllQuery) Source() map[string]interface{} {
+	return map[string]interface{}{"match_all": map[string]interface{}{}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *MatchAllQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
@@ -0,0 +1,9 @@
+package query
+
+import "testing"
+
+func TestMatchAll(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "match all", query: MatchAll(), want: `{"match_all":{}}`},
+	})
+}
@@ -0,0 +1,12 @@
+package query
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "text", query: Match("title", "the hobbit"), want: `{"match":{"title":{"query":"the hobbit"}}}`},
+		{name: "operator", query: Match("title", "the hobbit").Operator("and"), want: `{"match":{"title":{"query":"the hobbit","operator":"and"}}}`},
+		{name: "fuzziness", query: Match("title", "hobit").Fuzziness("AUTO"), want: `{"match":{"title":{"query":"hobit","fuzziness":"AUTO"}}}`},
+		{name: "empty text", query: Match("title", ""), want: `{"match":{"title":{"query":""}}}`},
+	})
+}
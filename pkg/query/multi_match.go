@@ -0,0 +1,106 @@
+package query
+
+// MultiMatchQuery runs a full text query against several fields.
+type MultiMatchQuery struct {
+	text               string
+	fields             []string
+	matchType          string
+	operator           string
+	minimumShouldMatch string
+	fuzziness          string
+	tieBreaker         *float64
+	boost              *float64
+	name               string
+}
+
+// MultiMatch returns a query for text in fields. Fields may carry a boost,
+// e.g. "title^3".
+func MultiMatch(text string, fields ...string) *MultiMatchQuery {
+	return &MultiMatchQuery{text: text, fields: fields}
+}
+
+// Type sets how matches across fields are scored, e.g. "best_fields",
+// "most_fields", or "cross_fields".
+func (q *MultiMatchQuery) Type(matchType string) *MultiMatchQuery {
+	q.matchType = matchType
+	return q
+}
+
+// Operator sets whether all terms ("and") or any term ("or") must match.
+func (q *MultiMatchQuery) Operator(operator string) *MultiMatchQuery {
+	q.operator = operator
+	return q
+}
+
+// MinimumShouldMatch sets how many terms must match, e.g. "2" or "75%".
+func (q *MultiMatchQuery) MinimumShouldMatch(value string) *MultiMatchQuery {
+	q.minimumShouldMatch = value
+	return q
+}
+
+// Fuzziness sets how many edits a term may be from a matching term, e.g.
+// "AUTO" or "1", so misspelled queries still match. Elasticsearch doesn't
+// allow it with the phrase and cross_fields types.
+func (q *MultiMatchQuery) Fuzziness(fuzziness string) *MultiMatchQuery {
+	q.fuzziness = fuzziness
+	return q
+}
+
+// TieBreaker sets how much matches in fields other than the best one add
+// to the score, between 0 and 1.
+func (q *MultiMatchQuery) TieBreaker(tieBreaker float64) *MultiMatchQuery {
+	q.tieBreaker = &tieBreaker
+	return q
+}
+
+// Boost multiplies the score of the query. A boost of 0 keeps a clause
+// from affecting the score, which is useful for named clauses that are
+// only there to attribute matches.
+func (q *MultiMatchQuery) Boost(boost float64) *MultiMatchQuery {
+	q.boost = &boost
+	return q
+}
+
+// Name sets the name reported in a hit's matched_queries when this query
+// matches it.
+func (q *MultiMatchQuery) Name(name string) *MultiMatchQuery {
+	q.name = name
+	return q
+}
+
+// Source implements Query.
+func (q *MultiMatchQuery) Source() map[string]interface{} {
+	params := map[string]interface{}{
+		"query": q.text,
+	}
+	if len(q.fields) > 0 {
+		params["fields"] = q.fields
+	}
+	if q.matchType != "" {
+		params["type"] = q.matchType
+	}
+	if q.operator != "" {
+		params["operator"] = q.operator
+	}
+	if q.minimumShouldMatch != "" {
+		params["minimum_should_match"] = q.minimumShouldMatch
+	}
+	if q.fuzziness != "" {
+		params["fuzziness"] = q.fuzziness
+	}
+	if q.tieBreaker != nil {
+		params["tie_breaker"] = *q.tieBreaker
+	}
+	if q.boost != nil {
+		params["boost"] = *q.boost
+	}
+	if q.name != "" {
+		params["_name"] = q.name
+	}
+	return map[string]interface{}{"multi_match": params}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *MultiMatchQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
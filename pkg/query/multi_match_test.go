@@ -0,0 +1,24 @@
+package query
+
+import "testing"
+
+func TestMultiMatch(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "no fields", query: MultiMatch("dragons"), want: `{"multi_match":{"query":"dragons"}}`},
+		{name: "boosted fields", query: MultiMatch("dragons", "title^3", "description"), want: `{"multi_match":{"query":"dragons","fields":["title^3","description"]}}`},
+		{
+			name: "every option",
+			query: MultiMatch("le guin", "authors.name", "title").
+				Type("cross_fields").
+				Operator("and").
+				MinimumShouldMatch("75%").
+				Fuzziness("1").
+				TieBreaker(0.3).
+				Boost(2).
+				Name("author"),
+			want: `{"multi_match":{"query":"le guin","fields":["authors.name","title"],"type":"cross_fields","operator":"and","minimum_should_match":"75%","fuzziness":"1","tie_breaker":0.3,"boost":2,"_name":"author"}}`,
+		},
+		// Zero is a meaningful boost and tie breaker, so it is still sent
+		{name: "zero boost", query: MultiMatch("dragons", "title").Boost(0).TieBreaker(0), want: `{"multi_match":{"query":"dragons","fields":["title"],"tie_breaker":0,"boost":0}}`},
+	})
+}
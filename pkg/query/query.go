@@ -0,0 +1,25 @@
+// Package query builds Elasticsearch query DSL clauses from Go values, so
+// request bodies are produced by json.Marshal instead of string templates.
+package query
+
+import "encoding/json"
+
+// Query is a clause of the Elasticsearch query DSL.
+type Query interface {
+	// Source returns the clause as it is sent to Elasticsearch.
+	Source() map[string]interface{}
+}
+
+// marshal encodes a query's source, for use in MarshalJSON methods.
+func marshal(q Query) ([]byte, error) {
+	return json.Marshal(q.Source())
+}
+
+// sources returns the source of each query.
+func sources(queries []Query) []interface{} {
+	result := make([]interface{}, len(queries))
+	for i, q := range queries {
+		result[i] = q.Source()
+	}
+	return result
+}
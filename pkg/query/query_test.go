@@ -0,0 +1,68 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// queryTest is a query and the JSON it should marshal to.
+type queryTest struct {
+	name  string
+	query Query
+	want  string
+}
+
+// runQueryTests checks that each query marshals to its JSON, both through
+// MarshalJSON and from Source, so the two can't drift apart.
+func runQueryTests(t *testing.T, tests []queryTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var want interface{}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatalf("invalid want JSON: %v", err)
+			}
+			wantJSON, _ := json.Marshal(want)
+
+			got, err := json.Marshal(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(wantJSON) {
+				t.Errorf("marshalled to\n%s\nwant\n%s", got, wantJSON)
+			}
+			source, err := json.Marshal(test.query.Source())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(source) != string(got) {
+				t.Errorf("Source() marshals to %s, MarshalJSON to %s", source, got)
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	if got := sources(nil); len(got) != 0 {
+		t.Errorf("sources(nil) = %v, want none", got)
+	}
+	got, err := json.Marshal(sources([]Query{MatchAll(), Exists("isbn")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"match_all":{}},{"exists":{"field":"isbn"}}]`; string(got) != want {
+		t.Errorf("sources marshalled to %s, want %s, in order", got, want)
+	}
+}
+
+func TestQueriesNestInMaps(t *testing.T) {
+	// Request bodies embed queries in maps, which must marshal them the
+	// same way as Source
+	body, err := json.Marshal(map[string]interface{}{"query": Term("isbn", "0618640150"), "size": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"query":{"term":{"isbn":{"value":"0618640150"}}},"size":1}`; string(body) != want {
+		t.Errorf("body marshalled to %s, want %s", body, want)
+	}
+}
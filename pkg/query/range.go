@@ -0,0 +1,73 @@
+package query
+
+// RangeQuery matches documents whose field falls within bounds. Bounds
+// left unset are open.
+type RangeQuery struct {
+	field  string
+	bounds map[string]interface{}
+	format string
+	name   string
+}
+
+// Range returns a query on field with no bounds set.
+func Range(field string) *RangeQuery {
+	return &RangeQuery{field: field, bounds: map[string]interface{}{}}
+}
+
+// Gte sets an inclusive lower bound.
+func (q *RangeQuery) Gte(value interface{}) *RangeQuery {
+	q.bounds["gte"] = value
+	return q
+}
+
+// Gt sets an exclusive lower bound.
+func (q *RangeQuery) Gt(value interface{}) *RangeQuery {
+	q.bounds["gt"] = value
+	return q
+}
+
+// Lte sets an inclusive upper bound.
+func (q *RangeQuery) Lte(value interface{}) *RangeQuery {
+	q.bounds["lte"] = value
+	return q
+}
+
+// Lt sets an exclusive upper bound.
+func (q *RangeQuery) Lt(value interface{}) *RangeQuery {
+	q.bounds["lt"] = value
+	return q
+}
+
+// Format sets the date format bounds on a date field are written in,
+// e.g. "yyyy".
+func (q *RangeQuery) Format(format string) *RangeQuery {
+	q.format = format
+	return q
+}
+
+// Name sets the name reported in a hit's matched_queries when this query
+// matches it.
+func (q *RangeQuery) Name(name string) *RangeQuery {
+	q.name = name
+	return q
+}
+
+// Source implements Query.
+func (q *RangeQuery) Source() map[string]interface{} {
+	params := map[string]interface{}{}
+	for bound, value := range q.bounds {
+		params[bound] = value
+	}
+	if q.format != "" {
+		params["format"] = q.format
+	}
+	if q.name != "" {
+		params["_name"] = q.name
+	}
+	return map[string]interface{}{"range": map[string]interface{}{q.field: params}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *RangeQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
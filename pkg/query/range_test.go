@@ -0,0 +1,21 @@
+package query
+
+import "testing"
+
+func TestRange(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "open", query: Range("average_rating"), want: `{"range":{"average_rating":{}}}`},
+		{name: "inclusive", query: Range("average_rating").Gte(3.5).Lte(5), want: `{"range":{"average_rating":{"gte":3.5,"lte":5}}}`},
+		{name: "exclusive", query: Range("ratings_count").Gt(100).Lt(1000), want: `{"range":{"ratings_count":{"gt":100,"lt":1000}}}`},
+		{name: "date format", query: Range("publication_year").Gte("1950").Format("yyyy").Name("since"), want: `{"range":{"publication_year":{"gte":"1950","format":"yyyy","_name":"since"}}}`},
+		{name: "bound set twice", query: Range("average_rating").Gte(3).Gte(4), want: `{"range":{"average_rating":{"gte":4}}}`},
+	})
+}
+
+func TestRangeSourceIsACopy(t *testing.T) {
+	q := Range("average_rating").Gte(4)
+	q.Source()["range"].(map[string]interface{})["average_rating"].(map[string]interface{})["lt"] = 5
+	runQueryTests(t, []queryTest{
+		{name: "unchanged", query: q, want: `{"range":{"average_rating":{"gte":4}}}`},
+	})
+}
@@ -0,0 +1,42 @@
+package query
+
+// ScriptScoreQuery scores the documents matched by another query with a
+// painless script.
+type ScriptScoreQuery struct {
+	query  Query
+	source string
+	params map[string]interface{}
+}
+
+// ScriptScore returns a query that matches the same documents as q and
+// scores them with the painless script source.
+func ScriptScore(q Query, source string) *ScriptScoreQuery {
+	return &ScriptScoreQuery{query: q, source: source}
+}
+
+// Params sets parameters the script can read from params.
+func (q *ScriptScoreQuery) Params(params map[string]interface{}) *ScriptScoreQuery {
+	q.params = params
+	return q
+}
+
+// Source implements Query.
+func (q *ScriptScoreQuery) Source() map[string]interface{} {
+	script := map[string]interface{}{
+		"source": q.source,
+	}
+	if len(q.params) > 0 {
+		script["params"] = q.params
+	}
+	return map[string]interface{}{
+		"script_score": map[string]interface{}{
+			"query":  q.query.Source(),
+			"script": script,
+		},
+	}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *ScriptScoreQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
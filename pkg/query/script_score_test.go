@@ -0,0 +1,24 @@
+package query
+
+import "testing"
+
+func TestScriptScore(t *testing.T) {
+	const script = "_score * Math.log(2 + doc['ratings_count'].value)"
+	runQueryTests(t, []queryTest{
+		{
+			name:  "script",
+			query: ScriptScore(MatchAll(), script),
+			want:  `{"script_score":{"query":{"match_all":{}},"script":{"source":"_score * Math.log(2 + doc['ratings_count'].value)"}}}`,
+		},
+		{
+			name:  "params",
+			query: ScriptScore(Match("title", "dune"), "_score * params.weight").Params(map[string]interface{}{"weight": 1.5}),
+			want:  `{"script_score":{"query":{"match":{"title":{"query":"dune"}}},"script":{"source":"_score * params.weight","params":{"weight":1.5}}}}`,
+		},
+		{
+			name:  "empty params",
+			query: ScriptScore(MatchAll(), "1").Params(map[string]interface{}{}),
+			want:  `{"script_score":{"query":{"match_all":{}},"script":{"source":"1"}}}`,
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package query
+
+// TermQuery matches documents whose field exactly equals a value. The
+// value is not analyzed, so it is meant for keyword, numeric and date
+// fields.
+type TermQuery struct {
+	field string
+	value interface{}
+	boost *float64
+	name  string
+}
+
+// Term returns a query matching documents where field equals value.
+func Term(field string, value interface{}) *TermQuery {
+	return &TermQuery{field: field, value: value}
+}
+
+// Boost multiplies the score of the query.
+func (q *TermQuery) Boost(boost float64) *TermQuery {
+	q.boost = &boost
+	return q
+}
+
+// Name sets the name reported in a hit's matched_queries when this query
+// matches it.
+func (q *TermQuery) Name(name string) *TermQuery {
+	q.name = name
+	return q
+}
+
+// Source implements Query.
+func (q *TermQuery) Source() map[string]interface{} {
+	params := map[string]interface{}{
+		"value": q.value,
+	}
+	if q.boost != nil {
+		params["boost"] = *q.boost
+	}
+	if q.name != "" {
+		params["_name"] = q.name
+	}
+	return map[string]interface{}{"term": map[string]interface{}{q.field: params}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *TermQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
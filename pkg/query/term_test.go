@@ -0,0 +1,13 @@
+package query
+
+import "testing"
+
+func TestTerm(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "string", query: Term("isbn", "0618640150"), want: `{"term":{"isbn":{"value":"0618640150"}}}`},
+		{name: "number", query: Term("publication_year", 1937), want: `{"term":{"publication_year":{"value":1937}}}`},
+		{name: "bool", query: Term("deleted", true), want: `{"term":{"deleted":{"value":true}}}`},
+		{name: "boost and name", query: Term("title.keyword", "the hobbit").Boost(10).Name("exact"), want: `{"term":{"title.keyword":{"value":"the hobbit","boost":10,"_name":"exact"}}}`},
+		{name: "zero boost", query: Term("isbn", "0618640150").Boost(0), want: `{"term":{"isbn":{"value":"0618640150","boost":0}}}`},
+	})
+}
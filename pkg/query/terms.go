@@ -0,0 +1,45 @@
+package query
+
+// TermsQuery matches documents whose field exactly equals one of a list
+// of values, either given inline or looked up from a document.
+type TermsQuery struct {
+	field  string
+	values []interface{}
+	lookup map[string]interface{}
+}
+
+// Terms returns a query matching documents where field is one of values.
+func Terms(field string, values ...interface{}) *TermsQuery {
+	return &TermsQuery{field: field, values: values}
+}
+
+// TermsLookup returns a query matching documents where field is one of the
+// values stored at path in the document id of index. Storing a long list
+// once and referencing it keeps it out of every request body.
+func TermsLookup(field, index, id, path string) *TermsQuery {
+	return &TermsQuery{
+		field: field,
+		lookup: map[string]interface{}{
+			"index": index,
+			"id":    id,
+			"path":  path,
+		},
+	}
+}
+
+// Source implements Query.
+func (q *TermsQuery) Source() map[string]interface{} {
+	if q.lookup != nil {
+		return map[string]interface{}{"terms": map[string]interface{}{q.field: q.lookup}}
+	}
+	values := q.values
+	if values == nil {
+		values = []interface{}{}
+	}
+	return map[string]interface{}{"terms": map[string]interface{}{q.field: values}}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q *TermsQuery) MarshalJSON() ([]byte, error) {
+	return marshal(q)
+}
@@ -0,0 +1,17 @@
+package query
+
+import "testing"
+
+func TestTerms(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{name: "values", query: Terms("book_id", "1", "2", "3"), want: `{"terms":{"book_id":["1","2","3"]}}`},
+		{name: "mixed values", query: Terms("publication_year", 1937, "1954"), want: `{"terms":{"publication_year":[1937,"1954"]}}`},
+		// An empty list matches nothing, rather than being left out
+		{name: "no values", query: Terms("book_id"), want: `{"terms":{"book_id":[]}}`},
+		{
+			name:  "lookup",
+			query: TermsLookup("book_id", "lists", "favourites", "book_ids"),
+			want:  `{"terms":{"book_id":{"index":"lists","id":"favourites","path":"book_ids"}}}`,
+		},
+	})
+}